@@ -23,21 +23,32 @@ func main() {
 	defer logger.Sync()
 
 	log := logger.L()
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(ctx, os.Args[2:], log); err != nil {
+			log.Fatal("migrate failed", zap.Error(err))
+		}
+		return
+	}
+
 	cfg := config.Load(log)
 
-	store, err := storage.New(cfg.DatabaseURL, log)
+	store, err := storage.Open(cfg.DatabaseURL, log)
 	if err != nil {
 		log.Fatal("failed to connect to database", zap.Error(err))
 	}
 	defer store.Close()
+	if cfg.MemoryCacheEntries > 0 {
+		store = storage.NewLRU(store, cfg.MemoryCacheEntries, cfg.MemoryCacheTTL)
+	}
 
 	b, err := bot.New(cfg, log, store)
 	if err != nil {
 		log.Fatal("failed to create bot", zap.Error(err))
 	}
 
-	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
-	defer cancel()
-
 	b.Run(ctx)
 }
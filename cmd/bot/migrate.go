@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"xa4yy_vidsave/internal/storage"
+
+	"go.uber.org/zap"
+)
+
+const migrateUsage = "usage: bot migrate up | down [N] | status"
+
+// runMigrate — подкоманда «bot migrate up|down [N]|status».
+// Нужен только DATABASE_URL: остальной конфиг бота не загружается.
+func runMigrate(ctx context.Context, args []string, log *zap.Logger) error {
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+
+	dsn := strings.TrimSpace(os.Getenv("DATABASE_URL"))
+	if strings.HasPrefix(dsn, "memory://") {
+		return errors.New("migrations apply only to PostgreSQL, DATABASE_URL is memory://")
+	}
+	store, err := storage.Connect(dsn, log)
+	if err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer store.Close()
+
+	switch args[0] {
+	case "up":
+		applied, err := store.MigrateUp(ctx)
+		for _, m := range applied {
+			fmt.Printf("applied     %04d_%s\n", m.Version, m.Name)
+		}
+		if err == nil && len(applied) == 0 {
+			fmt.Println("schema is up to date")
+		}
+		return err
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			if steps, err = strconv.Atoi(args[1]); err != nil || steps < 1 {
+				return errors.New(migrateUsage)
+			}
+		}
+		rolledBack, err := store.MigrateDown(ctx, steps)
+		for _, m := range rolledBack {
+			fmt.Printf("rolled back %04d_%s\n", m.Version, m.Name)
+		}
+		return err
+	case "status":
+		states, err := store.MigrationStatus(ctx)
+		if err != nil {
+			return err
+		}
+		for _, st := range states {
+			applied := "pending"
+			if st.AppliedAt != nil {
+				applied = st.AppliedAt.Local().Format(time.DateTime)
+			}
+			fmt.Printf("%04d_%-24s %s\n", st.Version, st.Name, applied)
+		}
+		return nil
+	default:
+		return errors.New(migrateUsage)
+	}
+}
@@ -3,8 +3,13 @@ package bot
 import (
 	"context"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"xa4yy_vidsave/internal/config"
+	"xa4yy_vidsave/internal/download"
 	"xa4yy_vidsave/internal/link"
+	"xa4yy_vidsave/internal/media"
+	"xa4yy_vidsave/internal/proc"
 	"xa4yy_vidsave/internal/storage"
 
 	"go.uber.org/zap"
@@ -18,44 +23,136 @@ type Bot struct {
 	cfg           *config.Config
 	log           *zap.Logger
 	sender        *Sender
-	store         *storage.Storage
+	store         storage.Store
+	workDir       *download.WorkDir
+	cookies       *download.CookieJars
+	proxies       *download.ProxyPool
+	native        map[link.Type]download.Backend
+	media         media.Tools
 	downloadSlots chan struct{}
+	jobWake       chan struct{}
+	active        *activeJobs
+	requests      *requestLog
+	hits          *hitCounter
+	seen          *seenTracker
+
+	fileIDInvalidations atomic.Int64 // сколько file_id из кэша Telegram отверг с запуска
 }
 
 // New создаёт экземпляр бота.
-func New(cfg *config.Config, log *zap.Logger, store *storage.Storage) (*Bot, error) {
+func New(cfg *config.Config, log *zap.Logger, store storage.Store) (*Bot, error) {
 	api, err := tgbotapi.NewBotAPI(cfg.BotToken)
 	if err != nil {
 		return nil, err
 	}
 
+	workDir, err := download.NewWorkDir(cfg.WorkDir, cfg.WorkDirQuotaBytes, cfg.WorkDirMaxAge, log)
+	if err != nil {
+		return nil, err
+	}
+
+	cookies, err := download.LoadCookieJars(cfg.CookiesDir, cfg.CookieCooldown, log)
+	if err != nil {
+		return nil, err
+	}
+
+	// Нативные extractor'ы по платформам; платформы без них качаются через yt-dlp
+	native := make(map[link.Type]download.Backend)
+	if cfg.NativeBackends {
+		native[link.TypeTikTok] = download.NewTikTokBackend()
+		native[link.TypeInstagram] = download.NewInstagramBackend()
+	}
+
 	maxConcurrentDownloads := cfg.MaxConcurrentDownloads
 	if maxConcurrentDownloads < 1 {
 		maxConcurrentDownloads = 3
 	}
 
+	sender := NewSender(api, log)
+
 	log.Info("bot authorized",
 		zap.String("username", api.Self.UserName),
 		zap.Bool("can_read_all_group_messages", api.Self.CanReadAllGroupMessages),
 		zap.Int("max_concurrent_downloads", maxConcurrentDownloads),
+		zap.String("work_dir", workDir.Root()),
+		zap.Bool("native_backends", cfg.NativeBackends),
 	)
 	if !api.Self.CanReadAllGroupMessages {
 		log.Warn("Telegram privacy mode is enabled; disable it via BotFather /setprivacy to receive ordinary group messages")
 	}
 
-	return &Bot{
-		api:           api,
-		cfg:           cfg,
-		log:           log,
-		sender:        NewSender(api, log),
-		store:         store,
+	b := &Bot{
+		api:     api,
+		cfg:     cfg,
+		log:     log,
+		sender:  sender,
+		store:   store,
+		workDir: workDir,
+		cookies: cookies,
+		proxies: download.NewProxyPool(cfg.Proxies, download.ProxyStrategy(cfg.ProxyStrategy), cfg.ProxyMaxFailures, cfg.ProxyQuarantine, log),
+		native:  native,
+		media: media.Tools{Limits: proc.Limits{
+			CPUTime:   cfg.YtDlpCPUTime,
+			Memory:    cfg.YtDlpMemoryBytes,
+			MaxOutput: cfg.YtDlpMaxOutputBytes,
+		}},
 		downloadSlots: make(chan struct{}, maxConcurrentDownloads),
-	}, nil
+		jobWake:       make(chan struct{}, maxConcurrentDownloads),
+		active:        newActiveJobs(),
+		requests:      newRequestLog(store, log),
+		hits:          newHitCounter(store, log),
+		seen:          newSeenTracker(store, log),
+	}
+	sender.onBlocked = b.markBlocked
+	return b, nil
 }
 
-// Run запускает long-polling обработку обновлений.
-// Блокирует до отмены ctx.
+// Run запускает воркеры очереди и long-polling обработку обновлений.
+// Блокирует до отмены ctx и остановки воркеров. Журнал запросов и счётчики
+// попаданий и последних визитов останавливаются последними: в них пишут и обработчики, и воркеры.
 func (b *Bot) Run(ctx context.Context) {
+	var handlers, workers, sinks sync.WaitGroup
+	sinkCtx, stopSinks := context.WithCancel(context.WithoutCancel(ctx))
+	defer func() {
+		handlers.Wait()
+		workers.Wait()
+		stopSinks()
+		sinks.Wait()
+	}()
+
+	sinks.Add(1)
+	go func() {
+		defer sinks.Done()
+		b.requests.Run(sinkCtx)
+	}()
+	sinks.Add(1)
+	go func() {
+		defer sinks.Done()
+		b.hits.Run(sinkCtx)
+	}()
+	sinks.Add(1)
+	go func() {
+		defer sinks.Done()
+		b.seen.Run(sinkCtx)
+	}()
+
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		b.runJobReaper(ctx)
+	}()
+	b.startWorkers(ctx, &workers)
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		b.workDir.RunJanitor(ctx, b.cfg.JanitorInterval, b.cfg.WorkDirMaxAge)
+	}()
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		storage.RunMaintenance(ctx, b.store, b.cfg.CacheMaintenance, b.maintenanceConfig(), b.log)
+	}()
+
 	u := tgbotapi.NewUpdate(0)
 	u.Timeout = 30
 	updates := b.api.GetUpdatesChan(u)
@@ -67,19 +164,59 @@ func (b *Bot) Run(ctx context.Context) {
 			b.log.Info("shutting down gracefully")
 			return
 		case upd := <-updates:
-			go b.handleUpdate(ctx, upd)
+			handlers.Add(1)
+			go func() {
+				defer handlers.Done()
+				b.handleUpdate(ctx, upd)
+			}()
 		}
 	}
 }
 
+// maintenanceConfig — пороги обслуживания кэша из конфига.
+func (b *Bot) maintenanceConfig() storage.MaintenanceConfig {
+	eviction := storage.EvictLRU
+	if b.cfg.CacheEviction == string(storage.EvictLFU) {
+		eviction = storage.EvictLFU
+	}
+	return storage.MaintenanceConfig{
+		TTL:        b.cfg.CacheTTL,
+		MaxEntries: b.cfg.CacheMaxEntries,
+		Eviction:   eviction,
+		PurgeAfter: b.cfg.CachePurgeAfter,
+
+		RequestRetention: b.cfg.RequestRetention,
+	}
+}
+
 // handleUpdate обрабатывает одно обновление (сообщение пользователя).
 func (b *Bot) handleUpdate(ctx context.Context, upd tgbotapi.Update) {
 	// Inline-запросы (кнопка «Поделиться»)
 	if upd.InlineQuery != nil {
+		b.touch(upd.InlineQuery.From, nil)
 		b.handleInlineQuery(upd.InlineQuery)
 		return
 	}
 
+	// Inline-результат выбран и отправлен — это настоящая отправка из кэша.
+	// Telegram присылает их, только если в BotFather включён /setinlinefeedback.
+	if upd.ChosenInlineResult != nil {
+		b.touch(upd.ChosenInlineResult.From, nil)
+		b.hits.Add(upd.ChosenInlineResult.ResultID)
+		return
+	}
+
+	// Нажатия inline-кнопок («Отмена», «Субтитры», «GIF», «Кружок»)
+	if upd.CallbackQuery != nil {
+		var chat *tgbotapi.Chat
+		if upd.CallbackQuery.Message != nil {
+			chat = upd.CallbackQuery.Message.Chat
+		}
+		b.touch(upd.CallbackQuery.From, chat)
+		b.handleCallbackQuery(ctx, upd.CallbackQuery)
+		return
+	}
+
 	if upd.Message == nil {
 		return
 	}
@@ -92,6 +229,11 @@ func (b *Bot) handleUpdate(ctx context.Context, upd tgbotapi.Update) {
 		return
 	}
 
+	var userID int64
+	if msg.From != nil {
+		userID = msg.From.ID
+	}
+
 	replyToMessageID := 0
 	if isGroup {
 		replyToMessageID = msg.MessageID
@@ -109,10 +251,11 @@ func (b *Bot) handleUpdate(ctx context.Context, upd tgbotapi.Update) {
 	if msg.From != nil && msg.From.IsBot {
 		return
 	}
+	b.touch(msg.From, msg.Chat)
 
 	// Команды
 	if msg.IsCommand() {
-		b.handleCommand(chatID, msg)
+		b.handleCommand(ctx, chatID, msg)
 		return
 	}
 
@@ -142,7 +285,7 @@ func (b *Bot) handleUpdate(ctx context.Context, upd tgbotapi.Update) {
 
 	switch parsed.LinkType {
 	case link.TypeInstagram, link.TypeTikTok:
-		b.handleDownload(ctx, chatID, replyToMessageID, parsed)
+		b.handleDownload(chatID, userID, msg.Chat.Type, replyToMessageID, parsed)
 	default:
 		b.sender.TextReply(chatID, replyToMessageID, "этот тип пока не поддерживаю 😕")
 	}
@@ -0,0 +1,193 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"xa4yy_vidsave/internal/storage"
+
+	"go.uber.org/zap"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// cancelCallbackData — callback_data кнопки «Отмена» в статус-сообщении.
+const cancelCallbackData = "cancel"
+
+// cancelKeyboard — клавиатура статус-сообщения «⏳ качаю».
+func cancelKeyboard() tgbotapi.InlineKeyboardMarkup {
+	return tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("✖️ Отмена", cancelCallbackData),
+		),
+	)
+}
+
+// statusKey — статус-сообщение, по которому находим выполняющуюся задачу.
+type statusKey struct {
+	chatID    int64
+	messageID int
+}
+
+// activeJob — задача, которую сейчас выполняет воркер этого процесса.
+type activeJob struct {
+	userID    int64
+	cancel    context.CancelFunc
+	cancelled bool
+}
+
+// activeJobs — реестр выполняющихся задач по статус-сообщению.
+type activeJobs struct {
+	mu   sync.Mutex
+	jobs map[statusKey]*activeJob
+}
+
+func newActiveJobs() *activeJobs {
+	return &activeJobs{jobs: make(map[statusKey]*activeJob)}
+}
+
+// track регистрирует задачу и возвращает её контекст. release нужно вызвать по завершении;
+// он сообщает, была ли задача отменена пользователем.
+func (a *activeJobs) track(ctx context.Context, key statusKey, userID int64) (jobCtx context.Context, release func() (cancelled bool)) {
+	jobCtx, cancel := context.WithCancel(ctx)
+	job := &activeJob{userID: userID, cancel: cancel}
+
+	a.mu.Lock()
+	a.jobs[key] = job
+	a.mu.Unlock()
+
+	return jobCtx, func() bool {
+		a.mu.Lock()
+		defer a.mu.Unlock()
+		if a.jobs[key] == job {
+			delete(a.jobs, key)
+		}
+		cancel()
+		return job.cancelled
+	}
+}
+
+// lookup возвращает владельца выполняющейся задачи.
+func (a *activeJobs) lookup(key statusKey) (userID int64, ok bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	job, ok := a.jobs[key]
+	if !ok {
+		return 0, false
+	}
+	return job.userID, true
+}
+
+// cancel отменяет контекст задачи: yt-dlp будет убит, слот скачивания освободится.
+func (a *activeJobs) cancel(key statusKey) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	job, ok := a.jobs[key]
+	if !ok {
+		return false
+	}
+	job.cancelled = true
+	job.cancel()
+	return true
+}
+
+// handleCallbackQuery обрабатывает нажатия inline-кнопок.
+func (b *Bot) handleCallbackQuery(ctx context.Context, q *tgbotapi.CallbackQuery) {
+	switch {
+	case q.Data == cancelCallbackData:
+		b.handleCancel(q)
+	case strings.HasPrefix(q.Data, subtitlesCallbackPrefix):
+		b.handleSubtitles(ctx, q, strings.TrimPrefix(q.Data, subtitlesCallbackPrefix))
+	case strings.HasPrefix(q.Data, animationCallbackPrefix):
+		b.handleConvert(ctx, q, strings.TrimPrefix(q.Data, animationCallbackPrefix), animationConversion)
+	case strings.HasPrefix(q.Data, videoNoteCallbackPrefix):
+		b.handleConvert(ctx, q, strings.TrimPrefix(q.Data, videoNoteCallbackPrefix), videoNoteConversion)
+	case strings.HasPrefix(q.Data, preferencesCallbackPrefix):
+		b.handlePreferenceToggle(q, strings.TrimPrefix(q.Data, preferencesCallbackPrefix))
+	default:
+		b.sender.AnswerCallback(q.ID, "")
+	}
+}
+
+// handleCancel отменяет скачивание по кнопке «✖️ Отмена».
+// Нажать её может только автор запроса или админ группы.
+func (b *Bot) handleCancel(q *tgbotapi.CallbackQuery) {
+	if q.Message == nil || q.From == nil {
+		b.sender.AnswerCallback(q.ID, "")
+		return
+	}
+
+	key := statusKey{chatID: q.Message.Chat.ID, messageID: q.Message.MessageID}
+
+	// Задача выполняется в этом процессе — отменяем контекст, воркер сам допишет статус.
+	if ownerID, ok := b.active.lookup(key); ok {
+		if !b.canCancel(q.Message.Chat, q.From.ID, ownerID) {
+			b.sender.AnswerCallback(q.ID, "отменить может только тот, кто прислал ссылку, или админ 🙅")
+			return
+		}
+		if b.active.cancel(key) {
+			b.log.Info("download cancelled by user",
+				zap.Int64("chat_id", key.chatID),
+				zap.Int64("user_id", q.From.ID),
+			)
+			b.sender.AnswerCallback(q.ID, "отменяю")
+			return
+		}
+	}
+
+	// Иначе задача ещё в очереди (или ждёт повтора) — отменяем её в БД.
+	job, err := b.store.JobByStatusMessage(key.chatID, key.messageID)
+	if err != nil {
+		if !errors.Is(err, storage.ErrJobNotFound) {
+			b.log.Error("failed to find job for cancel", zap.Error(err))
+		}
+		b.sender.AnswerCallback(q.ID, "эта загрузка уже завершилась")
+		return
+	}
+	if !b.canCancel(q.Message.Chat, q.From.ID, job.UserID) {
+		b.sender.AnswerCallback(q.ID, "отменить может только тот, кто прислал ссылку, или админ 🙅")
+		return
+	}
+
+	ok, err := b.store.CancelPendingJob(job.ID)
+	if err != nil {
+		b.log.Error("failed to cancel pending job", zap.Error(err), zap.Uint("job_id", job.ID))
+		b.sender.AnswerCallback(q.ID, "не получилось отменить 😕")
+		return
+	}
+	if !ok {
+		b.sender.AnswerCallback(q.ID, "уже поздно — загрузка завершается")
+		return
+	}
+
+	b.log.Info("queued download cancelled by user",
+		zap.Uint("job_id", job.ID),
+		zap.Int64("user_id", q.From.ID),
+	)
+	b.sender.AnswerCallback(q.ID, "отменено")
+	b.sender.EditText(key.chatID, key.messageID, "✖️ отменено")
+}
+
+// canCancel — может ли userID отменить задачу ownerID в этом чате.
+func (b *Bot) canCancel(chat *tgbotapi.Chat, userID, ownerID int64) bool {
+	if userID == ownerID {
+		return true
+	}
+	if chat == nil || chat.IsPrivate() {
+		return false
+	}
+	return b.isChatAdmin(chat.ID, userID)
+}
+
+// isChatAdmin — админ или создатель группы chatID.
+func (b *Bot) isChatAdmin(chatID, userID int64) bool {
+	member, err := b.api.GetChatMember(tgbotapi.GetChatMemberConfig{
+		ChatConfigWithUser: tgbotapi.ChatConfigWithUser{ChatID: chatID, UserID: userID},
+	})
+	if err != nil {
+		b.log.Warn("failed to get chat member", zap.Error(err), zap.Int64("chat_id", chatID))
+		return false
+	}
+	return member.IsAdministrator() || member.IsCreator()
+}
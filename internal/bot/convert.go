@@ -0,0 +1,260 @@
+package bot
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+	"xa4yy_vidsave/internal/media"
+	"xa4yy_vidsave/internal/storage"
+
+	"go.uber.org/zap"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	animationCallbackPrefix = "gif:"
+	videoNoteCallbackPrefix = "note:"
+
+	// animationMaxDuration — «гифка» для реакций; длиннее обрезаем.
+	animationMaxDuration = 30 * time.Second
+
+	// telegramMaxGetFileSize — Bot API отдаёт через getFile файлы не больше 20 MB.
+	telegramMaxGetFileSize = 20 * 1024 * 1024
+)
+
+// conversion — производный формат видео, который кэшируется под ключом sourceKey+suffix.
+type conversion struct {
+	suffix   string // "#gif" → "tiktok:123#gif"
+	name     string // для логов
+	progress string // ответ на нажатие кнопки
+	failure  string // текст ошибки пользователю
+	convert  func(ctx context.Context, tools media.Tools, in, out string) error
+	message  func(chatID int64, replyTo int, file tgbotapi.RequestFileData) tgbotapi.Chattable
+	fileID   func(resp *tgbotapi.Message) (fileID, uniqueID string, ok bool)
+}
+
+var (
+	animationConversion = conversion{
+		suffix:   "#gif",
+		name:     "animation",
+		progress: "делаю гифку ⏳",
+		failure:  "не получилось сделать гифку 😕",
+		convert: func(ctx context.Context, tools media.Tools, in, out string) error {
+			return tools.Animation(ctx, in, out, animationMaxDuration)
+		},
+		message: func(chatID int64, replyTo int, file tgbotapi.RequestFileData) tgbotapi.Chattable {
+			msg := tgbotapi.NewAnimation(chatID, file)
+			setReply(&msg.BaseChat, replyTo)
+			return msg
+		},
+		fileID: func(resp *tgbotapi.Message) (string, string, bool) {
+			if resp.Animation != nil {
+				return resp.Animation.FileID, resp.Animation.FileUniqueID, true
+			}
+			// Telegram может распознать mp4 без звука как обычный документ
+			if resp.Document != nil {
+				return resp.Document.FileID, resp.Document.FileUniqueID, true
+			}
+			return "", "", false
+		},
+	}
+
+	videoNoteConversion = conversion{
+		suffix:   "#note",
+		name:     "video note",
+		progress: "делаю кружок ⏳",
+		failure:  "не получилось сделать кружок 😕",
+		convert: func(ctx context.Context, tools media.Tools, in, out string) error {
+			return tools.VideoNote(ctx, in, out)
+		},
+		message: func(chatID int64, replyTo int, file tgbotapi.RequestFileData) tgbotapi.Chattable {
+			msg := tgbotapi.NewVideoNote(chatID, media.VideoNoteSize, file)
+			setReply(&msg.BaseChat, replyTo)
+			return msg
+		},
+		fileID: func(resp *tgbotapi.Message) (string, string, bool) {
+			if resp.VideoNote != nil {
+				return resp.VideoNote.FileID, resp.VideoNote.FileUniqueID, true
+			}
+			return "", "", false
+		},
+	}
+)
+
+// handleConvert отвечает на кнопки «🎞 GIF» и «⭕ Кружок» под видео.
+func (b *Bot) handleConvert(ctx context.Context, q *tgbotapi.CallbackQuery, sourceKey string, c conversion) {
+	if q.Message == nil {
+		b.sender.AnswerCallback(q.ID, "это работает только в чате с ботом 🙏")
+		return
+	}
+	chatID := q.Message.Chat.ID
+	videoMessageID := q.Message.MessageID
+	derivedKey := sourceKey + c.suffix
+
+	// Уже конвертировали — по file_id
+	cached, err := b.store.Lookup(derivedKey)
+	if err == nil {
+		b.sender.AnswerCallback(q.ID, "")
+		msg := c.message(chatID, videoMessageID, tgbotapi.FileID(cached.TgFileID))
+		err := b.sender.Send(msg)
+		if err == nil {
+			b.hits.Add(derivedKey)
+			return
+		}
+		if !errors.Is(err, ErrInvalidFileID) {
+			b.log.Error("failed to send cached conversion", zap.Error(err), zap.String("source_key", derivedKey))
+			b.sender.TextReply(chatID, videoMessageID, c.failure)
+			return
+		}
+		// file_id умер — конвертируем заново
+		b.invalidateFileID(derivedKey, err)
+	} else {
+		if !errors.Is(err, storage.ErrNotFound) {
+			b.log.Error("cache lookup error", zap.Error(err))
+		}
+		b.sender.AnswerCallback(q.ID, c.progress)
+	}
+
+	if b.cfg.JobTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, b.cfg.JobTimeout)
+		defer cancel()
+	}
+
+	data, err := b.convertVideo(ctx, sourceKey, q.Message.Video, c)
+	if err != nil {
+		b.log.Error("conversion failed", zap.Error(err), zap.String("kind", c.name), zap.String("source_key", sourceKey))
+		b.sender.TextReply(chatID, videoMessageID, c.failure+errorContact)
+		return
+	}
+
+	hash := sha256.Sum256(data)
+	msg := c.message(chatID, videoMessageID, tgbotapi.FileBytes{Name: "clip.mp4", Bytes: data})
+	resp, err := b.sender.SendWithResponse(msg)
+	if err != nil || resp == nil {
+		b.log.Error("failed to send conversion", zap.Error(err), zap.String("kind", c.name))
+		b.sender.TextReply(chatID, videoMessageID, c.failure)
+		return
+	}
+
+	fileID, uniqueID, ok := c.fileID(resp)
+	if !ok {
+		return
+	}
+	entry := &storage.MediaCache{
+		SourceKey:      derivedKey,
+		SHA256:         hex.EncodeToString(hash[:]),
+		TgFileID:       fileID,
+		TgFileUniqueID: uniqueID,
+		SizeBytes:      int64(len(data)),
+	}
+	if err := b.store.Upsert(entry); err != nil {
+		b.log.Error("failed to save cache entry", zap.Error(err))
+	}
+	b.log.Info("conversion sent",
+		zap.String("kind", c.name),
+		zap.String("source_key", derivedKey),
+		zap.Int("size_bytes", len(data)),
+	)
+}
+
+// convertVideo получает исходное видео и прогоняет его через ffmpeg (в слоте скачивания).
+func (b *Bot) convertVideo(ctx context.Context, sourceKey string, video *tgbotapi.Video, c conversion) ([]byte, error) {
+	src, dir, err := b.sourceVideo(ctx, sourceKey, video)
+	if err != nil {
+		return nil, err
+	}
+	defer b.workDir.Remove(dir, c.name+" finished")
+
+	select {
+	case b.downloadSlots <- struct{}{}:
+		defer func() { <-b.downloadSlots }()
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	out := filepath.Join(dir, "converted.mp4")
+	if err := c.convert(ctx, b.media, src, out); err != nil {
+		return nil, err
+	}
+	return os.ReadFile(out)
+}
+
+// sourceVideo кладёт исходное видео во временную директорию: берёт его у Telegram
+// по file_id (если влезает в лимит getFile), иначе скачивает заново по source_key.
+// dir нужно удалить после использования.
+func (b *Bot) sourceVideo(ctx context.Context, sourceKey string, video *tgbotapi.Video) (path, dir string, err error) {
+	if video != nil && video.FileSize > 0 && video.FileSize <= telegramMaxGetFileSize {
+		path, dir, err := b.fetchTelegramFile(ctx, video.FileID)
+		if err == nil {
+			return path, dir, nil
+		}
+		if ctx.Err() != nil {
+			return "", "", ctx.Err()
+		}
+		b.log.Warn("failed to fetch video from telegram, downloading again", zap.Error(err))
+	}
+
+	parsed, ok := sourceFromKey(sourceKey)
+	if !ok {
+		return "", "", fmt.Errorf("unknown source key %q", sourceKey)
+	}
+	result, err := b.downloadVideoWithLimit(ctx, parsed)
+	if err != nil {
+		return "", "", fmt.Errorf("video download failed: %w", err)
+	}
+	v, ok := result.Video()
+	if !ok {
+		b.workDir.Remove(result.Dir, "no video artifact")
+		return "", "", fmt.Errorf("no video in download of %s", sourceKey)
+	}
+	return v.Path, result.Dir, nil
+}
+
+// fetchTelegramFile скачивает файл бота через getFile.
+func (b *Bot) fetchTelegramFile(ctx context.Context, fileID string) (path, dir string, err error) {
+	fileURL, err := b.api.GetFileDirectURL(fileID)
+	if err != nil {
+		return "", "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fileURL, nil)
+	if err != nil {
+		return "", "", errors.New("bad telegram file url")
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		// В ошибке URL с токеном бота — не логируем его
+		return "", "", errors.New("telegram file request failed")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", "", fmt.Errorf("telegram file status %d", resp.StatusCode)
+	}
+
+	dir, err = b.workDir.MkdirTemp()
+	if err != nil {
+		return "", "", err
+	}
+	path = filepath.Join(dir, "source.mp4")
+	f, err := os.Create(path)
+	if err == nil {
+		_, err = io.Copy(f, io.LimitReader(resp.Body, telegramMaxGetFileSize))
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if err != nil {
+		b.workDir.Remove(dir, "telegram file failed")
+		return "", "", err
+	}
+	return path, dir, nil
+}
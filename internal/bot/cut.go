@@ -0,0 +1,243 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"xa4yy_vidsave/internal/link"
+	"xa4yy_vidsave/internal/media"
+	"xa4yy_vidsave/internal/storage"
+
+	"go.uber.org/zap"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// cutMaxDuration — самый длинный кусок, который режем: дальше это уже не «момент»,
+// а перекодирование длинного видео надолго занимает слот.
+const cutMaxDuration = 2 * time.Minute
+
+const cutUsage = "✂️ как резать:\n\n" +
+	"ответь на видео командой /cut 0:12 0:27\n" +
+	"или /cut <ссылка> 0:12 0:27"
+
+var (
+	errCutUsage     = errors.New("cut usage")
+	errBadTimestamp = errors.New("bad timestamp")
+)
+
+// cutRequest — разобранные аргументы /cut.
+type cutRequest struct {
+	url        string // пусто — режем видео из сообщения, на которое ответили
+	start, end time.Duration
+}
+
+// parseCutArgs разбирает «[ссылка] начало конец».
+func parseCutArgs(args []string) (cutRequest, error) {
+	var req cutRequest
+	switch len(args) {
+	case 2:
+	case 3:
+		req.url, args = args[0], args[1:]
+	default:
+		return req, errCutUsage
+	}
+
+	var err error
+	if req.start, err = parseTimestamp(args[0]); err != nil {
+		return req, err
+	}
+	if req.end, err = parseTimestamp(args[1]); err != nil {
+		return req, err
+	}
+	return req, nil
+}
+
+// parseTimestamp понимает «27», «0:27», «1:02:03» и дробные секунды («12.5»).
+func parseTimestamp(s string) (time.Duration, error) {
+	parts := strings.Split(strings.ReplaceAll(s, ",", "."), ":")
+	if len(parts) > 3 {
+		return 0, errBadTimestamp
+	}
+
+	sec, err := strconv.ParseFloat(parts[len(parts)-1], 64)
+	if err != nil || sec < 0 || (len(parts) > 1 && sec >= 60) || strings.ContainsAny(parts[len(parts)-1], "eE+-") {
+		return 0, errBadTimestamp
+	}
+	total := sec
+	for i, mult := len(parts)-2, 60.0; i >= 0; i, mult = i-1, mult*60 {
+		n, err := strconv.Atoi(parts[i])
+		if err != nil || n < 0 || (i > 0 && n >= 60) {
+			return 0, errBadTimestamp
+		}
+		total += float64(n) * mult
+	}
+	return time.Duration(total * float64(time.Second)), nil
+}
+
+// sourceKeyFromMarkup достаёт source_key из кнопки «Поделиться» под видео бота.
+func sourceKeyFromMarkup(markup *tgbotapi.InlineKeyboardMarkup) string {
+	if markup == nil {
+		return ""
+	}
+	for _, row := range markup.InlineKeyboard {
+		for _, button := range row {
+			if button.SwitchInlineQuery != nil {
+				return *button.SwitchInlineQuery
+			}
+		}
+	}
+	return ""
+}
+
+// handleCut — /cut: вырезает кусок из видео бота (ответом на него) или по ссылке.
+func (b *Bot) handleCut(ctx context.Context, chatID int64, msg *tgbotapi.Message) {
+	req, err := parseCutArgs(strings.Fields(msg.CommandArguments()))
+	switch {
+	case errors.Is(err, errBadTimestamp):
+		b.sender.TextReply(chatID, msg.MessageID, "не понял время 🤔 пиши как 0:12 или 1:02:03")
+		return
+	case err != nil:
+		b.sender.TextReply(chatID, msg.MessageID, cutUsage)
+		return
+	}
+	if req.end <= req.start {
+		b.sender.TextReply(chatID, msg.MessageID, "конец должен быть позже начала 🙃")
+		return
+	}
+	if req.end-req.start > cutMaxDuration {
+		b.sender.TextReply(chatID, msg.MessageID,
+			fmt.Sprintf("максимум %s за раз — возьми кусок покороче ✂️", formatDuration(cutMaxDuration.Seconds())))
+		return
+	}
+
+	sourceKey, video, ok := b.cutSource(chatID, msg, req)
+	if !ok {
+		return
+	}
+	// Длительность видео бота известна заранее — не качаем зря
+	if video != nil && video.Duration > 0 && req.start.Seconds() >= float64(video.Duration) {
+		b.sender.TextReply(chatID, msg.MessageID, cutOutOfRangeText(float64(video.Duration)))
+		return
+	}
+
+	status := b.sender.TextWithResponseReply(chatID, msg.MessageID, "✂️ режу, сек")
+	if status != nil {
+		defer b.sender.Delete(chatID, status.MessageID)
+	}
+
+	if b.cfg.JobTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, b.cfg.JobTimeout)
+		defer cancel()
+	}
+
+	// Конец может оказаться дальше конца видео — конвертация прижмёт его к длительности
+	end := req.end
+	data, err := b.convertVideo(ctx, sourceKey, video, cutConversion(b.log, req.start, &end))
+	if err == nil && len(data) > telegramMaxFileSize {
+		err = &replyError{text: "кусок вышел больше 50 MB 😕 возьми покороче"}
+	}
+	if err != nil {
+		var re *replyError
+		if errors.As(err, &re) {
+			b.sender.TextReply(chatID, msg.MessageID, re.text)
+			return
+		}
+		b.log.Error("cut failed", zap.Error(err), zap.String("source_key", sourceKey))
+		b.sender.TextReply(chatID, msg.MessageID, "не получилось вырезать кусок 😕"+errorContact)
+		return
+	}
+
+	clip := tgbotapi.NewVideo(chatID, tgbotapi.FileBytes{Name: "cut.mp4", Bytes: data})
+	clip.Caption = strings.TrimSpace(fmt.Sprintf("✂️ %s–%s\n%s", formatDuration(req.start.Seconds()), formatDuration(end.Seconds()), captionFor(b.preferences(chatID))))
+	clip.Duration = int((end - req.start).Seconds())
+	clip.SupportsStreaming = true
+	setReply(&clip.BaseChat, msg.MessageID)
+	if err := b.sender.Send(clip); err != nil {
+		b.log.Error("failed to send cut", zap.Error(err))
+		b.sender.TextReply(chatID, msg.MessageID, "не удалось отправить видео 😢"+errorContact)
+		return
+	}
+	b.log.Info("cut sent",
+		zap.String("source_key", sourceKey),
+		zap.Duration("start", req.start),
+		zap.Duration("end", end),
+		zap.Int("size_bytes", len(data)),
+	)
+}
+
+// cutSource определяет, что резать: видео по ссылке (из кэша по file_id или скачать
+// заново) или видео из сообщения, на которое ответили. false — пользователю уже ответили.
+func (b *Bot) cutSource(chatID int64, msg *tgbotapi.Message, req cutRequest) (string, *tgbotapi.Video, bool) {
+	if req.url != "" {
+		parsed, err := link.Parse(req.url, b.cfg.AllowedHosts)
+		if err != nil {
+			b.handleParseError(chatID, req.url, err)
+			return "", nil, false
+		}
+		sourceKey := storage.SourceKeyFromParsed(string(parsed.LinkType), parsed.VideoID)
+		cached, err := b.store.Lookup(sourceKey)
+		if err != nil {
+			if !errors.Is(err, storage.ErrNotFound) {
+				b.log.Error("cache lookup error", zap.Error(err))
+			}
+			return sourceKey, nil, true
+		}
+		return sourceKey, &tgbotapi.Video{FileID: cached.TgFileID, FileSize: int(cached.SizeBytes)}, true
+	}
+
+	reply := msg.ReplyToMessage
+	if reply == nil || reply.Video == nil {
+		b.sender.TextReply(chatID, msg.MessageID, cutUsage)
+		return "", nil, false
+	}
+	// Чужое видео без нашей кнопки тоже режем, если Telegram отдаст его через getFile
+	sourceKey := sourceKeyFromMarkup(reply.ReplyMarkup)
+	if sourceKey == "" && reply.Video.FileSize > telegramMaxGetFileSize {
+		b.sender.TextReply(chatID, msg.MessageID, "видео больше 20 MB — пришли лучше ссылку на него: /cut <ссылка> 0:12 0:27")
+		return "", nil, false
+	}
+	return sourceKey, reply.Video, true
+}
+
+// cutConversion — разовая «конвертация» для /cut: сверяет границы с реальной
+// длительностью и режет без перекодирования, если они попадают в ключевые кадры.
+// Конец за пределами видео прижимается к его длительности прямо в *end.
+func cutConversion(log *zap.Logger, start time.Duration, end *time.Duration) conversion {
+	return conversion{
+		name: "cut",
+		convert: func(ctx context.Context, tools media.Tools, in, out string) error {
+			info, err := tools.Validate(ctx, in)
+			if err != nil {
+				return err
+			}
+			duration := info.Duration()
+			if start.Seconds() >= duration {
+				return &replyError{text: cutOutOfRangeText(duration)}
+			}
+			if end.Seconds() > duration {
+				*end = time.Duration(duration * float64(time.Second))
+			}
+
+			keyframes, err := tools.Keyframes(ctx, in)
+			if err != nil {
+				log.Warn("keyframes probe failed, re-encoding", zap.Error(err))
+			}
+			streamCopy := err == nil && media.OnKeyframes(keyframes, start.Seconds(), end.Seconds(), duration)
+			log.Info("cutting video",
+				zap.Duration("start", start),
+				zap.Duration("end", *end),
+				zap.Bool("stream_copy", streamCopy),
+			)
+			return tools.Cut(ctx, in, out, start, *end, streamCopy)
+		},
+	}
+}
+
+func cutOutOfRangeText(duration float64) string {
+	return fmt.Sprintf("видео длится %s — такого момента в нём нет 🤷", formatDuration(duration))
+}
@@ -0,0 +1,69 @@
+package bot
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseTimestamp(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    time.Duration
+		wantErr bool
+	}{
+		{in: "27", want: 27 * time.Second},
+		{in: "0:12", want: 12 * time.Second},
+		{in: "1:02:03", want: time.Hour + 2*time.Minute + 3*time.Second},
+		{in: "12.5", want: 12500 * time.Millisecond},
+		{in: "0:12,25", want: 12250 * time.Millisecond},
+		{in: "90", want: 90 * time.Second},
+		{in: "1:75", wantErr: true},
+		{in: "1:60:00", wantErr: true},
+		{in: "-3", wantErr: true},
+		{in: "1e2", wantErr: true},
+		{in: "0:0:0:1", wantErr: true},
+		{in: "abc", wantErr: true},
+		{in: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseTimestamp(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseTimestamp(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseTimestamp(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestParseCutArgs(t *testing.T) {
+	tests := []struct {
+		args    string
+		want    cutRequest
+		wantErr error
+	}{
+		{args: "0:12 0:27", want: cutRequest{start: 12 * time.Second, end: 27 * time.Second}},
+		{
+			args: "https://vm.tiktok.com/ZM123/ 5 10",
+			want: cutRequest{url: "https://vm.tiktok.com/ZM123/", start: 5 * time.Second, end: 10 * time.Second},
+		},
+		{args: "", wantErr: errCutUsage},
+		{args: "0:12", wantErr: errCutUsage},
+		{args: "0:12 потом", wantErr: errBadTimestamp},
+	}
+
+	for _, tt := range tests {
+		got, err := parseCutArgs(strings.Fields(tt.args))
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("parseCutArgs(%q) error = %v, want %v", tt.args, err, tt.wantErr)
+			continue
+		}
+		if err == nil && got != tt.want {
+			t.Errorf("parseCutArgs(%q) = %+v, want %+v", tt.args, got, tt.want)
+		}
+	}
+}
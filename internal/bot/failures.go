@@ -0,0 +1,92 @@
+package bot
+
+import (
+	"errors"
+	"time"
+	"xa4yy_vidsave/internal/download"
+	"xa4yy_vidsave/internal/media"
+	"xa4yy_vidsave/internal/storage"
+
+	"go.uber.org/zap"
+)
+
+// failureClasses — какие окончательные ошибки запоминать в негативном кэше и на сколько.
+// Удалённое видео не вернётся, приватное могут открыть, а лимит запросов снимется через минуты.
+// Сетевые ошибки, таймауты и наши собственные сбои не запоминаем: это не свойство ссылки.
+var failureClasses = []struct {
+	err   error
+	class string
+	ttl   time.Duration
+}{
+	{download.ErrYtDlpRemoved, "removed", 7 * 24 * time.Hour},
+	{download.ErrYtDlpUnsupported, "unsupported", 7 * 24 * time.Hour},
+	{download.ErrYtDlpTooLarge, "too_large", 7 * 24 * time.Hour},
+	{download.ErrYtDlpPrivate, "private", 24 * time.Hour},
+	{download.ErrYtDlpAgeRestricted, "age_restricted", 24 * time.Hour},
+	{download.ErrYtDlpGeoRestricted, "geo_restricted", 24 * time.Hour},
+	{download.ErrYtDlpAuth, "auth", 6 * time.Hour},
+	{media.ErrNoVideoStream, "no_video", 24 * time.Hour},
+	{download.ErrYtDlpLiveStream, "live_stream", 10 * time.Minute},
+	{download.ErrYtDlpRateLimited, "rate_limited", 2 * time.Minute},
+}
+
+// rejectedTTL — сколько помним отказ самого бота по свойствам видео (слишком длинное, нет видео).
+const rejectedTTL = 24 * time.Hour
+
+// failureClass возвращает класс ошибки и срок, на который её запомнить.
+// ok=false — ошибку не кэшируем.
+func failureClass(err error) (class string, ttl time.Duration, ok bool) {
+	var re *replyError
+	if errors.As(err, &re) {
+		// Запоминаем только отказы, зависящие от самого видео; остальные replyError —
+		// наши сбои (чтение, отправка), повтор может пройти
+		if re.reject != "" {
+			return re.reject, rejectedTTL, true
+		}
+		if re.err == nil {
+			return "", 0, false
+		}
+		err = re.err
+	}
+	for _, fc := range failureClasses {
+		if errors.Is(err, fc.err) {
+			return fc.class, fc.ttl, true
+		}
+	}
+	return "", 0, false
+}
+
+// failureTTL ограничивает срок ошибки авторизации остыванием кук:
+// после него бот попробует другой набор, и ссылка может скачаться.
+func failureTTL(class string, ttl, cookieCooldown time.Duration) time.Duration {
+	if class == "auth" {
+		return min(ttl, cookieCooldown)
+	}
+	return ttl
+}
+
+// rememberFailure запоминает окончательную ошибку ссылки, если она того стоит.
+func (b *Bot) rememberFailure(sourceKey string, err error, text string) {
+	class, ttl, ok := failureClass(err)
+	if !ok {
+		return
+	}
+	if ttl = failureTTL(class, ttl, b.cfg.CookieCooldown); ttl <= 0 {
+		return
+	}
+	entry := &storage.Failure{
+		SourceKey: sourceKey,
+		Class:     class,
+		Text:      text,
+		ExpiresAt: time.Now().Add(ttl),
+	}
+	if err := b.store.SaveFailure(entry); err != nil {
+		b.log.Error("failed to save failure cache entry", zap.Error(err), zap.String("source_key", sourceKey))
+		return
+	}
+	b.log.Info("failure cached",
+		zap.String("source_key", sourceKey),
+		zap.String("class", class),
+		zap.Duration("ttl", ttl),
+	)
+}
@@ -0,0 +1,54 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+	"xa4yy_vidsave/internal/download"
+)
+
+func TestFailureClass(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       error
+		wantClass string
+		wantTTL   time.Duration
+		wantOK    bool
+	}{
+		{"removed", fmt.Errorf("video download failed: %w", download.ErrYtDlpRemoved), "removed", 7 * 24 * time.Hour, true},
+		{"rate limited", download.ErrYtDlpRateLimited, "rate_limited", 2 * time.Minute, true},
+		{"too long", &replyError{text: "видео длится 12:00, а лимит 10:00 ⏱", reject: "too_long"}, "too_long", rejectedTTL, true},
+		{"no video", &replyError{text: "по ссылке нет видео — только фото или аудио 🖼", reject: "no_video"}, "no_video", rejectedTTL, true},
+		{"reply without reject kind", &replyError{text: "кусок вышел больше 50 MB 😕 возьми покороче"}, "", 0, false},
+		{"reply with classified cause", &replyError{text: "x", err: download.ErrYtDlpPrivate}, "private", 24 * time.Hour, true},
+		{"send failure", &replyError{text: "не удалось отправить видео 😢", err: errors.New("telegram 500")}, "", 0, false},
+		{"network", download.ErrYtDlpNetwork, "", 0, false},
+		{"timeout", context.DeadlineExceeded, "", 0, false},
+	}
+	for _, tt := range tests {
+		class, ttl, ok := failureClass(tt.err)
+		if class != tt.wantClass || ttl != tt.wantTTL || ok != tt.wantOK {
+			t.Errorf("%s: failureClass() = %q, %v, %v; want %q, %v, %v", tt.name, class, ttl, ok, tt.wantClass, tt.wantTTL, tt.wantOK)
+		}
+	}
+}
+
+func TestFailureTTL(t *testing.T) {
+	tests := []struct {
+		class         string
+		ttl, cooldown time.Duration
+		want          time.Duration
+	}{
+		{"auth", 6 * time.Hour, 30 * time.Minute, 30 * time.Minute},
+		{"auth", 6 * time.Hour, 0, 0},
+		{"auth", 6 * time.Hour, 12 * time.Hour, 6 * time.Hour},
+		{"removed", 7 * 24 * time.Hour, 30 * time.Minute, 7 * 24 * time.Hour},
+	}
+	for _, tt := range tests {
+		if got := failureTTL(tt.class, tt.ttl, tt.cooldown); got != tt.want {
+			t.Errorf("failureTTL(%q, %v, %v) = %v, want %v", tt.class, tt.ttl, tt.cooldown, got, tt.want)
+		}
+	}
+}
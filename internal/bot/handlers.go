@@ -7,11 +7,12 @@ import (
 	"errors"
 	"fmt"
 	"os"
-	"path/filepath"
 	"strings"
 	"time"
 	"xa4yy_vidsave/internal/download"
 	"xa4yy_vidsave/internal/link"
+	"xa4yy_vidsave/internal/media"
+	"xa4yy_vidsave/internal/proc"
 	"xa4yy_vidsave/internal/storage"
 
 	"go.uber.org/zap"
@@ -27,7 +28,7 @@ const (
 
 // --- Команды ---
 
-func (b *Bot) handleCommand(chatID int64, msg *tgbotapi.Message) {
+func (b *Bot) handleCommand(ctx context.Context, chatID int64, msg *tgbotapi.Message) {
 	switch msg.Command() {
 	case "start":
 		text := "Барев! 👋\n\n" +
@@ -46,14 +47,103 @@ func (b *Bot) handleCommand(chatID int64, msg *tgbotapi.Message) {
 			"📌 что умею:\n\n"+
 				"• TikTok — ссылка на видео\n"+
 				"• Instagram — ссылка на reel\n"+
-				"• в группах — отвечаю видео на первую ссылку в сообщении\n\n"+
+				"• в группах — отвечаю видео на первую ссылку в сообщении\n"+
+				"• 📝 субтитры — кнопка под видео из TikTok, на языке твоего Telegram\n"+
+				"• 🎞 гифка и ⭕ кружок — кнопки под любым видео\n"+
+				"• ✂️ /cut 0:12 0:27 — ответом на видео вырежу кусок (или /cut <ссылка> 0:12 0:27)\n"+
+				"• ⚙️ /settings — подпись под видео и отправка файлом без сжатия\n\n"+
 				"просто кидай ссылку 👇",
 		)
+	case "cut":
+		b.handleCut(ctx, chatID, msg)
+	case "settings":
+		b.handleSettings(chatID)
+	case "cookies":
+		if !b.isAdmin(msg.From) {
+			b.sender.Text(chatID, "хз такую команду 🤷‍♂️ жми /help")
+			return
+		}
+		b.sender.Text(chatID, cookieHealthText(b.cookies.Health()))
+	case "proxies":
+		if !b.isAdmin(msg.From) {
+			b.sender.Text(chatID, "хз такую команду 🤷‍♂️ жми /help")
+			return
+		}
+		b.sender.Text(chatID, proxyHealthText(b.proxies.Health()))
+	case "cache":
+		if !b.isAdmin(msg.From) {
+			b.sender.Text(chatID, "хз такую команду 🤷‍♂️ жми /help")
+			return
+		}
+		b.sender.Text(chatID, b.cacheStatsText())
 	default:
 		b.sender.Text(chatID, "хз такую команду 🤷‍♂️ жми /help")
 	}
 }
 
+// isAdmin — входит ли пользователь в ADMIN_IDS.
+func (b *Bot) isAdmin(user *tgbotapi.User) bool {
+	if user == nil {
+		return false
+	}
+	_, ok := b.cfg.AdminIDs[user.ID]
+	return ok
+}
+
+// cookieHealthText — отчёт для /cookies: только имена файлов и счётчики, без содержимого.
+func cookieHealthText(health []download.CookieHealth) string {
+	if len(health) == 0 {
+		return "🍪 cookie-файлы не настроены (COOKIES_DIR)"
+	}
+
+	var sb strings.Builder
+	sb.WriteString("🍪 cookies:\n")
+	for _, h := range health {
+		status := "✅"
+		if !h.Available {
+			status = "🧊 до " + h.CooldownUntil.Format("15:04")
+		}
+		fmt.Fprintf(&sb, "\n%s / %s — %s (ок: %d, отказов: %d)", h.Platform, h.Name, status, h.Successes, h.Failures)
+	}
+	return sb.String()
+}
+
+// proxyHealthText — отчёт для /proxies (адреса без логинов и паролей).
+func proxyHealthText(health []download.ProxyHealth) string {
+	if len(health) == 0 {
+		return "🌐 прокси не настроены (PROXIES / PROXY)"
+	}
+
+	var sb strings.Builder
+	sb.WriteString("🌐 прокси:\n")
+	for _, h := range health {
+		status := "✅"
+		if !h.Available {
+			status = "🚧 карантин до " + h.QuarantinedUntil.Format("15:04")
+		}
+		fmt.Fprintf(&sb, "\n%s / %s — %s (ок: %d, ошибок: %d)", h.Platform, h.Proxy, status, h.Successes, h.Failures)
+	}
+	return sb.String()
+}
+
+// cacheStatsText — отчёт для /cache: попадания в LRU перед базой и инвалидации file_id.
+func (b *Bot) cacheStatsText() string {
+	var sb strings.Builder
+	if lru, ok := b.store.(*storage.LRU); ok {
+		stats := lru.Stats()
+		hitRate := 0.0
+		if total := stats.Hits + stats.Misses; total > 0 {
+			hitRate = float64(stats.Hits) / float64(total) * 100
+		}
+		fmt.Fprintf(&sb, "🧠 кэш в памяти: %d записей\nпопаданий: %d, промахов: %d (%.1f%%), вытеснено: %d",
+			stats.Entries, stats.Hits, stats.Misses, hitRate, stats.Evictions)
+	} else {
+		sb.WriteString("🧠 кэш в памяти выключен (MEMORY_CACHE_ENTRIES=0)")
+	}
+	fmt.Fprintf(&sb, "\n\n♻️ file_id отвергнуто Telegram: %d", b.fileIDInvalidations.Load())
+	return sb.String()
+}
+
 // --- Ошибки парсинга ---
 
 func (b *Bot) handleParseError(chatID int64, text string, err error) {
@@ -76,188 +166,238 @@ func (b *Bot) handleParseError(chatID int64, text string, err error) {
 // Telegram Bot API лимит — 50 MB для отправки видео.
 const telegramMaxFileSize = 50 * 1024 * 1024
 
-func (b *Bot) handleDownload(ctx context.Context, chatID int64, replyToMessageID int, parsed link.Parsed) {
+// handleDownload отправляет видео из кэша, а при промахе ставит скачивание в очередь.
+func (b *Bot) handleDownload(chatID, userID int64, chatType string, replyToMessageID int, parsed link.Parsed) {
+	start := time.Now()
 	sourceKey := storage.SourceKeyFromParsed(string(parsed.LinkType), parsed.VideoID)
-	replyText := func(text string) {
-		b.sender.TextReply(chatID, replyToMessageID, text)
+	record := func(outcome storage.RequestOutcome, errorClass string, size int64, upload time.Duration) {
+		b.requests.Record(storage.Request{
+			UserID:     userID,
+			ChatID:     chatID,
+			ChatType:   chatType,
+			SourceKey:  sourceKey,
+			Outcome:    outcome,
+			ErrorClass: errorClass,
+			SizeBytes:  size,
+			TotalMs:    time.Since(start).Milliseconds(),
+			UploadMs:   upload.Milliseconds(),
+			CreatedAt:  start,
+		})
 	}
 
-	// 1. Проверяем кэш по source_key
-	cached, err := b.store.Lookup(sourceKey)
+	// 1. Проверяем кэш по source_key (в виде, который выбран в настройках чата)
+	prefs := b.preferences(chatID)
+	cacheKey := deliveryKey(sourceKey, prefs)
+	cached, err := b.store.Lookup(cacheKey)
 	if err == nil {
 		// Кэш-хит — отправляем по file_id мгновенно
-		b.log.Info("cache hit",
-			zap.String("source_key", sourceKey),
-			zap.Int64("hit_count", cached.HitCount+1),
-		)
-		kb := shareKeyboard(sourceKey)
-		video := tgbotapi.NewVideo(chatID, tgbotapi.FileID(cached.TgFileID))
-		video.Caption = videoCaption
-		video.SupportsStreaming = true
-		video.ReplyMarkup = kb
-		setReply(&video.BaseChat, replyToMessageID)
-		if err := b.sender.Send(video); err != nil {
+		b.log.Info("cache hit", zap.String("source_key", cacheKey))
+		sendStart := time.Now()
+		err := b.sender.Send(videoMessage(chatID, replyToMessageID, tgbotapi.FileID(cached.TgFileID), sourceKey, 0, prefs))
+		if err == nil {
+			b.hits.Add(cacheKey)
+			record(storage.OutcomeCacheHit, "", cached.SizeBytes, time.Since(sendStart))
+			return
+		}
+		if !errors.Is(err, ErrInvalidFileID) {
 			b.log.Error("failed to send cached video", zap.Error(err))
-			replyText("не удалось отправить видео 😢")
+			b.sender.TextReply(chatID, replyToMessageID, "не удалось отправить видео 😢")
+			record(storage.OutcomeFailed, "send", 0, time.Since(sendStart))
+			return
 		}
+		// file_id умер — забываем его и качаем заново, как будто кэша не было
+		b.invalidateFileID(cacheKey, err)
+	} else if !errors.Is(err, storage.ErrNotFound) {
+		b.log.Error("cache lookup error", zap.Error(err))
+	}
+
+	// 2. Ссылка недавно окончательно не скачалась — отвечаем тем же, не занимая очередь
+	if failure, err := b.store.LookupFailure(sourceKey); err == nil {
+		b.log.Info("failure cache hit",
+			zap.String("source_key", sourceKey),
+			zap.String("class", failure.Class),
+			zap.Time("expires_at", failure.ExpiresAt),
+		)
+		b.sender.TextReply(chatID, replyToMessageID, failure.Text)
+		record(storage.OutcomeKnownFailure, failure.Class, 0, 0)
 		return
+	} else if !errors.Is(err, storage.ErrNotFound) {
+		b.log.Error("failure cache lookup error", zap.Error(err))
 	}
-	if !errors.Is(err, storage.ErrNotFound) {
-		b.log.Error("cache lookup error", zap.Error(err))
+
+	// 3. Кэш-мисс — скачиванием займётся воркер очереди
+	b.enqueueDownload(chatID, userID, chatType, replyToMessageID, parsed, sourceKey)
+}
+
+// deliver скачивает видео задачи, отправляет его в Telegram и сохраняет file_id в кэш.
+// Ошибки, которые нужно показать пользователю как есть, возвращаются как *replyError.
+// В stats записывается, сколько занял каждый этап и чем всё кончилось.
+func (b *Bot) deliver(ctx context.Context, job *storage.Job, parsed link.Parsed, stats *deliveryStats) error {
+	chatID := job.ChatID
+	replyToMessageID := job.ReplyToMessageID
+	sourceKey := job.SourceKey
+
+	// 1. Быстрая проверка метаданных — отказываем до того, как занять слот скачивания
+	stageStart := time.Now()
+	err := b.checkMetadata(ctx, parsed)
+	stats.probe = time.Since(stageStart)
+	if err != nil {
+		return err
 	}
 
-	// 2. Кэш-мисс — скачиваем
-	statusMsg := b.sender.TextWithResponseReply(chatID, replyToMessageID, "⏳ сек, качаю")
-
-	// Анимация загрузки в фоне
-	stopAnim := make(chan struct{})
-	if statusMsg != nil {
-		go func() {
-			frames := []string{"⏳ сек, качаю.", "⏳ сек, качаю..", "⏳ сек, качаю...", "⏳ сек, качаю"}
-			i := 0
-			ticker := time.NewTicker(800 * time.Millisecond)
-			defer ticker.Stop()
-			for {
-				select {
-				case <-stopAnim:
-					return
-				case <-ticker.C:
-					b.sender.EditText(chatID, statusMsg.MessageID, frames[i%len(frames)])
-					i++
-				}
-			}
-		}()
+	// 2. Скачиваем
+	stageStart = time.Now()
+	result, err := b.downloadVideoWithLimit(ctx, parsed)
+	stats.download = time.Since(stageStart)
+	if err != nil {
+		return fmt.Errorf("video download failed: %w", err)
 	}
+	defer b.workDir.Remove(result.Dir, "job finished")
 
-	// Удаляем статус-сообщение при выходе
-	defer func() {
-		close(stopAnim)
-		if statusMsg != nil {
-			b.sender.Delete(chatID, statusMsg.MessageID)
-		}
-	}()
+	stageStart = time.Now()
+
+	// В директории бывают миниатюры, субтитры и .info.json — берём именно видео
+	videoFile, ok := result.Video()
+	if !ok {
+		return &replyError{text: "по ссылке нет видео — только фото или аудио 🖼", reject: "no_video"}
+	}
 
-	result, err := b.downloadVideoWithLimit(ctx, parsed.Raw)
+	// 3. Проверяем, что скачалось настоящее видео, и готовим mp4 к стримингу
+	mediaInfo, err := b.prepareVideo(ctx, videoFile.Path)
 	if err != nil {
-		b.log.Error("video download failed", zap.Error(err), zap.String("url", parsed.Raw))
-
-		switch {
-		case errors.Is(err, download.ErrYtDlpAuth):
-			replyText("эта ссылка требует вход в аккаунт и в публичном режиме не скачивается 😕\nпопробуй другую публичную ссылку" + errorContact)
-		case errors.Is(err, download.ErrYtDlpUnsupported):
-			replyText("эта ссылка ведёт не на видео или yt-dlp не умеет её скачивать 😕\nпопробуй другую ссылку" + errorContact)
-		default:
-			replyText("не удалось скачать видео 😕\nпопробуй позже" + errorContact)
-		}
-		return
+		return err
 	}
-	defer cleanup(result.FilePath, b.log)
 
-	// 3. Проверяем размер
-	info, err := os.Stat(result.FilePath)
+	// 4. Проверяем фактический размер
+	info, err := os.Stat(videoFile.Path)
 	if err != nil {
-		b.log.Error("failed to stat downloaded file", zap.Error(err))
-		replyText("ошибка чтения файла 😕" + errorContact)
-		return
+		return &replyError{text: "ошибка чтения файла 😕" + errorContact, err: err}
 	}
 
 	fileSize := info.Size()
 
 	if fileSize > b.cfg.MaxDownloadBytes {
-		replyText(fmt.Sprintf(
+		return &replyError{text: fmt.Sprintf(
 			"видео слишком большое (%d МБ), лимит %d МБ 😬",
 			fileSize/(1024*1024), b.cfg.MaxDownloadBytes/(1024*1024),
-		))
-		return
+		), reject: "too_large"}
 	}
 
 	if fileSize > telegramMaxFileSize {
-		replyText(fmt.Sprintf(
+		return &replyError{text: fmt.Sprintf(
 			"видео слишком большое для Telegram (%d МБ), лимит 50 МБ 😬",
 			fileSize/(1024*1024),
-		))
-		return
+		), reject: "too_large"}
 	}
 
-	// 4. Читаем файл и считаем SHA256
-	fileData, err := os.ReadFile(result.FilePath)
+	// 5. Читаем файл и считаем SHA256
+	fileData, err := os.ReadFile(videoFile.Path)
 	if err != nil {
-		b.log.Error("failed to read downloaded file", zap.Error(err))
-		replyText("ошибка чтения файла 😕" + errorContact)
-		return
+		return &replyError{text: "ошибка чтения файла 😕" + errorContact, err: err}
 	}
 
 	hash := sha256.Sum256(fileData)
 	hashHex := hex.EncodeToString(hash[:])
+	stats.process = time.Since(stageStart)
+	stats.size = fileSize
 
-	// 5. Проверяем дедупликацию по SHA256 — может тот же файл уже был по другой ссылке
-	if dedup, err := b.store.LookupBySHA256(hashHex); err == nil {
+	// 6. Проверяем дедупликацию по SHA256 — может тот же файл уже был по другой ссылке.
+	// В кэше по хэшу лежат только видео, поэтому для отправки файлом дедупликации нет.
+	prefs := b.preferences(chatID)
+	if dedup, err := b.store.LookupBySHA256(hashHex); err == nil && !prefs.SendAsDocument {
 		b.log.Info("dedup hit by sha256",
 			zap.String("sha256", hashHex),
 			zap.String("existing_key", dedup.SourceKey),
 		)
-		kb := shareKeyboard(sourceKey)
-		video := tgbotapi.NewVideo(chatID, tgbotapi.FileID(dedup.TgFileID))
-		video.Caption = videoCaption
-		video.SupportsStreaming = true
-		video.ReplyMarkup = kb
-		setReply(&video.BaseChat, replyToMessageID)
-		if err := b.sender.Send(video); err == nil {
+		stageStart = time.Now()
+		err := b.sender.Send(videoMessage(chatID, replyToMessageID, tgbotapi.FileID(dedup.TgFileID), sourceKey, 0, prefs))
+		stats.upload = time.Since(stageStart)
+		if err == nil {
+			stats.outcome = storage.OutcomeDedupHit
+			b.hits.Add(dedup.SourceKey)
 			// Сохраняем новый source_key с тем же file_id
-			_ = b.store.Upsert(&storage.MediaCache{
+			err := b.store.Upsert(&storage.MediaCache{
 				SourceKey:      sourceKey,
 				SHA256:         hashHex,
 				TgFileID:       dedup.TgFileID,
 				TgFileUniqueID: dedup.TgFileUniqueID,
 				SizeBytes:      fileSize,
 			})
-			return
+			if err != nil {
+				b.log.Error("failed to save cache entry", zap.Error(err), zap.String("source_key", sourceKey))
+			}
+			return nil
+		}
+		if errors.Is(err, ErrInvalidFileID) {
+			b.invalidateFileID(dedup.SourceKey, err)
+		} else {
+			b.log.Warn("dedup send failed, uploading fresh", zap.Error(err))
 		}
-		b.log.Warn("dedup send failed, uploading fresh", zap.Error(err))
 	}
 
-	// 6. Отправляем файл в Telegram
-	kb := shareKeyboard(sourceKey)
+	// 7. Отправляем файл в Telegram
 	fileBytes := tgbotapi.FileBytes{Name: parsed.VideoID + ".mp4", Bytes: fileData}
-	video := tgbotapi.NewVideo(chatID, fileBytes)
-	video.Caption = videoCaption
-	video.SupportsStreaming = true
-	video.ReplyMarkup = kb
-	setReply(&video.BaseChat, replyToMessageID)
-
-	resp, sendErr := b.sender.SendWithResponse(video)
+	stageStart = time.Now()
+	resp, sendErr := b.sender.SendWithResponse(videoMessage(chatID, replyToMessageID, fileBytes, sourceKey, int(mediaInfo.Duration()), prefs))
+	stats.upload += time.Since(stageStart)
 	if sendErr != nil {
-		b.log.Error("failed to send video to telegram", zap.Error(sendErr))
-		replyText("не удалось отправить видео 😢" + errorContact)
-		return
+		return &replyError{text: "не удалось отправить видео 😢" + errorContact, err: sendErr}
 	}
 
-	// 7. Извлекаем file_id из ответа Telegram и сохраняем в кэш
-	if resp.Video != nil {
-		entry := &storage.MediaCache{
+	// 8. Извлекаем file_id из ответа Telegram и сохраняем в кэш
+	var entry *storage.MediaCache
+	switch {
+	case resp.Video != nil:
+		entry = &storage.MediaCache{
 			SourceKey:      sourceKey,
 			SHA256:         hashHex,
 			TgFileID:       resp.Video.FileID,
 			TgFileUniqueID: resp.Video.FileUniqueID,
 			SizeBytes:      fileSize,
 		}
+	case resp.Document != nil:
+		// Без хэша: дедупликация по SHA256 должна находить только видео
+		entry = &storage.MediaCache{
+			SourceKey:      sourceKey + documentSuffix,
+			TgFileID:       resp.Document.FileID,
+			TgFileUniqueID: resp.Document.FileUniqueID,
+			SizeBytes:      fileSize,
+		}
+	}
+	if entry != nil {
 		if err := b.store.Upsert(entry); err != nil {
 			b.log.Error("failed to save cache entry", zap.Error(err))
 		} else {
 			b.log.Info("cached video",
-				zap.String("source_key", sourceKey),
-				zap.String("file_id", resp.Video.FileID),
+				zap.String("source_key", entry.SourceKey),
+				zap.String("file_id", entry.TgFileID),
 			)
 		}
 	}
 
+	stats.outcome = storage.OutcomeDownloaded
 	b.log.Info("video sent successfully",
 		zap.String("video_id", parsed.VideoID),
 		zap.Int64("size_bytes", fileSize),
 	)
+	return nil
+}
+
+// invalidateFileID убирает из кэша запись, чей file_id Telegram больше не принимает.
+// Следующий запрос той же ссылки скачает видео заново и сохранит свежий file_id.
+func (b *Bot) invalidateFileID(sourceKey string, cause error) {
+	if err := b.store.Invalidate(sourceKey); err != nil {
+		b.log.Error("failed to invalidate cache entry", zap.Error(err), zap.String("source_key", sourceKey))
+		return
+	}
+	b.log.Warn("cached file_id rejected by telegram, entry invalidated",
+		zap.String("source_key", sourceKey),
+		zap.Error(cause),
+		zap.Int64("invalidations_total", b.fileIDInvalidations.Add(1)),
+	)
 }
 
-func (b *Bot) downloadVideoWithLimit(ctx context.Context, rawURL string) (*download.VideoResult, error) {
+func (b *Bot) downloadVideoWithLimit(ctx context.Context, parsed link.Parsed) (*download.VideoResult, error) {
 	select {
 	case b.downloadSlots <- struct{}{}:
 		defer func() { <-b.downloadSlots }()
@@ -265,7 +405,103 @@ func (b *Bot) downloadVideoWithLimit(ctx context.Context, rawURL string) (*downl
 		return nil, ctx.Err()
 	}
 
-	return download.DownloadVideo(ctx, rawURL, b.cfg.Proxy, b.log)
+	return download.DownloadVideo(ctx, parsed.Raw, b.downloadOptions(parsed), b.log)
+}
+
+// prepareVideo проверяет скачанный файл через ffprobe (контейнер читается, есть видеопоток,
+// длительность не нулевая) и при необходимости переносит moov atom в начало (+faststart).
+func (b *Bot) prepareVideo(ctx context.Context, path string) (*media.Info, error) {
+	info, err := b.media.Validate(ctx, path)
+	if err != nil {
+		return nil, fmt.Errorf("downloaded file rejected: %w", err)
+	}
+	if !info.IsMP4() {
+		return info, nil
+	}
+
+	needsFaststart, err := media.NeedsFaststart(path)
+	if err != nil {
+		b.log.Warn("failed to inspect mp4 layout", zap.Error(err), zap.String("path", path))
+		return info, nil
+	}
+	if !needsFaststart {
+		return info, nil
+	}
+
+	// Ремукс без перекодирования; если не вышло — отправляем как есть.
+	if err := b.media.Faststart(ctx, path); err != nil {
+		b.log.Warn("faststart remux failed, sending as is", zap.Error(err), zap.String("path", path))
+		return info, nil
+	}
+	b.log.Info("video remuxed with faststart", zap.String("path", path))
+	return info, nil
+}
+
+// checkMetadata проверяет длительность, размер, эфир и доступность видео без скачивания.
+// Если сама проверка не удалась по временной причине, не мешаем скачиванию — это лишь оптимизация.
+func (b *Bot) checkMetadata(ctx context.Context, parsed link.Parsed) error {
+	meta, err := download.ProbeVideo(ctx, parsed.Raw, b.downloadOptions(parsed), b.log)
+	if err != nil {
+		if ctx.Err() != nil || !download.IsRetryable(err) {
+			return fmt.Errorf("video probe failed: %w", err)
+		}
+		b.log.Warn("video probe failed, downloading anyway", zap.Error(err), zap.String("url", parsed.Raw))
+		return nil
+	}
+
+	if err := meta.Err(); err != nil {
+		return fmt.Errorf("video rejected by probe: %w", err)
+	}
+
+	if b.cfg.MaxVideoDuration > 0 && meta.Duration > b.cfg.MaxVideoDuration.Seconds() {
+		return &replyError{text: fmt.Sprintf(
+			"видео длится %s, а лимит %s ⏱",
+			formatDuration(meta.Duration), formatDuration(b.cfg.MaxVideoDuration.Seconds()),
+		), reject: "too_long"}
+	}
+
+	size := meta.EstimatedSize()
+	if size > b.cfg.MaxDownloadBytes {
+		return &replyError{text: fmt.Sprintf(
+			"видео слишком большое (~%d МБ), лимит %d МБ 😬",
+			size/(1024*1024), b.cfg.MaxDownloadBytes/(1024*1024),
+		), reject: "too_large"}
+	}
+	if size > telegramMaxFileSize {
+		return &replyError{text: fmt.Sprintf(
+			"видео слишком большое для Telegram (~%d МБ), лимит 50 МБ 😬",
+			size/(1024*1024),
+		), reject: "too_large"}
+	}
+	return nil
+}
+
+// downloadOptions — настройки yt-dlp для ссылки.
+func (b *Bot) downloadOptions(parsed link.Parsed) download.Options {
+	return download.Options{
+		Proxies:  b.proxies,
+		WorkDir:  b.workDir,
+		Platform: string(parsed.LinkType),
+		Cookies:  b.cookies,
+		Native:   b.native[parsed.LinkType],
+		// Больше лимита всё равно не отправим — не качаем лишнее
+		MaxFilesize: min(b.cfg.MaxDownloadBytes, telegramMaxFileSize),
+		Limits: proc.Limits{
+			CPUTime:   b.cfg.YtDlpCPUTime,
+			Memory:    b.cfg.YtDlpMemoryBytes,
+			MaxOutput: b.cfg.YtDlpMaxOutputBytes,
+		},
+	}
+}
+
+// formatDuration форматирует секунды как «м:сс» или «ч:мм:сс».
+func formatDuration(seconds float64) string {
+	total := int(seconds)
+	h, m, sec := total/3600, total%3600/60, total%60
+	if h > 0 {
+		return fmt.Sprintf("%d:%02d:%02d", h, m, sec)
+	}
+	return fmt.Sprintf("%d:%02d", m, sec)
 }
 
 // --- Inline ---
@@ -282,6 +518,50 @@ func shareKeyboard(sourceKey string) tgbotapi.InlineKeyboardMarkup {
 	)
 }
 
+// videoKeyboard — клавиатура под видео в чате: «Поделиться» и инструменты
+// (субтитры там, где они бывают, «гифка» и кружок).
+// Inline-результаты получают только shareKeyboard: у них нет чата, куда ответить.
+func videoKeyboard(sourceKey string) tgbotapi.InlineKeyboardMarkup {
+	kb := shareKeyboard(sourceKey)
+
+	var tools []tgbotapi.InlineKeyboardButton
+	addTool := func(text, prefix string) {
+		if data := prefix + sourceKey; len(data) <= maxCallbackData {
+			tools = append(tools, tgbotapi.NewInlineKeyboardButtonData(text, data))
+		}
+	}
+	if parsed, ok := sourceFromKey(sourceKey); ok && subtitlesSupported[parsed.LinkType] {
+		addTool("📝 Субтитры", subtitlesCallbackPrefix)
+	}
+	addTool("🎞 GIF", animationCallbackPrefix)
+	addTool("⭕ Кружок", videoNoteCallbackPrefix)
+
+	if len(tools) > 0 {
+		kb.InlineKeyboard = append(kb.InlineKeyboard, tools)
+	}
+	return kb
+}
+
+// maxCallbackData — лимит Telegram на callback_data в байтах.
+const maxCallbackData = 64
+
+// sourceFromKey восстанавливает ссылку на видео по source_key ("tiktok:123").
+func sourceFromKey(sourceKey string) (link.Parsed, bool) {
+	linkType, videoID, ok := strings.Cut(sourceKey, ":")
+	if !ok {
+		return link.Parsed{}, false
+	}
+	rawURL := link.CanonicalURL(link.Type(linkType), videoID)
+	if rawURL == "" {
+		return link.Parsed{}, false
+	}
+	parsed, err := link.Parse(rawURL, nil)
+	if err != nil {
+		return link.Parsed{}, false
+	}
+	return parsed, true
+}
+
 // handleInlineQuery обрабатывает inline-запросы для кнопки «Поделиться».
 func (b *Bot) handleInlineQuery(q *tgbotapi.InlineQuery) {
 	text := strings.TrimSpace(q.Query)
@@ -289,7 +569,12 @@ func (b *Bot) handleInlineQuery(q *tgbotapi.InlineQuery) {
 		return
 	}
 
+	// Превью — ещё не отправка: hit_count засчитывается по ChosenInlineResult.
+	// Производные ключи ("tiktok:1#gif") — не видео, их inline не отдаём
 	cached, err := b.store.Lookup(text)
+	if err == nil && strings.Contains(text, "#") {
+		err = storage.ErrNotFound
+	}
 	if err != nil {
 		b.log.Debug("inline query: cache miss", zap.String("query", text))
 		empty := tgbotapi.InlineConfig{InlineQueryID: q.ID, Results: []interface{}{}}
@@ -312,14 +597,3 @@ func (b *Bot) handleInlineQuery(q *tgbotapi.InlineQuery) {
 		b.log.Error("inline query failed", zap.Error(err))
 	}
 }
-
-// cleanup удаляет скачанный файл и его родительскую tmp-директорию.
-func cleanup(filePath string, log *zap.Logger) {
-	if filePath == "" {
-		return
-	}
-	dir := filepath.Dir(filePath)
-	if err := os.RemoveAll(dir); err != nil {
-		log.Warn("failed to cleanup tmp dir", zap.Error(err), zap.String("dir", dir))
-	}
-}
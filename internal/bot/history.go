@@ -0,0 +1,135 @@
+package bot
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+	"xa4yy_vidsave/internal/storage"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// requestLogBuffer — сколько записей истории ждут записи; сверх этого новые теряются,
+	// чтобы медленная база не тормозила отправку видео.
+	requestLogBuffer = 1024
+	// requestLogBatch — сколько записей пишем одним INSERT.
+	requestLogBatch = 100
+	// requestLogFlush — как часто сбрасываем неполную пачку.
+	requestLogFlush = 2 * time.Second
+)
+
+// requestLog пишет историю запросов в фоне пачками.
+type requestLog struct {
+	store   storage.Store
+	log     *zap.Logger
+	entries chan storage.Request
+	dropped atomic.Int64
+}
+
+func newRequestLog(store storage.Store, log *zap.Logger) *requestLog {
+	return &requestLog{
+		store:   store,
+		log:     log,
+		entries: make(chan storage.Request, requestLogBuffer),
+	}
+}
+
+// Record ставит запись в очередь на запись и никогда не блокируется.
+func (l *requestLog) Record(entry storage.Request) {
+	select {
+	case l.entries <- entry:
+	default:
+		l.log.Warn("request log buffer is full, entry dropped",
+			zap.String("source_key", entry.SourceKey),
+			zap.Int64("dropped_total", l.dropped.Add(1)),
+		)
+	}
+}
+
+// Run пишет накопленные записи каждые requestLogFlush или по заполнении пачки.
+// После отмены ctx дописывает то, что уже в буфере, и возвращается.
+func (l *requestLog) Run(ctx context.Context) {
+	ticker := time.NewTicker(requestLogFlush)
+	defer ticker.Stop()
+
+	batch := make([]storage.Request, 0, requestLogBatch)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := l.store.SaveRequests(batch); err != nil {
+			l.log.Error("failed to save request log", zap.Error(err), zap.Int("entries", len(batch)))
+		}
+		batch = batch[:0]
+	}
+
+	for {
+		select {
+		case entry := <-l.entries:
+			batch = append(batch, entry)
+			if len(batch) >= requestLogBatch {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case <-ctx.Done():
+			for {
+				select {
+				case entry := <-l.entries:
+					batch = append(batch, entry)
+					if len(batch) >= requestLogBatch {
+						flush()
+					}
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
+
+// deliveryStats — из чего сложилось время доставки видео задачи.
+type deliveryStats struct {
+	outcome  storage.RequestOutcome
+	size     int64
+	probe    time.Duration
+	download time.Duration
+	process  time.Duration
+	upload   time.Duration
+}
+
+// recordJob пишет в историю итог задачи. err — финальная ошибка (nil для успеха и отмены).
+func (b *Bot) recordJob(job *storage.Job, stats *deliveryStats, outcome storage.RequestOutcome, err error) {
+	entry := storage.Request{
+		UserID:     job.UserID,
+		ChatID:     job.ChatID,
+		ChatType:   job.ChatType,
+		SourceKey:  job.SourceKey,
+		Outcome:    outcome,
+		SizeBytes:  stats.size,
+		Attempts:   job.Attempts,
+		TotalMs:    time.Since(job.CreatedAt).Milliseconds(),
+		ProbeMs:    stats.probe.Milliseconds(),
+		DownloadMs: stats.download.Milliseconds(),
+		ProcessMs:  stats.process.Milliseconds(),
+		UploadMs:   stats.upload.Milliseconds(),
+		CreatedAt:  job.CreatedAt,
+	}
+	if job.StartedAt != nil {
+		entry.QueueMs = job.StartedAt.Sub(job.CreatedAt).Milliseconds()
+	}
+	if err != nil {
+		entry.ErrorClass = errorClass(err)
+	}
+	b.requests.Record(entry)
+}
+
+// errorClass — класс ошибки для истории: как в негативном кэше, иначе "other".
+func errorClass(err error) string {
+	if class, _, ok := failureClass(err); ok {
+		return class
+	}
+	return "other"
+}
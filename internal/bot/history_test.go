@@ -0,0 +1,59 @@
+package bot
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"xa4yy_vidsave/internal/storage"
+
+	"go.uber.org/zap"
+)
+
+// requestSink — Store, который только собирает записи истории.
+type requestSink struct {
+	storage.Store
+	mu      sync.Mutex
+	batches [][]storage.Request
+}
+
+func (s *requestSink) SaveRequests(entries []storage.Request) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.batches = append(s.batches, append([]storage.Request(nil), entries...))
+	return nil
+}
+
+func TestRequestLogFlushesOnShutdown(t *testing.T) {
+	sink := &requestSink{}
+	l := newRequestLog(sink, zap.NewNop())
+
+	total := requestLogBatch + 5
+	for i := 0; i < total; i++ {
+		l.Record(storage.Request{SourceKey: "tiktok:1", Outcome: storage.OutcomeCacheHit})
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	l.Run(ctx)
+
+	saved := 0
+	for _, batch := range sink.batches {
+		if len(batch) > requestLogBatch {
+			t.Errorf("batch of %d entries, want at most %d", len(batch), requestLogBatch)
+		}
+		saved += len(batch)
+	}
+	if saved != total {
+		t.Fatalf("saved %d entries, want %d", saved, total)
+	}
+}
+
+func TestRequestLogDropsWhenFull(t *testing.T) {
+	l := newRequestLog(&requestSink{}, zap.NewNop())
+	for i := 0; i < requestLogBuffer+3; i++ {
+		l.Record(storage.Request{})
+	}
+	if got := l.dropped.Load(); got != 3 {
+		t.Fatalf("dropped = %d, want 3", got)
+	}
+}
@@ -0,0 +1,82 @@
+package bot
+
+import (
+	"context"
+	"sync"
+	"time"
+	"xa4yy_vidsave/internal/storage"
+
+	"go.uber.org/zap"
+)
+
+// hitFlushInterval — как часто пишем накопленные отправки из кэша в базу.
+const hitFlushInterval = 10 * time.Second
+
+// hitCounter копит отправки из кэша в памяти и пишет их в hit_count пачками:
+// популярное видео даёт один UPDATE за интервал, а не по одному на каждую отправку.
+type hitCounter struct {
+	store storage.Store
+	log   *zap.Logger
+
+	mu      sync.Mutex
+	pending map[string]*storage.CacheHits
+}
+
+func newHitCounter(store storage.Store, log *zap.Logger) *hitCounter {
+	return &hitCounter{
+		store:   store,
+		log:     log,
+		pending: make(map[string]*storage.CacheHits),
+	}
+}
+
+// Add засчитывает одну реальную отправку file_id из записи sourceKey.
+func (c *hitCounter) Add(sourceKey string) {
+	now := time.Now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	hit, ok := c.pending[sourceKey]
+	if !ok {
+		hit = &storage.CacheHits{SourceKey: sourceKey}
+		c.pending[sourceKey] = hit
+	}
+	hit.Count++
+	hit.LastUsedAt = now
+}
+
+// Flush пишет накопленное одним вызовом RecordHits. При ошибке счётчики теряются —
+// это статистика, и копить её бесконечно при лежащей базе не стоит.
+func (c *hitCounter) Flush() {
+	c.mu.Lock()
+	if len(c.pending) == 0 {
+		c.mu.Unlock()
+		return
+	}
+	hits := make([]storage.CacheHits, 0, len(c.pending))
+	for _, hit := range c.pending {
+		hits = append(hits, *hit)
+	}
+	c.pending = make(map[string]*storage.CacheHits)
+	c.mu.Unlock()
+
+	if err := c.store.RecordHits(hits); err != nil {
+		c.log.Error("failed to record cache hits", zap.Error(err), zap.Int("entries", len(hits)))
+	}
+}
+
+// Run сбрасывает счётчики каждые hitFlushInterval и последний раз после отмены ctx.
+func (c *hitCounter) Run(ctx context.Context) {
+	ticker := time.NewTicker(hitFlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			c.Flush()
+		case <-ctx.Done():
+			c.Flush()
+			return
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package bot
+
+import (
+	"context"
+	"testing"
+	"xa4yy_vidsave/internal/storage"
+
+	"go.uber.org/zap"
+)
+
+// hitSink — Store, который только собирает сброшенные счётчики.
+type hitSink struct {
+	storage.Store
+	batches [][]storage.CacheHits
+}
+
+func (s *hitSink) RecordHits(hits []storage.CacheHits) error {
+	s.batches = append(s.batches, hits)
+	return nil
+}
+
+func TestHitCounterAggregatesAndFlushesOnShutdown(t *testing.T) {
+	sink := &hitSink{}
+	c := newHitCounter(sink, zap.NewNop())
+
+	for range 3 {
+		c.Add("tiktok:1")
+	}
+	c.Add("tiktok:2#doc")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	c.Run(ctx)
+
+	if len(sink.batches) != 1 {
+		t.Fatalf("flushed %d batches, want 1", len(sink.batches))
+	}
+	got := make(map[string]int64)
+	for _, hit := range sink.batches[0] {
+		if hit.LastUsedAt.IsZero() {
+			t.Errorf("%s: LastUsedAt is not set", hit.SourceKey)
+		}
+		got[hit.SourceKey] = hit.Count
+	}
+	if got["tiktok:1"] != 3 || got["tiktok:2#doc"] != 1 || len(got) != 2 {
+		t.Errorf("flushed hits = %v, want tiktok:1=3 tiktok:2#doc=1", got)
+	}
+
+	// Пустой счётчик в базу не ходит
+	c.Flush()
+	if len(sink.batches) != 1 {
+		t.Errorf("empty flush wrote a batch: %d batches", len(sink.batches))
+	}
+}
@@ -0,0 +1,185 @@
+package bot
+
+import (
+	"errors"
+	"xa4yy_vidsave/internal/storage"
+
+	"go.uber.org/zap"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	preferencesCallbackPrefix = "pref:"
+	prefCaption               = "caption"
+	prefDocument              = "document"
+)
+
+// documentSuffix — ключ кэша для file_id документа: тот же файл, отправленный
+// через sendDocument, нельзя переслать как видео и наоборот.
+const documentSuffix = "#doc"
+
+// touch запоминает автора и чат обновления: профиль, last_seen_at и снятие блокировки.
+// В базу это попадает пачкой из seenTracker — обработка обновления её не ждёт.
+func (b *Bot) touch(from *tgbotapi.User, chat *tgbotapi.Chat) {
+	b.seen.Add(from, chat)
+}
+
+// markBlocked вызывается Sender'ом, когда Telegram ответил 403: бота заблокировали или выгнали.
+func (b *Bot) markBlocked(chatID int64) {
+	if err := b.store.MarkBlocked(chatID); err != nil {
+		b.log.Warn("failed to mark chat as blocked", zap.Error(err), zap.Int64("chat_id", chatID))
+		return
+	}
+	b.log.Info("chat blocked the bot", zap.Int64("chat_id", chatID))
+}
+
+// preferences — настройки, действующие в чате: в личке — пользователя, в группе — группы.
+// Если их нет или база недоступна — настройки по умолчанию.
+func (b *Bot) preferences(chatID int64) storage.Preferences {
+	var (
+		prefs storage.Preferences
+		err   error
+	)
+	if chatID > 0 {
+		// ID личного чата совпадает с ID пользователя
+		var user *storage.User
+		if user, err = b.store.LookupUser(chatID); err == nil {
+			prefs = user.Preferences
+		}
+	} else {
+		var chat *storage.Chat
+		if chat, err = b.store.LookupChat(chatID); err == nil {
+			prefs = chat.Preferences
+		}
+	}
+	if err != nil && !errors.Is(err, storage.ErrNotFound) {
+		b.log.Warn("failed to load preferences", zap.Error(err), zap.Int64("chat_id", chatID))
+	}
+	return prefs
+}
+
+// setPreferences сохраняет настройки чата: в личке — пользователю, в группе — группе.
+func (b *Bot) setPreferences(chatID int64, prefs storage.Preferences) error {
+	if chatID > 0 {
+		return b.store.SetUserPreferences(chatID, prefs)
+	}
+	return b.store.SetChatPreferences(chatID, prefs)
+}
+
+// captionFor — подпись под видео по настройкам.
+func captionFor(prefs storage.Preferences) string {
+	if prefs.Caption == storage.CaptionNone {
+		return ""
+	}
+	return videoCaption
+}
+
+// deliveryKey — ключ кэша, под которым лежит file_id в нужном настройкам виде.
+func deliveryKey(sourceKey string, prefs storage.Preferences) string {
+	if prefs.SendAsDocument {
+		return sourceKey + documentSuffix
+	}
+	return sourceKey
+}
+
+// videoMessage — видео (или файл, если так настроено) с подписью и кнопками под ним.
+// duration в секундах, 0 — неизвестна.
+func videoMessage(chatID int64, replyToMessageID int, file tgbotapi.RequestFileData, sourceKey string, duration int, prefs storage.Preferences) tgbotapi.Chattable {
+	if prefs.SendAsDocument {
+		doc := tgbotapi.NewDocument(chatID, file)
+		doc.Caption = captionFor(prefs)
+		doc.ReplyMarkup = videoKeyboard(sourceKey)
+		setReply(&doc.BaseChat, replyToMessageID)
+		return doc
+	}
+	video := tgbotapi.NewVideo(chatID, file)
+	video.Caption = captionFor(prefs)
+	video.Duration = duration
+	video.SupportsStreaming = true
+	video.ReplyMarkup = videoKeyboard(sourceKey)
+	setReply(&video.BaseChat, replyToMessageID)
+	return video
+}
+
+// --- /settings ---
+
+// handleSettings показывает настройки чата с кнопками-переключателями.
+func (b *Bot) handleSettings(chatID int64) {
+	msg := tgbotapi.NewMessage(chatID, settingsText(chatID))
+	msg.ReplyMarkup = settingsKeyboard(b.preferences(chatID))
+	b.sender.Send(msg)
+}
+
+func settingsText(chatID int64) string {
+	if chatID > 0 {
+		return "⚙️ настройки — жми, чтобы переключить"
+	}
+	return "⚙️ настройки группы — менять могут админы"
+}
+
+func settingsKeyboard(prefs storage.Preferences) tgbotapi.InlineKeyboardMarkup {
+	caption := "подпись: 🎬 @XA4yy"
+	if prefs.Caption == storage.CaptionNone {
+		caption = "подпись: без подписи"
+	}
+	format := "формат: 🎬 видео"
+	if prefs.SendAsDocument {
+		format = "формат: 📎 файл (без сжатия)"
+	}
+	return tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData(caption, preferencesCallbackPrefix+prefCaption)),
+		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData(format, preferencesCallbackPrefix+prefDocument)),
+	)
+}
+
+// togglePreference переключает настройку name; false — такой настройки нет.
+func togglePreference(prefs storage.Preferences, name string) (storage.Preferences, bool) {
+	switch name {
+	case prefCaption:
+		if prefs.Caption == storage.CaptionNone {
+			prefs.Caption = storage.CaptionCredit
+		} else {
+			prefs.Caption = storage.CaptionNone
+		}
+	case prefDocument:
+		prefs.SendAsDocument = !prefs.SendAsDocument
+	default:
+		return prefs, false
+	}
+	return prefs, true
+}
+
+// handlePreferenceToggle — нажатие кнопки в /settings. В группах настройки меняют только админы.
+func (b *Bot) handlePreferenceToggle(q *tgbotapi.CallbackQuery, name string) {
+	if q.Message == nil || q.From == nil {
+		b.sender.AnswerCallback(q.ID, "")
+		return
+	}
+	chat := q.Message.Chat
+	if !chat.IsPrivate() && !b.isChatAdmin(chat.ID, q.From.ID) {
+		b.sender.AnswerCallback(q.ID, "менять настройки группы могут только админы 🙅")
+		return
+	}
+
+	prefs, ok := togglePreference(b.preferences(chat.ID), name)
+	if !ok {
+		b.sender.AnswerCallback(q.ID, "")
+		return
+	}
+	// Настройки пишутся в строку пользователя или чата — она должна уже быть в базе
+	b.seen.Flush()
+	if err := b.setPreferences(chat.ID, prefs); err != nil {
+		b.log.Error("failed to save preferences", zap.Error(err), zap.Int64("chat_id", chat.ID))
+		b.sender.AnswerCallback(q.ID, "не получилось сохранить 😕")
+		return
+	}
+	b.log.Info("preferences changed",
+		zap.Int64("chat_id", chat.ID),
+		zap.Int64("user_id", q.From.ID),
+		zap.String("caption", string(prefs.Caption)),
+		zap.Bool("send_as_document", prefs.SendAsDocument),
+	)
+	b.sender.AnswerCallback(q.ID, "сохранил ✅")
+	b.sender.EditTextMarkup(chat.ID, q.Message.MessageID, settingsText(chat.ID), settingsKeyboard(prefs))
+}
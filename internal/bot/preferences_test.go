@@ -0,0 +1,52 @@
+package bot
+
+import (
+	"testing"
+	"xa4yy_vidsave/internal/storage"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestTogglePreference(t *testing.T) {
+	prefs, ok := togglePreference(storage.Preferences{}, prefCaption)
+	if !ok || prefs.Caption != storage.CaptionNone {
+		t.Fatalf("toggle caption = %+v, %v; want none", prefs, ok)
+	}
+	if prefs, _ = togglePreference(prefs, prefCaption); prefs.Caption != storage.CaptionCredit {
+		t.Errorf("toggle caption twice = %q, want credit", prefs.Caption)
+	}
+	if prefs, _ = togglePreference(prefs, prefDocument); !prefs.SendAsDocument {
+		t.Errorf("toggle document = %+v, want send as document", prefs)
+	}
+	if _, ok := togglePreference(prefs, "unknown"); ok {
+		t.Error("toggle unknown preference succeeded")
+	}
+}
+
+func TestVideoMessage(t *testing.T) {
+	file := tgbotapi.FileID("file")
+
+	video, ok := videoMessage(42, 0, file, "tiktok:1", 12, storage.Preferences{}).(tgbotapi.VideoConfig)
+	if !ok {
+		t.Fatal("default preferences must send a video")
+	}
+	if video.Caption != videoCaption || video.Duration != 12 || !video.SupportsStreaming {
+		t.Errorf("video = caption %q, duration %d, streaming %v", video.Caption, video.Duration, video.SupportsStreaming)
+	}
+
+	prefs := storage.Preferences{Caption: storage.CaptionNone, SendAsDocument: true}
+	doc, ok := videoMessage(-100, 5, file, "tiktok:1", 12, prefs).(tgbotapi.DocumentConfig)
+	if !ok {
+		t.Fatal("send_as_document must send a document")
+	}
+	if doc.Caption != "" || doc.ReplyToMessageID != 5 {
+		t.Errorf("document = caption %q, reply to %d; want no caption, reply to 5", doc.Caption, doc.ReplyToMessageID)
+	}
+	markup := doc.ReplyMarkup.(tgbotapi.InlineKeyboardMarkup)
+	if got := sourceKeyFromMarkup(&markup); got != "tiktok:1" {
+		t.Errorf("document keyboard source key = %q, want tiktok:1", got)
+	}
+	if got := deliveryKey("tiktok:1", prefs); got != "tiktok:1#doc" {
+		t.Errorf("deliveryKey = %q, want tiktok:1#doc", got)
+	}
+}
@@ -0,0 +1,428 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+	"xa4yy_vidsave/internal/download"
+	"xa4yy_vidsave/internal/link"
+	"xa4yy_vidsave/internal/media"
+	"xa4yy_vidsave/internal/storage"
+
+	"go.uber.org/zap"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	// jobPollInterval — как часто воркер заглядывает в очередь без явного сигнала
+	// (нужно для задач, отложенных backoff'ом, и задач других инстансов).
+	jobPollInterval = 2 * time.Second
+	// jobErrorPause — пауза после ошибки БД, чтобы не крутиться вхолостую.
+	jobErrorPause = 5 * time.Second
+	// jobLease — на сколько воркер берёт задачу в аренду. Пока задача выполняется,
+	// аренда продлевается каждые jobLease/3; если инстанс упал, через jobLease
+	// задачу подберёт другой (или этот же после рестарта).
+	jobLease = time.Minute
+)
+
+// replyError — ошибка, текст которой показывается пользователю как есть.
+// Такие ошибки не ретраятся: повтор даст тот же результат.
+type replyError struct {
+	text string
+	err  error
+	// reject — класс отказа по свойствам самого видео (too_long, too_large, no_video);
+	// такие отказы запоминаются в негативном кэше
+	reject string
+}
+
+func (e *replyError) Error() string {
+	if e.err != nil {
+		return e.text + ": " + e.err.Error()
+	}
+	return e.text
+}
+
+func (e *replyError) Unwrap() error {
+	return e.err
+}
+
+// errJobInterrupted — попытки задачи кончились на падениях инстансов, а не на ошибках скачивания.
+var errJobInterrupted = errors.New("job interrupted too many times")
+
+// enqueueDownload ставит скачивание в очередь и сразу показывает статус-сообщение.
+func (b *Bot) enqueueDownload(chatID, userID int64, chatType string, replyToMessageID int, parsed link.Parsed, sourceKey string) {
+	statusMsg := b.sendStatus(chatID, replyToMessageID, "⏳ сек, качаю")
+
+	job := &storage.Job{
+		ChatID:           chatID,
+		UserID:           userID,
+		ChatType:         chatType,
+		ReplyToMessageID: replyToMessageID,
+		URL:              parsed.Raw,
+		SourceKey:        sourceKey,
+		MaxAttempts:      b.cfg.JobMaxAttempts,
+	}
+	if statusMsg != nil {
+		job.StatusMessageID = statusMsg.MessageID
+	}
+
+	if err := b.store.EnqueueJob(job); err != nil {
+		b.log.Error("failed to enqueue download job", zap.Error(err), zap.String("source_key", sourceKey))
+		if statusMsg != nil {
+			b.sender.Delete(chatID, statusMsg.MessageID)
+		}
+		b.sender.TextReply(chatID, replyToMessageID, "не удалось поставить видео в очередь 😕\nпопробуй позже"+errorContact)
+		return
+	}
+
+	b.log.Info("download job enqueued",
+		zap.Uint("job_id", job.ID),
+		zap.String("source_key", sourceKey),
+	)
+	b.wakeWorkers()
+}
+
+// wakeWorkers будит один простаивающий воркер, не блокируясь.
+func (b *Bot) wakeWorkers() {
+	select {
+	case b.jobWake <- struct{}{}:
+	default:
+	}
+}
+
+// runJobReaper подбирает задачи упавших инстансов сразу и затем каждые jobLease.
+// Блокирует до отмены ctx.
+func (b *Bot) runJobReaper(ctx context.Context) {
+	ticker := time.NewTicker(jobLease)
+	defer ticker.Stop()
+
+	for {
+		b.resumeJobs()
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// resumeJobs возвращает в очередь задачи, чья аренда истекла, и предупреждает
+// пользователей: видео всё ещё в пути или, если попытки кончились, не скачалось.
+func (b *Bot) resumeJobs() {
+	jobs, err := b.store.RequeueExpiredJobs()
+	if err != nil {
+		b.log.Error("failed to requeue interrupted jobs", zap.Error(err))
+		return
+	}
+	if len(jobs) == 0 {
+		return
+	}
+
+	b.log.Info("resuming interrupted jobs", zap.Int("count", len(jobs)))
+	const text = "⏳ загрузка прервалась, но видео всё ещё в пути — качаю"
+	for _, job := range jobs {
+		if job.Status == storage.JobFailed {
+			b.log.Warn("interrupted job ran out of attempts",
+				zap.Uint("job_id", job.ID),
+				zap.String("source_key", job.SourceKey),
+				zap.Int("attempts", job.Attempts),
+			)
+			b.deleteStatus(&job)
+			b.sender.TextReply(job.ChatID, job.ReplyToMessageID, failureText(errJobInterrupted))
+			b.recordJob(&job, &deliveryStats{}, storage.OutcomeFailed, errJobInterrupted)
+			continue
+		}
+		if job.StatusMessageID != 0 {
+			b.sender.EditTextMarkup(job.ChatID, job.StatusMessageID, text, cancelKeyboard())
+			continue
+		}
+		statusMsg := b.sendStatus(job.ChatID, job.ReplyToMessageID, text)
+		if statusMsg == nil {
+			continue
+		}
+		if err := b.store.SetJobStatusMessage(job.ID, statusMsg.MessageID); err != nil {
+			b.log.Warn("failed to save job status message", zap.Error(err), zap.Uint("job_id", job.ID))
+		}
+	}
+	b.wakeWorkers()
+}
+
+// keepLease продлевает аренду задачи, пока она выполняется. Возвращает функцию остановки.
+func (b *Bot) keepLease(job *storage.Job) (stop func()) {
+	done := make(chan struct{})
+	exited := make(chan struct{})
+	go func() {
+		defer close(exited)
+		ticker := time.NewTicker(jobLease / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if err := b.store.RenewJobLease(job.ID, jobLease); err != nil {
+					b.log.Warn("failed to renew job lease", zap.Error(err), zap.Uint("job_id", job.ID))
+				}
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-exited
+	}
+}
+
+// startWorkers запускает пул воркеров очереди. wg.Wait() дождётся их остановки после отмены ctx.
+func (b *Bot) startWorkers(ctx context.Context, wg *sync.WaitGroup) {
+	for i := 0; i < cap(b.downloadSlots); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			b.worker(ctx)
+		}()
+	}
+}
+
+func (b *Bot) worker(ctx context.Context) {
+	ticker := time.NewTicker(jobPollInterval)
+	defer ticker.Stop()
+
+	for {
+		// Разбираем всё, что готово, и только потом засыпаем.
+		for ctx.Err() == nil {
+			job, err := b.store.ClaimJob(jobLease)
+			if errors.Is(err, storage.ErrNoJobs) {
+				break
+			}
+			if err != nil {
+				b.log.Error("failed to claim job", zap.Error(err))
+				select {
+				case <-ctx.Done():
+				case <-time.After(jobErrorPause):
+				}
+				break
+			}
+			b.processJob(ctx, job)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-b.jobWake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// processJob выполняет одну попытку задачи и записывает её итог.
+func (b *Bot) processJob(ctx context.Context, job *storage.Job) {
+	log := b.log.With(
+		zap.Uint("job_id", job.ID),
+		zap.String("source_key", job.SourceKey),
+		zap.Int("attempt", job.Attempts),
+	)
+
+	defer func() {
+		if r := recover(); r != nil {
+			log.Error("panic in job", zap.Any("recover", r))
+			b.failJob(job, errors.New("panic in job"), "что-то сломалось 😵 попробуй позже")
+		}
+	}()
+
+	parsed, err := link.Parse(job.URL, b.cfg.AllowedHosts)
+	if err != nil {
+		log.Error("job has unparsable url", zap.Error(err), zap.String("url", job.URL))
+		b.failJob(job, err, "не могу разобрать ссылку 🤔"+errorContact)
+		return
+	}
+
+	stopLease := b.keepLease(job)
+	defer stopLease()
+
+	jobCtx, release := b.active.track(ctx, statusKey{chatID: job.ChatID, messageID: job.StatusMessageID}, job.UserID)
+	if b.cfg.JobTimeout > 0 {
+		var cancelTimeout context.CancelFunc
+		jobCtx, cancelTimeout = context.WithTimeout(jobCtx, b.cfg.JobTimeout)
+		defer cancelTimeout()
+	}
+	stopAnim := b.animateStatus(job.ChatID, job.StatusMessageID)
+	stats := &deliveryStats{}
+	err = b.deliver(jobCtx, job, parsed, stats)
+	stopAnim()
+	cancelled := release()
+
+	switch {
+	case err == nil:
+		if err := b.store.FinishJob(job.ID, storage.JobDone, ""); err != nil {
+			log.Error("failed to finish job", zap.Error(err))
+		}
+		b.deleteStatus(job)
+		b.recordJob(job, stats, stats.outcome, nil)
+	case cancelled:
+		log.Info("job cancelled")
+		b.recordJob(job, stats, storage.OutcomeCancelled, nil)
+		if err := b.store.FinishJob(job.ID, storage.JobCancelled, err.Error()); err != nil {
+			log.Error("failed to finish job", zap.Error(err))
+		}
+		if job.StatusMessageID != 0 {
+			b.sender.EditText(job.ChatID, job.StatusMessageID, "✖️ отменено")
+		}
+	case ctx.Err() != nil:
+		// Бот останавливается: возвращаем задачу в очередь сразу, не дожидаясь конца аренды.
+		// Попытка засчитана, как и при падении.
+		log.Info("job interrupted by shutdown")
+		if err := b.store.RetryJob(job.ID, "interrupted by shutdown", time.Now()); err != nil {
+			log.Error("failed to requeue job", zap.Error(err))
+		}
+		if job.StatusMessageID != 0 {
+			b.sender.EditTextMarkup(job.ChatID, job.StatusMessageID, "⏳ бот перезапускается, но видео всё ещё в пути", cancelKeyboard())
+		}
+	case isRetryable(err) && job.Attempts < job.MaxAttempts:
+		delay := jobBackoff(job.Attempts)
+		log.Warn("job failed, will retry", zap.Error(err), zap.Duration("delay", delay))
+		if err := b.store.RetryJob(job.ID, err.Error(), time.Now().Add(delay)); err != nil {
+			log.Error("failed to reschedule job", zap.Error(err))
+		}
+		if job.StatusMessageID != 0 {
+			b.sender.EditTextMarkup(job.ChatID, job.StatusMessageID, "⏳ с первого раза не вышло, пробую ещё раз", cancelKeyboard())
+		}
+	default:
+		log.Error("job failed", zap.Error(err))
+		text := failureText(err)
+		b.rememberFailure(job.SourceKey, err, text)
+		b.failJob(job, err, text)
+		b.recordJob(job, stats, storage.OutcomeFailed, err)
+	}
+}
+
+// failJob помечает задачу failed, убирает статус и сообщает пользователю text.
+func (b *Bot) failJob(job *storage.Job, cause error, text string) {
+	if err := b.store.FinishJob(job.ID, storage.JobFailed, cause.Error()); err != nil {
+		b.log.Error("failed to finish job", zap.Error(err), zap.Uint("job_id", job.ID))
+	}
+	b.deleteStatus(job)
+	b.sender.TextReply(job.ChatID, job.ReplyToMessageID, text)
+}
+
+// sendStatus отправляет статус-сообщение с кнопкой «Отмена».
+func (b *Bot) sendStatus(chatID int64, replyToMessageID int, text string) *tgbotapi.Message {
+	message := tgbotapi.NewMessage(chatID, text)
+	message.ReplyMarkup = cancelKeyboard()
+	setReply(&message.BaseChat, replyToMessageID)
+	msg, err := b.sender.SendWithResponse(message)
+	if err != nil {
+		b.log.Warn("failed to send status message", zap.Error(err), zap.Int64("chat_id", chatID))
+		return nil
+	}
+	return msg
+}
+
+func (b *Bot) deleteStatus(job *storage.Job) {
+	if job.StatusMessageID != 0 {
+		b.sender.Delete(job.ChatID, job.StatusMessageID)
+	}
+}
+
+// animateStatus крутит анимацию «качаю...» в статус-сообщении. Возвращает функцию остановки.
+func (b *Bot) animateStatus(chatID int64, messageID int) (stop func()) {
+	if messageID == 0 {
+		return func() {}
+	}
+
+	done := make(chan struct{})
+	exited := make(chan struct{})
+	go func() {
+		defer close(exited)
+		frames := []string{"⏳ сек, качаю.", "⏳ сек, качаю..", "⏳ сек, качаю...", "⏳ сек, качаю"}
+		i := 0
+		ticker := time.NewTicker(800 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				b.sender.EditTextMarkup(chatID, messageID, frames[i%len(frames)], cancelKeyboard())
+				i++
+			}
+		}
+	}()
+
+	// Ждём выхода горутины, чтобы запоздалый кадр не перетёр итоговый текст статуса.
+	var once sync.Once
+	return func() {
+		once.Do(func() { close(done) })
+		<-exited
+	}
+}
+
+// isRetryable — имеет ли смысл повторить задачу с этой ошибкой.
+func isRetryable(err error) bool {
+	var re *replyError
+	switch {
+	case errors.As(err, &re):
+		return false
+	case errors.Is(err, context.DeadlineExceeded):
+		// Упёрлись в JOB_TIMEOUT — повтор, скорее всего, упрётся снова.
+		return false
+	case errors.Is(err, media.ErrNoVideoStream), errors.Is(err, media.ErrZeroDuration):
+		return false
+	default:
+		return download.IsRetryable(err)
+	}
+}
+
+// jobBackoff — задержка перед следующей попыткой: 10s, 30s, 90s, ... но не больше 5 минут.
+func jobBackoff(attempt int) time.Duration {
+	delay := 10 * time.Second
+	for i := 1; i < attempt; i++ {
+		delay *= 3
+		if delay >= 5*time.Minute {
+			return 5 * time.Minute
+		}
+	}
+	return delay
+}
+
+// failureText — сообщение пользователю о финальной ошибке задачи.
+func failureText(err error) string {
+	var re *replyError
+	switch {
+	case errors.As(err, &re):
+		return re.text
+	case errors.Is(err, download.ErrYtDlpAuth):
+		return "эта ссылка требует вход в аккаунт и в публичном режиме не скачивается 😕\nпопробуй другую публичную ссылку" + errorContact
+	case errors.Is(err, download.ErrYtDlpUnsupported):
+		return "эта ссылка ведёт не на видео или yt-dlp не умеет её скачивать 😕\nпопробуй другую ссылку" + errorContact
+	case errors.Is(err, download.ErrYtDlpPrivate):
+		return "это видео приватное 🔒\nскачать можно только публичные"
+	case errors.Is(err, download.ErrYtDlpRemoved):
+		return "видео удалено или недоступно 🫥"
+	case errors.Is(err, download.ErrYtDlpGeoRestricted):
+		return "видео недоступно в нашем регионе 🌍\nпопробуй другую ссылку"
+	case errors.Is(err, download.ErrYtDlpAgeRestricted):
+		return "видео с возрастным ограничением 🔞\nбез входа в аккаунт его не скачать"
+	case errors.Is(err, download.ErrYtDlpLiveStream):
+		return "это прямой эфир 📡\nэфиры не качаю — кидай ссылку на запись"
+	case errors.Is(err, download.ErrYtDlpTooLarge):
+		return "видео слишком большое для Telegram, лимит 50 МБ 😬"
+	case errors.Is(err, download.ErrYtDlpNetwork):
+		return "не получилось достучаться до платформы 📶\nпопробуй позже" + errorContact
+	case errors.Is(err, download.ErrYtDlpRateLimited):
+		return "платформа временно ограничила запросы 🐢\nпопробуй через несколько минут"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "видео качается слишком долго ⌛\nпопробуй позже или другую ссылку" + errorContact
+	case errors.Is(err, media.ErrNoVideoStream):
+		return "по ссылке нет видео — только звук или картинка 🤷"
+	case errors.Is(err, media.ErrZeroDuration):
+		return "скачался пустой файл 😕\nпопробуй другую ссылку" + errorContact
+	case errors.Is(err, media.ErrBrokenContainer):
+		return "скачался битый файл 😕\nпопробуй позже" + errorContact
+	case errors.Is(err, download.ErrWorkDirFull):
+		return "сервер сейчас перегружен загрузками 😮‍💨\nпопробуй через пару минут"
+	default:
+		return "не удалось скачать видео 😕\nпопробуй позже" + errorContact
+	}
+}
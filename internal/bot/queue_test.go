@@ -0,0 +1,52 @@
+package bot
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+	"xa4yy_vidsave/internal/download"
+)
+
+func TestJobBackoff(t *testing.T) {
+	tests := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{attempt: 1, want: 10 * time.Second},
+		{attempt: 2, want: 30 * time.Second},
+		{attempt: 3, want: 90 * time.Second},
+		{attempt: 4, want: 270 * time.Second},
+		{attempt: 10, want: 5 * time.Minute},
+	}
+
+	for _, tt := range tests {
+		if got := jobBackoff(tt.attempt); got != tt.want {
+			t.Errorf("jobBackoff(%d) = %v, want %v", tt.attempt, got, tt.want)
+		}
+	}
+}
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "generic yt-dlp error", err: fmt.Errorf("video download failed: %w", download.ErrYtDlp), want: true},
+		{name: "auth required", err: fmt.Errorf("video download failed: %w", download.ErrYtDlpAuth), want: false},
+		{name: "unsupported url", err: fmt.Errorf("video download failed: %w", download.ErrYtDlpUnsupported), want: false},
+		{name: "private video", err: fmt.Errorf("video download failed: %w", download.ErrYtDlpPrivate), want: false},
+		{name: "rate limited", err: fmt.Errorf("video download failed: %w", download.ErrYtDlpRateLimited), want: true},
+		{name: "reply error", err: &replyError{text: "видео слишком большое"}, want: false},
+		{name: "unknown error", err: errors.New("connection reset"), want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isRetryable(tt.err); got != tt.want {
+				t.Fatalf("isRetryable() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
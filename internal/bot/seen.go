@@ -0,0 +1,94 @@
+package bot
+
+import (
+	"context"
+	"sync"
+	"time"
+	"xa4yy_vidsave/internal/storage"
+
+	"go.uber.org/zap"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// seenFlushInterval — как часто пишем в базу, кто и откуда писал боту.
+const seenFlushInterval = 10 * time.Second
+
+// seenTracker копит последних увиденных пользователей и чаты в памяти и пишет их
+// пачками, как hitCounter: обновление не ждёт базы, а активный пользователь даёт
+// одну запись за интервал, а не по одной на каждое сообщение.
+type seenTracker struct {
+	store storage.Store
+	log   *zap.Logger
+
+	mu    sync.Mutex
+	users map[int64]storage.User
+	chats map[int64]storage.Chat
+}
+
+func newSeenTracker(store storage.Store, log *zap.Logger) *seenTracker {
+	return &seenTracker{
+		store: store,
+		log:   log,
+		users: make(map[int64]storage.User),
+		chats: make(map[int64]storage.Chat),
+	}
+}
+
+// Add запоминает автора и чат обновления (оба могут быть nil); ботов пропускает.
+func (t *seenTracker) Add(from *tgbotapi.User, chat *tgbotapi.Chat) {
+	now := time.Now()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if from != nil && !from.IsBot {
+		t.users[from.ID] = storage.User{
+			ID:           from.ID,
+			Username:     from.UserName,
+			FirstName:    from.FirstName,
+			LanguageCode: from.LanguageCode,
+			LastSeenAt:   now,
+		}
+	}
+	if chat != nil {
+		t.chats[chat.ID] = storage.Chat{ID: chat.ID, Type: chat.Type, Title: chat.Title, LastSeenAt: now}
+	}
+}
+
+// Flush пишет накопленное. Пользователи идут раньше чатов: запись личного чата
+// снимает блокировку с пользователя, и он должен уже быть в базе.
+// При ошибке запись теряется — следующее обновление от того же пользователя её повторит.
+func (t *seenTracker) Flush() {
+	t.mu.Lock()
+	users, chats := t.users, t.chats
+	t.users = make(map[int64]storage.User)
+	t.chats = make(map[int64]storage.Chat)
+	t.mu.Unlock()
+
+	for _, user := range users {
+		if err := t.store.TouchUser(&user); err != nil {
+			t.log.Warn("failed to save user", zap.Error(err), zap.Int64("user_id", user.ID))
+		}
+	}
+	for _, chat := range chats {
+		if err := t.store.TouchChat(&chat); err != nil {
+			t.log.Warn("failed to save chat", zap.Error(err), zap.Int64("chat_id", chat.ID))
+		}
+	}
+}
+
+// Run пишет накопленное каждые seenFlushInterval и последний раз после отмены ctx.
+func (t *seenTracker) Run(ctx context.Context) {
+	ticker := time.NewTicker(seenFlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			t.Flush()
+		case <-ctx.Done():
+			t.Flush()
+			return
+		}
+	}
+}
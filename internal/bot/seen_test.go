@@ -0,0 +1,44 @@
+package bot
+
+import (
+	"context"
+	"testing"
+	"xa4yy_vidsave/internal/storage"
+
+	"go.uber.org/zap"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestSeenTrackerDebouncesAndFlushesOnShutdown(t *testing.T) {
+	store := storage.NewMemory(zap.NewNop())
+	tracker := newSeenTracker(store, zap.NewNop())
+
+	group := &tgbotapi.Chat{ID: -100, Type: "supergroup", Title: "memes"}
+	private := &tgbotapi.Chat{ID: 42, Type: storage.ChatPrivate}
+	tracker.Add(&tgbotapi.User{ID: 42, UserName: "old"}, group)
+	tracker.Add(&tgbotapi.User{ID: 42, UserName: "new"}, private)
+	tracker.Add(&tgbotapi.User{ID: 7, IsBot: true}, nil)
+
+	// До сброса в базе ничего нет — обработка обновления её не ждёт
+	if _, err := store.LookupUser(42); err == nil {
+		t.Fatal("user saved before flush")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	tracker.Run(ctx)
+
+	user, err := store.LookupUser(42)
+	if err != nil || user.Username != "new" {
+		t.Fatalf("LookupUser(42) = %+v, %v; want the latest profile", user, err)
+	}
+	for _, id := range []int64{-100, 42} {
+		if _, err := store.LookupChat(id); err != nil {
+			t.Errorf("LookupChat(%d) error = %v", id, err)
+		}
+	}
+	if _, err := store.LookupUser(7); err == nil {
+		t.Error("bot user was saved")
+	}
+}
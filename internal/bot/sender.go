@@ -1,6 +1,8 @@
 package bot
 
 import (
+	"errors"
+	"fmt"
 	"strings"
 	"time"
 
@@ -13,6 +15,10 @@ import (
 type Sender struct {
 	api *tgbotapi.BotAPI
 	log *zap.Logger
+
+	// onBlocked вызывается, когда Telegram ответил 403: пользователь заблокировал бота
+	// или бота выгнали из группы.
+	onBlocked func(chatID int64)
 }
 
 func NewSender(api *tgbotapi.BotAPI, log *zap.Logger) *Sender {
@@ -22,6 +28,13 @@ func NewSender(api *tgbotapi.BotAPI, log *zap.Logger) *Sender {
 // maxRetries — сколько раз повторяем при 429.
 const maxRetries = 3
 
+// ErrRateLimited — Telegram отвечал 429 на все maxRetries попыток.
+var ErrRateLimited = errors.New("telegram rate limit: retries exhausted")
+
+// ErrInvalidFileID — Telegram не принял file_id: сменился токен бота, файл протух
+// на стороне Telegram или file_id битый. Повтор не поможет — только перезалив.
+var ErrInvalidFileID = errors.New("telegram rejected file_id")
+
 // Send отправляет Chattable (видео, фото, текст и т.д.) с retry при 429.
 func (s *Sender) Send(c tgbotapi.Chattable) error {
 	_, err := s.SendWithResponse(c)
@@ -29,6 +42,7 @@ func (s *Sender) Send(c tgbotapi.Chattable) error {
 }
 
 // SendWithResponse отправляет Chattable и возвращает ответ Telegram (Message).
+// Message не nil, если ошибки нет; исчерпанные попытки при 429 — ErrRateLimited.
 func (s *Sender) SendWithResponse(c tgbotapi.Chattable) (*tgbotapi.Message, error) {
 	for attempt := 1; attempt <= maxRetries; attempt++ {
 		msg, err := s.api.Send(c)
@@ -38,6 +52,9 @@ func (s *Sender) SendWithResponse(c tgbotapi.Chattable) (*tgbotapi.Message, erro
 
 		// Проверяем 429 Too Many Requests
 		if isRateLimited(err) {
+			if attempt == maxRetries {
+				return nil, fmt.Errorf("%w: %w", ErrRateLimited, err)
+			}
 			wait := retryAfter(err, attempt)
 			s.log.Warn("rate limited by Telegram, waiting",
 				zap.Duration("wait", wait),
@@ -48,10 +65,18 @@ func (s *Sender) SendWithResponse(c tgbotapi.Chattable) (*tgbotapi.Message, erro
 		}
 
 		// Другая ошибка — не ретраим
+		if isForbidden(err) && s.onBlocked != nil {
+			if chatID, ok := chatIDOf(c); ok {
+				s.onBlocked(chatID)
+			}
+		}
+		if isInvalidFileID(err) {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidFileID, err)
+		}
 		return nil, err
 	}
 
-	return nil, nil
+	return nil, ErrRateLimited
 }
 
 // Text — удобная обёртка для отправки текстового сообщения.
@@ -110,6 +135,24 @@ func (s *Sender) EditText(chatID int64, messageID int, text string) {
 	}
 }
 
+// EditTextMarkup редактирует текст сообщения, сохраняя (или заменяя) inline-клавиатуру.
+func (s *Sender) EditTextMarkup(chatID int64, messageID int, text string, markup tgbotapi.InlineKeyboardMarkup) {
+	edit := tgbotapi.NewEditMessageTextAndMarkup(chatID, messageID, text, markup)
+	if _, err := s.api.Send(edit); err != nil {
+		s.log.Warn("failed to edit message",
+			zap.Error(err),
+			zap.Int("message_id", messageID),
+		)
+	}
+}
+
+// AnswerCallback отвечает на нажатие inline-кнопки (всплывающее уведомление).
+func (s *Sender) AnswerCallback(callbackID, text string) {
+	if _, err := s.api.Request(tgbotapi.NewCallback(callbackID, text)); err != nil {
+		s.log.Warn("failed to answer callback query", zap.Error(err))
+	}
+}
+
 // Delete удаляет сообщение.
 func (s *Sender) Delete(chatID int64, messageID int) {
 	del := tgbotapi.NewDeleteMessage(chatID, messageID)
@@ -130,6 +173,52 @@ func isRateLimited(err error) bool {
 	return strings.Contains(msg, "429") || strings.Contains(msg, "Too Many Requests") || strings.Contains(msg, "retry after")
 }
 
+// isForbidden проверяет, что Telegram ответил 403: писать в этот чат боту больше нельзя.
+func isForbidden(err error) bool {
+	var apiErr *tgbotapi.Error
+	return errors.As(err, &apiErr) && apiErr.Code == 403
+}
+
+// chatIDOf достаёт ID чата из того, что бот отправляет в чат.
+func chatIDOf(c tgbotapi.Chattable) (int64, bool) {
+	var base tgbotapi.BaseChat
+	switch m := c.(type) {
+	case tgbotapi.MessageConfig:
+		base = m.BaseChat
+	case tgbotapi.VideoConfig:
+		base = m.BaseChat
+	case tgbotapi.DocumentConfig:
+		base = m.BaseChat
+	case tgbotapi.AnimationConfig:
+		base = m.BaseChat
+	case tgbotapi.VideoNoteConfig:
+		base = m.BaseChat
+	default:
+		return 0, false
+	}
+	return base.ChatID, base.ChatID != 0
+}
+
+// isInvalidFileID проверяет, что Telegram отверг file_id отправляемого файла.
+func isInvalidFileID(err error) bool {
+	var apiErr *tgbotapi.Error
+	if !errors.As(err, &apiErr) || apiErr.Code != 400 {
+		return false
+	}
+	msg := strings.ToLower(apiErr.Message)
+	for _, marker := range []string{
+		"wrong file identifier",
+		"wrong remote file identifier",
+		"file_id_invalid",
+		"file reference",
+	} {
+		if strings.Contains(msg, marker) {
+			return true
+		}
+	}
+	return false
+}
+
 // retryAfter определяет время ожидания перед повторной отправкой.
 // Telegram обычно присылает retry_after в ошибке, но для простоты
 // используем экспоненциальный backoff.
@@ -0,0 +1,51 @@
+package bot
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestIsInvalidFileID(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"wrong file identifier", &tgbotapi.Error{Code: 400, Message: "Bad Request: wrong file identifier/HTTP URL specified"}, true},
+		{"wrong remote file identifier", &tgbotapi.Error{Code: 400, Message: "Bad Request: wrong remote file identifier specified: Wrong padding in the string"}, true},
+		{"wrapped", fmt.Errorf("send: %w", &tgbotapi.Error{Code: 400, Message: "Bad Request: FILE_ID_INVALID"}), true},
+		{"other bad request", &tgbotapi.Error{Code: 400, Message: "Bad Request: message to reply not found"}, false},
+		{"forbidden", &tgbotapi.Error{Code: 403, Message: "Forbidden: bot was blocked by the user"}, false},
+		{"network", errors.New("wrong file identifier"), false},
+	}
+	for _, tt := range tests {
+		if got := isInvalidFileID(tt.err); got != tt.want {
+			t.Errorf("%s: isInvalidFileID() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestChatIDOf(t *testing.T) {
+	tests := []struct {
+		name   string
+		c      tgbotapi.Chattable
+		want   int64
+		wantOK bool
+	}{
+		{"message", tgbotapi.NewMessage(42, "hi"), 42, true},
+		{"video", tgbotapi.NewVideo(-100, tgbotapi.FileID("f")), -100, true},
+		{"document", tgbotapi.NewDocument(7, tgbotapi.FileID("f")), 7, true},
+		{"video note", tgbotapi.NewVideoNote(8, 384, tgbotapi.FileID("f")), 8, true},
+		{"channel username", tgbotapi.NewMessageToChannel("@chan", "hi"), 0, false},
+		{"edit", tgbotapi.NewEditMessageText(42, 1, "hi"), 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := chatIDOf(tt.c)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("%s: chatIDOf() = %d, %v; want %d, %v", tt.name, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"xa4yy_vidsave/internal/download"
+	"xa4yy_vidsave/internal/link"
+	"xa4yy_vidsave/internal/media"
+	"xa4yy_vidsave/internal/storage"
+
+	"go.uber.org/zap"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// subtitlesCallbackPrefix — callback_data кнопки «📝 Субтитры»: "subs:<source_key>".
+const subtitlesCallbackPrefix = "subs:"
+
+// subtitlesSupported — платформы, где у видео бывают авторские или авто-субтитры.
+// У YouTube они тоже есть, но YouTube-ссылки бот пока не принимает.
+var subtitlesSupported = map[link.Type]bool{
+	link.TypeTikTok: true,
+}
+
+// handleSubtitles отвечает на кнопку «📝 Субтитры» документом .srt на языке пользователя.
+func (b *Bot) handleSubtitles(ctx context.Context, q *tgbotapi.CallbackQuery, sourceKey string) {
+	if q.Message == nil {
+		b.sender.AnswerCallback(q.ID, "субтитры присылаю только в чате с ботом 🙏")
+		return
+	}
+	chatID := q.Message.Chat.ID
+	videoMessageID := q.Message.MessageID
+	lang := userLang(q.From)
+
+	// Уже отправляли — по file_id
+	cached, err := b.store.LookupSubtitle(sourceKey, lang)
+	if err == nil {
+		b.sender.AnswerCallback(q.ID, "")
+		b.sendSubtitles(chatID, videoMessageID, tgbotapi.FileID(cached.TgFileID), cached.TrackLang)
+		return
+	}
+	if !errors.Is(err, storage.ErrNotFound) {
+		b.log.Error("subtitle cache lookup error", zap.Error(err))
+	}
+
+	parsed, ok := sourceFromKey(sourceKey)
+	if !ok {
+		b.sender.AnswerCallback(q.ID, "не знаю, откуда это видео 🤔")
+		return
+	}
+	b.sender.AnswerCallback(q.ID, "ищу субтитры ⏳")
+
+	srt, track, err := b.fetchSubtitles(ctx, parsed, lang)
+	if err != nil {
+		if errors.Is(err, download.ErrNoSubtitles) || errors.Is(err, media.ErrNoCues) {
+			b.sender.TextReply(chatID, videoMessageID, "у этого видео нет субтитров 🤷")
+			return
+		}
+		b.log.Error("failed to fetch subtitles", zap.Error(err), zap.String("source_key", sourceKey))
+		b.sender.TextReply(chatID, videoMessageID, "не получилось достать субтитры 😕"+errorContact)
+		return
+	}
+
+	file := tgbotapi.FileBytes{Name: fmt.Sprintf("%s.%s.srt", parsed.VideoID, track), Bytes: srt}
+	resp := b.sendSubtitles(chatID, videoMessageID, file, track)
+	if resp == nil || resp.Document == nil {
+		return
+	}
+
+	entry := &storage.Subtitle{
+		SourceKey:      sourceKey,
+		Lang:           lang,
+		TrackLang:      track,
+		TgFileID:       resp.Document.FileID,
+		TgFileUniqueID: resp.Document.FileUniqueID,
+	}
+	if err := b.store.SaveSubtitle(entry); err != nil {
+		b.log.Error("failed to save subtitle cache entry", zap.Error(err))
+	}
+	b.log.Info("subtitles sent",
+		zap.String("source_key", sourceKey),
+		zap.String("lang", lang),
+		zap.String("track", track),
+	)
+}
+
+// fetchSubtitles скачивает дорожку через yt-dlp (занимая слот скачивания) и возвращает SRT.
+func (b *Bot) fetchSubtitles(ctx context.Context, parsed link.Parsed, lang string) ([]byte, string, error) {
+	if b.cfg.JobTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, b.cfg.JobTimeout)
+		defer cancel()
+	}
+
+	select {
+	case b.downloadSlots <- struct{}{}:
+		defer func() { <-b.downloadSlots }()
+	case <-ctx.Done():
+		return nil, "", ctx.Err()
+	}
+
+	langs := []string{lang}
+	if lang != "en" {
+		langs = append(langs, "en")
+	}
+
+	result, err := download.DownloadSubtitles(ctx, parsed.Raw, langs, b.downloadOptions(parsed), b.log)
+	if err != nil {
+		return nil, "", err
+	}
+	defer b.workDir.Remove(result.Dir, "subtitles fetched")
+
+	data, err := os.ReadFile(result.Path)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var srt []byte
+	if result.Format == "vtt" {
+		srt, err = media.VTTToSRT(data)
+	} else {
+		srt, err = media.NormalizeSRT(data)
+	}
+	return srt, result.Lang, err
+}
+
+// sendSubtitles отправляет .srt документом в ответ на видео.
+func (b *Bot) sendSubtitles(chatID int64, videoMessageID int, file tgbotapi.RequestFileData, track string) *tgbotapi.Message {
+	doc := tgbotapi.NewDocument(chatID, file)
+	doc.Caption = "📝 субтитры · " + track
+	setReply(&doc.BaseChat, videoMessageID)
+
+	resp, err := b.sender.SendWithResponse(doc)
+	if err != nil {
+		b.log.Error("failed to send subtitles", zap.Error(err))
+		b.sender.TextReply(chatID, videoMessageID, "не удалось отправить субтитры 😢")
+		return nil
+	}
+	return resp
+}
+
+// userLang — двухбуквенный язык интерфейса Telegram пользователя ("ru" из "ru-RU"), по умолчанию "en".
+func userLang(user *tgbotapi.User) string {
+	if user == nil {
+		return "en"
+	}
+	lang, _, _ := strings.Cut(strings.ToLower(user.LanguageCode), "-")
+	if len(lang) != 2 {
+		return "en"
+	}
+	return lang
+}
@@ -0,0 +1,55 @@
+package bot
+
+import (
+	"strings"
+	"testing"
+	"xa4yy_vidsave/internal/storage"
+)
+
+func TestSourceFromKeyRoundTrip(t *testing.T) {
+	for _, key := range []string{
+		"tiktok:7301234567890123456",
+		"tiktok:ZSabc123",
+		"instagram:C1a2B3c4D5e",
+	} {
+		parsed, ok := sourceFromKey(key)
+		if !ok {
+			t.Errorf("sourceFromKey(%q) failed", key)
+			continue
+		}
+		if got := storage.SourceKeyFromParsed(string(parsed.LinkType), parsed.VideoID); got != key {
+			t.Errorf("sourceFromKey(%q) -> %s -> key %q", key, parsed.Raw, got)
+		}
+	}
+
+	for _, key := range []string{"", "tiktok", "youtube:abc", "tiktok:bad/id"} {
+		if _, ok := sourceFromKey(key); ok {
+			t.Errorf("sourceFromKey(%q) ok, want failure", key)
+		}
+	}
+}
+
+func TestVideoKeyboardTools(t *testing.T) {
+	tests := []struct {
+		key  string
+		want []string
+	}{
+		{"tiktok:7301234567890123456", []string{"subs:tiktok:7301234567890123456", "gif:tiktok:7301234567890123456", "note:tiktok:7301234567890123456"}},
+		{"instagram:C1a2B3c4D5e", []string{"gif:instagram:C1a2B3c4D5e", "note:instagram:C1a2B3c4D5e"}},
+	}
+
+	for _, tt := range tests {
+		rows := videoKeyboard(tt.key).InlineKeyboard
+		if len(rows) != 2 {
+			t.Errorf("videoKeyboard(%q) rows = %d, want share + tools", tt.key, len(rows))
+			continue
+		}
+		var got []string
+		for _, btn := range rows[1] {
+			got = append(got, *btn.CallbackData)
+		}
+		if strings.Join(got, " ") != strings.Join(tt.want, " ") {
+			t.Errorf("videoKeyboard(%q) tools = %v, want %v", tt.key, got, tt.want)
+		}
+	}
+}
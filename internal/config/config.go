@@ -4,6 +4,7 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"time"
 
 	"go.uber.org/zap"
 )
@@ -13,9 +14,34 @@ type Config struct {
 	AllowedHosts           map[string]struct{}
 	InsecureSkipVerify     bool
 	MaxDownloadBytes       int64
+	MaxVideoDuration       time.Duration
 	MaxConcurrentDownloads int
-	Proxy                  string
+	JobMaxAttempts         int
+	Proxies                map[string][]string
+	ProxyStrategy          string
+	ProxyMaxFailures       int
+	ProxyQuarantine        time.Duration
 	DatabaseURL            string
+	WorkDir                string
+	WorkDirQuotaBytes      int64
+	WorkDirMaxAge          time.Duration
+	JanitorInterval        time.Duration
+	CacheTTL               time.Duration
+	CacheMaxEntries        int
+	CacheEviction          string
+	CachePurgeAfter        time.Duration
+	CacheMaintenance       time.Duration
+	MemoryCacheEntries     int
+	MemoryCacheTTL         time.Duration
+	RequestRetention       time.Duration
+	JobTimeout             time.Duration
+	YtDlpCPUTime           time.Duration
+	YtDlpMemoryBytes       int64
+	YtDlpMaxOutputBytes    int
+	CookiesDir             string
+	CookieCooldown         time.Duration
+	NativeBackends         bool
+	AdminIDs               map[int64]struct{}
 }
 
 func getEnv(key string, log *zap.Logger) string {
@@ -32,9 +58,34 @@ func Load(log *zap.Logger) *Config {
 		AllowedHosts:           parseAllowedHosts(getEnv("ALLOWED_HOSTS", log)),
 		InsecureSkipVerify:     parseBool(getEnv("INSECURE_SKIP_VERIFY", log)),
 		MaxDownloadBytes:       int64(parseInt(getEnv("MAX_DOWNLOAD_MB", log), 200)) * 1024 * 1024,
+		MaxVideoDuration:       parseDuration(os.Getenv("MAX_VIDEO_DURATION"), 10*time.Minute),
 		MaxConcurrentDownloads: max(1, parseInt(os.Getenv("MAX_CONCURRENT_DOWNLOADS"), 3)),
-		Proxy:                  strings.TrimSpace(os.Getenv("PROXY")),
+		JobMaxAttempts:         max(1, parseInt(os.Getenv("JOB_MAX_ATTEMPTS"), 3)),
+		Proxies:                parseProxies(os.Getenv("PROXIES"), os.Getenv("PROXY")),
+		ProxyStrategy:          strings.TrimSpace(os.Getenv("PROXY_STRATEGY")),
+		ProxyMaxFailures:       max(1, parseInt(os.Getenv("PROXY_MAX_FAILURES"), 3)),
+		ProxyQuarantine:        parseDuration(os.Getenv("PROXY_QUARANTINE"), 10*time.Minute),
 		DatabaseURL:            strings.TrimSpace(getEnv("DATABASE_URL", log)),
+		WorkDir:                strings.TrimSpace(os.Getenv("WORK_DIR")),
+		WorkDirQuotaBytes:      int64(max(0, parseInt(os.Getenv("WORK_DIR_QUOTA_MB"), 2048))) * 1024 * 1024,
+		WorkDirMaxAge:          parseDuration(os.Getenv("WORK_DIR_MAX_AGE"), time.Hour),
+		JanitorInterval:        parseDuration(os.Getenv("JANITOR_INTERVAL"), 10*time.Minute),
+		CacheTTL:               days(parseInt(os.Getenv("CACHE_TTL_DAYS"), 90)),
+		CacheMaxEntries:        max(0, parseInt(os.Getenv("CACHE_MAX_ENTRIES"), 200000)),
+		CacheEviction:          strings.ToLower(strings.TrimSpace(os.Getenv("CACHE_EVICTION"))),
+		CachePurgeAfter:        days(parseInt(os.Getenv("CACHE_PURGE_AFTER_DAYS"), 7)),
+		CacheMaintenance:       parseDuration(os.Getenv("CACHE_MAINTENANCE_INTERVAL"), 6*time.Hour),
+		MemoryCacheEntries:     max(0, parseInt(os.Getenv("MEMORY_CACHE_ENTRIES"), 10000)),
+		MemoryCacheTTL:         parseDuration(os.Getenv("MEMORY_CACHE_TTL"), 10*time.Minute),
+		RequestRetention:       days(parseInt(os.Getenv("REQUEST_RETENTION_DAYS"), 90)),
+		JobTimeout:             parseLimit(os.Getenv("JOB_TIMEOUT"), 5*time.Minute),
+		YtDlpCPUTime:           time.Duration(max(0, parseInt(os.Getenv("YTDLP_CPU_SECONDS"), 300))) * time.Second,
+		YtDlpMemoryBytes:       int64(max(0, parseInt(os.Getenv("YTDLP_MEMORY_MB"), 2048))) * 1024 * 1024,
+		YtDlpMaxOutputBytes:    max(1, parseInt(os.Getenv("YTDLP_MAX_OUTPUT_KB"), 64)) * 1024,
+		CookiesDir:             strings.TrimSpace(os.Getenv("COOKIES_DIR")),
+		CookieCooldown:         parseDuration(os.Getenv("COOKIE_COOLDOWN"), 30*time.Minute),
+		NativeBackends:         os.Getenv("NATIVE_BACKENDS") == "" || parseBool(os.Getenv("NATIVE_BACKENDS")),
+		AdminIDs:               parseIDs(os.Getenv("ADMIN_IDS")),
 	}
 }
 
@@ -50,6 +101,40 @@ func parseAllowedHosts(s string) map[string]struct{} {
 	return out
 }
 
+// parseProxies разбирает PROXIES вида "instagram=socks5h://a:1080,socks5h://b:1080;tiktok=http://c:8080;*=http://d:3128".
+// Одиночный legacy PROXY добавляется в общий список "*".
+func parseProxies(s string, legacy string) map[string][]string {
+	out := make(map[string][]string)
+	for _, group := range strings.Split(s, ";") {
+		platform, list, ok := strings.Cut(group, "=")
+		platform = strings.ToLower(strings.TrimSpace(platform))
+		if !ok || platform == "" {
+			continue
+		}
+		for _, p := range strings.Split(list, ",") {
+			if p = strings.TrimSpace(p); p != "" {
+				out[platform] = append(out[platform], p)
+			}
+		}
+	}
+	if legacy = strings.TrimSpace(legacy); legacy != "" {
+		out["*"] = append(out["*"], legacy)
+	}
+	return out
+}
+
+func parseIDs(s string) map[int64]struct{} {
+	out := make(map[int64]struct{})
+	for _, p := range strings.Split(s, ",") {
+		id, err := strconv.ParseInt(strings.TrimSpace(p), 10, 64)
+		if err != nil {
+			continue
+		}
+		out[id] = struct{}{}
+	}
+	return out
+}
+
 func parseBool(s string) bool {
 	s = strings.TrimSpace(strings.ToLower(s))
 	return s == "1" || s == "true" || s == "yes" || s == "y"
@@ -66,3 +151,28 @@ func parseInt(s string, def int) int {
 	}
 	return n
 }
+
+// days переводит число дней из env в длительность; 0 и меньше — шаг отключён.
+func days(n int) time.Duration {
+	return time.Duration(max(0, n)) * 24 * time.Hour
+}
+
+// parseLimit — как parseDuration, но "0" выключает лимит и даёт 0.
+func parseLimit(s string, def time.Duration) time.Duration {
+	if d, err := time.ParseDuration(strings.TrimSpace(s)); err == nil && d == 0 {
+		return 0
+	}
+	return parseDuration(s, def)
+}
+
+func parseDuration(s string, def time.Duration) time.Duration {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return def
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return def
+	}
+	return d
+}
@@ -0,0 +1,192 @@
+package download
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ArtifactKind — тип файла, найденного в директории скачивания.
+type ArtifactKind string
+
+const (
+	ArtifactVideo    ArtifactKind = "video"
+	ArtifactImage    ArtifactKind = "image"    // миниатюра, фото из карусели
+	ArtifactAudio    ArtifactKind = "audio"    // отдельная звуковая дорожка
+	ArtifactSubtitle ArtifactKind = "subtitle" // .vtt/.srt/.ass
+	ArtifactMetadata ArtifactKind = "metadata" // .info.json и прочий JSON
+	ArtifactUnknown  ArtifactKind = "unknown"
+)
+
+// sniffLen — сколько байт читаем для определения типа (как http.DetectContentType).
+const sniffLen = 512
+
+// Artifact — файл результата скачивания.
+type Artifact struct {
+	Path string
+	Kind ArtifactKind
+	MIME string
+	Size int64
+}
+
+// VideoResult — результат скачивания: все файлы из временной директории.
+type VideoResult struct {
+	Dir       string // временная директория скачивания, удаляется после отправки
+	Artifacts []Artifact
+	printed   string // путь, который yt-dlp напечатал как итоговый
+}
+
+// Video выбирает основной видеофайл: напечатанный yt-dlp, если это видео, иначе самый большой.
+func (r *VideoResult) Video() (Artifact, bool) {
+	var best Artifact
+	found := false
+	for _, a := range r.Artifacts {
+		if a.Kind != ArtifactVideo {
+			continue
+		}
+		if a.Path == r.printed {
+			return a, true
+		}
+		if !found || a.Size > best.Size {
+			best, found = a, true
+		}
+	}
+	return best, found
+}
+
+// ByKind возвращает все файлы указанного типа.
+func (r *VideoResult) ByKind(kind ArtifactKind) []Artifact {
+	var out []Artifact
+	for _, a := range r.Artifacts {
+		if a.Kind == kind {
+			out = append(out, a)
+		}
+	}
+	return out
+}
+
+// collectArtifacts классифицирует файлы верхнего уровня dir по содержимому.
+// Поддиректории (копия cookies) пропускаются.
+func collectArtifacts(dir string) ([]Artifact, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var out []Artifact
+	for _, e := range entries {
+		if e.IsDir() || isPartialDownload(e.Name()) {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil || info.Size() == 0 {
+			continue
+		}
+
+		path := filepath.Join(dir, e.Name())
+		kind, mime, err := sniffFile(path)
+		if err != nil {
+			continue
+		}
+		out = append(out, Artifact{Path: path, Kind: kind, MIME: mime, Size: info.Size()})
+	}
+	return out, nil
+}
+
+// isPartialDownload — служебные файлы недокачанных фрагментов yt-dlp.
+// Их содержимое может выглядеть как видео, но оно неполное.
+func isPartialDownload(name string) bool {
+	return strings.HasSuffix(name, ".part") || strings.HasSuffix(name, ".ytdl") ||
+		strings.Contains(name, ".part-Frag") || strings.HasSuffix(name, ".temp")
+}
+
+func sniffFile(path string) (ArtifactKind, string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return ArtifactUnknown, "", err
+	}
+	defer f.Close()
+
+	head := make([]byte, sniffLen)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return ArtifactUnknown, "", err
+	}
+	kind, mime := sniff(head[:n])
+	return kind, mime, nil
+}
+
+// sniff определяет тип по первым байтам файла.
+func sniff(head []byte) (ArtifactKind, string) {
+	// ISO BMFF: бренд ftyp отличает m4a от mp4/mov
+	if len(head) >= 12 && string(head[4:8]) == "ftyp" {
+		switch string(head[8:12]) {
+		case "M4A ", "M4B ", "M4P ", "F4A ":
+			return ArtifactAudio, "audio/mp4"
+		case "qt  ":
+			return ArtifactVideo, "video/quicktime"
+		default:
+			return ArtifactVideo, "video/mp4"
+		}
+	}
+	// EBML (Matroska/WebM)
+	if bytes.HasPrefix(head, []byte{0x1A, 0x45, 0xDF, 0xA3}) {
+		if bytes.Contains(head, []byte("webm")) {
+			return ArtifactVideo, "video/webm"
+		}
+		return ArtifactVideo, "video/x-matroska"
+	}
+	// MPEG-TS: пакеты по 188 байт с sync byte 0x47
+	if len(head) > 188 && head[0] == 0x47 && head[188] == 0x47 {
+		return ArtifactVideo, "video/mp2t"
+	}
+	// FLV
+	if bytes.HasPrefix(head, []byte("FLV")) {
+		return ArtifactVideo, "video/x-flv"
+	}
+
+	text := bytes.TrimLeft(bytes.TrimPrefix(head, []byte("\xEF\xBB\xBF")), " \t\r\n")
+	switch {
+	case bytes.HasPrefix(text, []byte("WEBVTT")):
+		return ArtifactSubtitle, "text/vtt"
+	case bytes.HasPrefix(text, []byte("[Script Info]")):
+		return ArtifactSubtitle, "text/x-ssa"
+	case isSRT(text):
+		return ArtifactSubtitle, "application/x-subrip"
+	case bytes.HasPrefix(text, []byte("{")), bytes.HasPrefix(text, []byte("[")):
+		return ArtifactMetadata, "application/json"
+	}
+
+	mime := http.DetectContentType(head)
+	switch {
+	case strings.HasPrefix(mime, "image/"):
+		return ArtifactImage, mime
+	case strings.HasPrefix(mime, "audio/"):
+		return ArtifactAudio, mime
+	case strings.HasPrefix(mime, "video/"):
+		return ArtifactVideo, mime
+	default:
+		return ArtifactUnknown, mime
+	}
+}
+
+// isSRT — первая строка номер реплики, вторая — тайминг "00:00:01,000 --> ...".
+func isSRT(text []byte) bool {
+	lines := bytes.SplitN(text, []byte("\n"), 3)
+	if len(lines) < 2 {
+		return false
+	}
+	first := bytes.TrimSpace(lines[0])
+	if len(first) == 0 {
+		return false
+	}
+	for _, c := range first {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return bytes.Contains(lines[1], []byte("-->"))
+}
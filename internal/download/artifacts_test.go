@@ -0,0 +1,37 @@
+package download
+
+import "testing"
+
+func TestSniff(t *testing.T) {
+	ts := make([]byte, 400)
+	ts[0], ts[188] = 0x47, 0x47
+
+	tests := []struct {
+		name string
+		head string
+		want ArtifactKind
+	}{
+		{"mp4", "\x00\x00\x00\x18ftypisom\x00\x00\x02\x00", ArtifactVideo},
+		{"mov", "\x00\x00\x00\x14ftypqt  \x00\x00\x00\x00", ArtifactVideo},
+		{"m4a", "\x00\x00\x00\x1cftypM4A \x00\x00\x00\x00", ArtifactAudio},
+		{"webm", "\x1a\x45\xdf\xa3\x9f\x42\x86\x81\x01\x42\x82\x84webm", ArtifactVideo},
+		{"mpeg-ts", string(ts), ArtifactVideo},
+		{"jpeg", "\xff\xd8\xff\xe0\x00\x10JFIF", ArtifactImage},
+		{"png", "\x89PNG\r\n\x1a\n", ArtifactImage},
+		{"webp thumbnail", "RIFF\x00\x00\x00\x00WEBPVP8 ", ArtifactImage},
+		{"mp3", "ID3\x03\x00\x00\x00", ArtifactAudio},
+		{"vtt", "\xef\xbb\xbfWEBVTT\n\n00:00.000 --> 00:01.000\nhi\n", ArtifactSubtitle},
+		{"srt", "1\r\n00:00:01,000 --> 00:00:02,000\r\nhi\r\n", ArtifactSubtitle},
+		{"ass", "[Script Info]\nTitle: x\n", ArtifactSubtitle},
+		{"info.json", `{"id": "123", "title": "x"}`, ArtifactMetadata},
+		{"plain text", "hello world", ArtifactUnknown},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, mime := sniff([]byte(tt.head)); got != tt.want {
+				t.Errorf("sniff() = %s (%s), want %s", got, mime, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,283 @@
+package download
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// CookieJar — один файл cookies (формат Netscape cookies.txt) для платформы.
+// Содержимое файла никогда не логируется — только имя. В память его читают
+// лишь нативные backend'ы на время одного запроса.
+type CookieJar struct {
+	Platform string
+	Name     string // имя файла, безопасно для логов
+	path     string
+
+	// Поля ниже защищены CookieJars.mu.
+	cooldownUntil time.Time
+	failures      int
+	successes     int
+}
+
+// CookieHealth — снимок состояния cookie-файла для логов и админ-команды.
+type CookieHealth struct {
+	Platform      string
+	Name          string
+	Available     bool
+	CooldownUntil time.Time
+	Failures      int
+	Successes     int
+}
+
+// CookieJars — наборы cookies по платформам с ротацией: при ошибке авторизации
+// файл уходит на cooldown, и следующая попытка берёт следующий.
+type CookieJars struct {
+	mu       sync.Mutex
+	jars     map[string][]*CookieJar // платформа → файлы
+	next     map[string]int          // индекс round-robin
+	cooldown time.Duration
+	log      *zap.Logger
+}
+
+// LoadCookieJars читает директорию вида dir/<платформа>/*.txt.
+// Пустой dir — cookies не используются. Файлы не в формате Netscape пропускаются.
+func LoadCookieJars(dir string, cooldown time.Duration, log *zap.Logger) (*CookieJars, error) {
+	c := &CookieJars{
+		jars:     make(map[string][]*CookieJar),
+		next:     make(map[string]int),
+		cooldown: cooldown,
+		log:      log,
+	}
+	if dir == "" {
+		return c, nil
+	}
+
+	platforms, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cookies dir: %w", err)
+	}
+
+	for _, p := range platforms {
+		if !p.IsDir() {
+			continue
+		}
+		platform := strings.ToLower(p.Name())
+		files, err := os.ReadDir(filepath.Join(dir, p.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read cookies dir: %w", err)
+		}
+		for _, f := range files {
+			if f.IsDir() {
+				continue
+			}
+			path := filepath.Join(dir, p.Name(), f.Name())
+			if !isNetscapeCookieFile(path) {
+				log.Warn("skipping cookie file: not in Netscape format",
+					zap.String("platform", platform),
+					zap.String("name", f.Name()),
+				)
+				continue
+			}
+			c.jars[platform] = append(c.jars[platform], &CookieJar{Platform: platform, Name: f.Name(), path: path})
+		}
+	}
+
+	for platform, jars := range c.jars {
+		names := make([]string, 0, len(jars))
+		for _, jar := range jars {
+			names = append(names, jar.Name)
+		}
+		log.Info("cookie jars loaded", zap.String("platform", platform), zap.Strings("names", names))
+	}
+	return c, nil
+}
+
+// Count возвращает число cookie-файлов платформы.
+func (c *CookieJars) Count(platform string) int {
+	if c == nil {
+		return 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.jars[platform])
+}
+
+// Acquire выбирает следующий по кругу файл платформы, не находящийся на cooldown.
+// exclude — файлы, уже опробованные в этом скачивании. nil — подходящих нет.
+func (c *CookieJars) Acquire(platform string, exclude map[*CookieJar]bool) *CookieJar {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	jars := c.jars[platform]
+	now := time.Now()
+	for i := 0; i < len(jars); i++ {
+		idx := (c.next[platform] + i) % len(jars)
+		jar := jars[idx]
+		if exclude[jar] || now.Before(jar.cooldownUntil) {
+			continue
+		}
+		c.next[platform] = idx + 1
+		return jar
+	}
+	return nil
+}
+
+// ReportFailure отправляет файл на cooldown после ошибки авторизации.
+func (c *CookieJars) ReportFailure(jar *CookieJar) {
+	c.mu.Lock()
+	jar.failures++
+	jar.cooldownUntil = time.Now().Add(c.cooldown)
+	failures := jar.failures
+	c.mu.Unlock()
+
+	c.log.Warn("cookie jar rejected by platform, cooling down",
+		zap.String("platform", jar.Platform),
+		zap.String("name", jar.Name),
+		zap.Int("failures", failures),
+		zap.Duration("cooldown", c.cooldown),
+	)
+}
+
+// ReportSuccess отмечает удачное скачивание с этим файлом.
+func (c *CookieJars) ReportSuccess(jar *CookieJar) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	jar.successes++
+}
+
+// Health возвращает состояние всех файлов, отсортированное по платформе и имени.
+func (c *CookieJars) Health() []CookieHealth {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	var out []CookieHealth
+	for _, jars := range c.jars {
+		for _, jar := range jars {
+			out = append(out, CookieHealth{
+				Platform:      jar.Platform,
+				Name:          jar.Name,
+				Available:     !now.Before(jar.cooldownUntil),
+				CooldownUntil: jar.cooldownUntil,
+				Failures:      jar.failures,
+				Successes:     jar.successes,
+			})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Platform != out[j].Platform {
+			return out[i].Platform < out[j].Platform
+		}
+		return out[i].Name < out[j].Name
+	})
+	return out
+}
+
+// copyTo копирует файл в dir: yt-dlp перезаписывает --cookies при выходе,
+// а один и тот же файл могут одновременно использовать несколько скачиваний.
+func (jar *CookieJar) copyTo(dir string) (string, error) {
+	src, err := os.Open(jar.path)
+	if err != nil {
+		return "", err
+	}
+	defer src.Close()
+
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", err
+	}
+	dstPath := filepath.Join(dir, "cookies.txt")
+	dst, err := os.OpenFile(dstPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		return "", err
+	}
+	return dstPath, dst.Close()
+}
+
+// httpCookies разбирает файл в cookies для net/http. Строки с неверным числом полей пропускаются.
+func (jar *CookieJar) httpCookies() ([]*http.Cookie, error) {
+	f, err := os.Open(jar.path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var out []*http.Cookie
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		httpOnly := strings.HasPrefix(line, "#HttpOnly_")
+		line = strings.TrimPrefix(line, "#HttpOnly_")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		// domain, include subdomains, path, secure, expires, name, value
+		fields := strings.Split(line, "\t")
+		if len(fields) != 7 {
+			continue
+		}
+		cookie := &http.Cookie{
+			Domain:   fields[0],
+			Path:     fields[2],
+			Secure:   strings.EqualFold(fields[3], "TRUE"),
+			Name:     fields[5],
+			Value:    fields[6],
+			HttpOnly: httpOnly,
+		}
+		if expires, err := strconv.ParseInt(fields[4], 10, 64); err == nil && expires > 0 {
+			cookie.Expires = time.Unix(expires, 0)
+		}
+		out = append(out, cookie)
+	}
+	return out, scanner.Err()
+}
+
+// isNetscapeCookieFile проверяет формат по заголовку или первой строке с данными
+// (7 полей через табуляцию), не сохраняя содержимое.
+func isNetscapeCookieFile(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "":
+			continue
+		case strings.HasPrefix(line, "# Netscape HTTP Cookie File"), strings.HasPrefix(line, "# HTTP Cookie File"):
+			return true
+		case strings.HasPrefix(line, "#HttpOnly_"):
+			return len(strings.Split(line, "\t")) == 7
+		case strings.HasPrefix(line, "#"):
+			continue
+		default:
+			return len(strings.Split(line, "\t")) == 7
+		}
+	}
+	return false
+}
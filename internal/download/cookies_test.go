@@ -0,0 +1,82 @@
+package download
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func writeCookieFile(t *testing.T, dir, platform, name, value string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Join(dir, platform), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	content := "# Netscape HTTP Cookie File\n.instagram.com\tTRUE\t/\tTRUE\t0\tsessionid\t" + value + "\n"
+	if err := os.WriteFile(filepath.Join(dir, platform, name), []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDownloadVideoRotatesCookieJarsOnAuthFailure(t *testing.T) {
+	dir := t.TempDir()
+	writeCookieFile(t, dir, "instagram", "a_expired.txt", "expired")
+	writeCookieFile(t, dir, "instagram", "b_fresh.txt", "fresh")
+	if err := os.WriteFile(filepath.Join(dir, "instagram", "notes.md"), []byte("not cookies"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	jars, err := LoadCookieJars(dir, time.Hour, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := jars.Count("instagram"); got != 2 {
+		t.Fatalf("Count() = %d, want 2 (notes.md must be skipped)", got)
+	}
+
+	bin := fakeYtDlp(t, `
+COOKIES=""
+prev=""
+for arg in "$@"; do
+	if [ "$prev" = "--cookies" ]; then COOKIES="$arg"; fi
+	prev="$arg"
+done
+if ! grep -q fresh "$COOKIES"; then
+	echo "ERROR: [Instagram] DbJLODStVAd: Requested content is not available, rate-limit reached or login required" >&2
+	exit 1
+fi
+file=$(echo "$OUT" | sed 's/%(ext)s/mp4/')
+printf 'video' > "$file"
+echo "$file"
+`)
+
+	opts := testOptions(t, bin)
+	opts.Platform = "instagram"
+	opts.Cookies = jars
+
+	if _, err := DownloadVideo(context.Background(), "https://www.instagram.com/reel/DbJLODStVAd/", opts, zap.NewNop()); err != nil {
+		t.Fatalf("DownloadVideo() error = %v", err)
+	}
+
+	health := jars.Health()
+	if len(health) != 2 {
+		t.Fatalf("Health() len = %d, want 2", len(health))
+	}
+	expired, fresh := health[0], health[1]
+	if expired.Available || expired.Failures != 1 {
+		t.Errorf("expired jar = %+v, want on cooldown with 1 failure", expired)
+	}
+	if !fresh.Available || fresh.Successes != 1 {
+		t.Errorf("fresh jar = %+v, want available with 1 success", fresh)
+	}
+
+	// Отвергнутый файл на cooldown — следующий Acquire его пропускает.
+	for i := 0; i < 3; i++ {
+		if jar := jars.Acquire("instagram", nil); jar == nil || jar.Name != "b_fresh.txt" {
+			t.Fatalf("Acquire() = %v, want b_fresh.txt", jar)
+		}
+	}
+}
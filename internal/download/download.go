@@ -1,39 +1,139 @@
 package download
 
 import (
-	"bytes"
 	"context"
 	"errors"
 	"fmt"
+	"net/http"
 	"os"
-	"os/exec"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"xa4yy_vidsave/internal/proc"
 
 	"go.uber.org/zap"
 )
 
-var (
-	ErrVideoNotFound    = errors.New("video not found")
-	ErrYtDlp            = errors.New("yt-dlp error")
-	ErrYtDlpAuth        = errors.New("yt-dlp authentication required")
-	ErrYtDlpUnsupported = errors.New("yt-dlp unsupported url")
-)
+// Options — настройки скачивания.
+type Options struct {
+	// Proxies — пул прокси по платформам (может быть nil — без прокси).
+	Proxies *ProxyPool
+	// WorkDir — где создаются временные директории скачиваний.
+	WorkDir *WorkDir
+	// Binary — путь к yt-dlp (пусто — ищем "yt-dlp" в PATH).
+	Binary string
+	// Limits — лимиты ресурсов процесса yt-dlp и его потомков.
+	Limits proc.Limits
+	// Platform — платформа ссылки ("instagram", "tiktok"), по ней выбираются cookies.
+	Platform string
+	// Cookies — cookie-файлы платформ (может быть nil).
+	Cookies *CookieJars
+	// Native — extractor на Go, который пробуется до yt-dlp (nil — сразу yt-dlp).
+	Native Backend
+	// MaxFilesize — лимит размера скачиваемого файла (0 — defaultMaxFilesize).
+	MaxFilesize int64
+	// Transport — HTTP-транспорт нативных backend'ов (nil — стандартный с прокси платформы).
+	Transport http.RoundTripper
+}
 
-// VideoResult содержит путь к скачанному файлу.
-type VideoResult struct {
-	FilePath string
+// defaultMaxFilesize — 50 MB, лимит Telegram Bot API.
+const defaultMaxFilesize int64 = 50 * 1024 * 1024
+
+func (o Options) binary() string {
+	if o.Binary != "" {
+		return o.Binary
+	}
+	return "yt-dlp"
+}
+
+func (o Options) maxFilesize() int64 {
+	if o.MaxFilesize > 0 {
+		return o.MaxFilesize
+	}
+	return defaultMaxFilesize
 }
 
+// maxDownloadAttempts — сколько раз за один вызов пробуем разные cookies/прокси.
+const maxDownloadAttempts = 5
+
 // DownloadVideo скачивает видео по оригинальному URL через yt-dlp.
 // Работает с Instagram Reels, TikTok и другими поддерживаемыми сайтами.
-// Возвращает путь к временному файлу (без водяного знака).
-// proxy — строка вида "socks5h://host:port" или "http://host:port" (может быть пустой).
-func DownloadVideo(ctx context.Context, rawURL string, proxy string, log *zap.Logger) (*VideoResult, error) {
-	// Создаём временную директорию для скачивания
-	tmpDir, err := os.MkdirTemp("", "vidsave_*")
+// Возвращает все файлы из временной директории внутри opts.WorkDir (видео, миниатюры,
+// субтитры...), классифицированные по содержимому; основное видео — result.Video().
+// Если задан opts.Native, сначала пробует его и откатывается на yt-dlp при неудаче.
+func DownloadVideo(ctx context.Context, rawURL string, opts Options, log *zap.Logger) (*VideoResult, error) {
+	return withNative(ctx, opts, log, "download",
+		func(native Backend) (*VideoResult, error) {
+			return native.Download(ctx, rawURL, opts, log)
+		},
+		func() (*VideoResult, error) {
+			return withRotation(opts, log, func(jar *CookieJar, proxy string) (*VideoResult, error) {
+				return downloadOnce(ctx, rawURL, opts, jar, proxy, log)
+			})
+		},
+	)
+}
+
+// withRotation выполняет attempt с cookies и прокси платформы.
+// При ошибке авторизации пробует следующий cookie-файл (отвергнутый уходит на cooldown),
+// при сетевой ошибке или rate-limit — следующий прокси (неудачный может уйти в карантин).
+func withRotation[T any](opts Options, log *zap.Logger, attempt func(jar *CookieJar, proxy string) (T, error)) (T, error) {
+	triedJars := make(map[*CookieJar]bool)
+	triedProxies := make(map[*Proxy]bool)
+	var zero T
+	var lastErr error
+
+	for i := 1; i <= maxDownloadAttempts; i++ {
+		jar := opts.Cookies.Acquire(opts.Platform, triedJars)
+		if jar == nil && len(triedJars) > 0 {
+			// Все cookie-файлы платформы отвергнуты
+			return zero, lastErr
+		}
+		proxy := opts.Proxies.Acquire(opts.Platform, triedProxies)
+		if proxy == nil && len(triedProxies) > 0 {
+			// Все прокси платформы опробованы
+			return zero, lastErr
+		}
+
+		proxyURL := ""
+		if proxy != nil {
+			proxyURL = proxy.URL
+		}
+
+		result, err := attempt(jar, proxyURL)
+		if err == nil {
+			if jar != nil {
+				opts.Cookies.ReportSuccess(jar)
+			}
+			if proxy != nil {
+				opts.Proxies.ReportSuccess(proxy)
+			}
+			return result, nil
+		}
+		lastErr = err
+
+		switch {
+		case jar != nil && errors.Is(err, ErrYtDlpAuth):
+			opts.Cookies.ReportFailure(jar)
+			triedJars[jar] = true
+			log.Info("retrying with next cookie jar", zap.String("platform", opts.Platform))
+		case proxy != nil && (errors.Is(err, ErrYtDlpNetwork) || errors.Is(err, ErrYtDlpRateLimited)):
+			opts.Proxies.ReportFailure(proxy, err)
+			triedProxies[proxy] = true
+			log.Info("retrying via next proxy", zap.String("platform", opts.Platform))
+		default:
+			return zero, err
+		}
+	}
+	return zero, lastErr
+}
+
+// downloadOnce — одна попытка скачивания через yt-dlp (jar и proxy могут быть пустыми).
+func downloadOnce(ctx context.Context, rawURL string, opts Options, jar *CookieJar, proxy string, log *zap.Logger) (*VideoResult, error) {
+	// Создаём временную директорию для скачивания (с проверкой квоты)
+	tmpDir, err := opts.WorkDir.MkdirTemp()
 	if err != nil {
-		return nil, fmt.Errorf("failed to create temp dir: %w", err)
+		return nil, err
 	}
 
 	outTemplate := filepath.Join(tmpDir, "video.%(ext)s")
@@ -44,8 +144,8 @@ func DownloadVideo(ctx context.Context, rawURL string, proxy string, log *zap.Lo
 		"--no-overwrites",
 		"-f", "best",
 		"-o", outTemplate,
-		// Лимит размера файла — 50 MB (Telegram Bot API limit)
-		"--max-filesize", "50M",
+		// Лимит размера файла (по умолчанию 50 MB — Telegram Bot API limit)
+		"--max-filesize", strconv.FormatInt(opts.maxFilesize(), 10),
 		// Таймаут на сокет-операции (не зависать вечно)
 		"--socket-timeout", "30",
 		// Количество ретраев при ошибках сети
@@ -54,84 +154,107 @@ func DownloadVideo(ctx context.Context, rawURL string, proxy string, log *zap.Lo
 		"--print", "after_move:filepath",
 	}
 
-	if proxy != "" {
-		args = append(args, "--proxy", proxy)
-	}
-
-	args = append(args, rawURL)
-
-	log.Debug("running yt-dlp", zap.Strings("args", args))
-
-	cmd := exec.CommandContext(ctx, "yt-dlp", args...)
-
-	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
-
-	if err := cmd.Run(); err != nil {
-		downloadErr := classifyYtDlpError(stderr.String())
-		log.Error("yt-dlp failed",
-			zap.Error(err),
-			zap.String("classified_error", downloadErr.Error()),
-			zap.String("stderr", stderr.String()),
-			zap.String("stdout", stdout.String()),
-		)
-		os.RemoveAll(tmpDir)
-		return nil, fmt.Errorf("%w: %s", downloadErr, stderr.String())
+	out, err := runYtDlp(ctx, rawURL, args, tmpDir, opts, jar, proxy, log)
+	if err != nil {
+		opts.WorkDir.Remove(tmpDir, "yt-dlp failed")
+		return nil, err
 	}
 
-	// --print after_move:filepath выводит путь к итоговому файлу в stdout
-	filePath := strings.TrimSpace(stdout.String())
-	log.Debug("yt-dlp output path", zap.String("raw_stdout", filePath))
+	// --print after_move:filepath выводит путь к итоговому файлу в stdout (последняя строка)
+	printed := lastLine(out.Stdout)
+	log.Debug("yt-dlp output path", zap.String("raw_stdout", printed))
 
-	// Если путь пустой или файла нет — ищем любой файл в tmp-директории
-	if filePath == "" || !fileExists(filePath) {
-		log.Debug("printed path not found, scanning tmpDir", zap.String("tmpDir", tmpDir))
-		filePath = findFirstFile(tmpDir)
+	artifacts, err := collectArtifacts(tmpDir)
+	if err != nil {
+		opts.WorkDir.Remove(tmpDir, "failed to list output")
+		return nil, fmt.Errorf("failed to list download dir: %w", err)
 	}
 
-	if filePath == "" {
+	if len(artifacts) == 0 {
 		// Логируем содержимое tmpDir для отладки
 		entries, _ := os.ReadDir(tmpDir)
 		names := make([]string, 0, len(entries))
 		for _, e := range entries {
 			names = append(names, e.Name())
 		}
-		log.Error("no files found in tmpDir", zap.Strings("files", names))
-		os.RemoveAll(tmpDir)
+		log.Error("no files found in tmpDir",
+			zap.Strings("files", names),
+			zap.String("stdout", out.Stdout),
+		)
+		opts.WorkDir.Remove(tmpDir, "no output file")
+		// yt-dlp пропускает видео без ошибки (например, больше --max-filesize) —
+		// причину ищем в выводе.
+		if classified := classifyYtDlpError(out.Stdout + "\n" + out.Stderr); classified != ErrYtDlp {
+			return nil, classified
+		}
 		return nil, ErrVideoNotFound
 	}
 
-	log.Info("video downloaded", zap.String("path", filePath))
-	return &VideoResult{FilePath: filePath}, nil
+	result := &VideoResult{Dir: tmpDir, Artifacts: artifacts, printed: printed}
+	for _, a := range artifacts {
+		log.Debug("download artifact",
+			zap.String("path", a.Path),
+			zap.String("kind", string(a.Kind)),
+			zap.String("mime", a.MIME),
+			zap.Int64("size", a.Size),
+		)
+	}
+	if video, ok := result.Video(); ok {
+		log.Info("video downloaded", zap.String("path", video.Path), zap.Int("artifacts", len(artifacts)))
+	} else {
+		log.Warn("download has no video artifact", zap.Int("artifacts", len(artifacts)))
+	}
+	return result, nil
 }
 
-func fileExists(path string) bool {
-	info, err := os.Stat(path)
-	return err == nil && !info.IsDir()
-}
+// runYtDlp запускает yt-dlp с args, добавив cookies (копия кладётся в dir), прокси и URL.
+// Ошибка классифицируется по stderr; отмена ctx возвращается как есть.
+func runYtDlp(ctx context.Context, rawURL string, args []string, dir string, opts Options, jar *CookieJar, proxy string, log *zap.Logger) (proc.Result, error) {
+	if jar != nil {
+		// Копия лежит в поддиректории, чтобы не попасться среди результатов скачивания
+		cookiesPath, err := jar.copyTo(filepath.Join(dir, "cookies"))
+		if err != nil {
+			return proc.Result{}, fmt.Errorf("failed to prepare cookies: %w", err)
+		}
+		args = append(args, "--cookies", cookiesPath)
+		log.Debug("using cookie jar", zap.String("platform", jar.Platform), zap.String("name", jar.Name))
+	}
 
-func findFirstFile(dir string) string {
-	entries, err := os.ReadDir(dir)
-	if err != nil {
-		return ""
+	args = append(args, rawURL)
+
+	log.Debug("running yt-dlp", zap.Strings("args", args), zap.String("proxy", RedactProxy(proxy)))
+
+	// Прокси добавляем после логирования: в URL могут быть логин и пароль
+	if proxy != "" {
+		args = append([]string{"--proxy", proxy}, args...)
 	}
-	for _, e := range entries {
-		if !e.IsDir() {
-			return filepath.Join(dir, e.Name())
-		}
+
+	out, err := proc.Run(ctx, opts.binary(), args, opts.Limits)
+	if err == nil {
+		return out, nil
 	}
-	return ""
+
+	// Отмена или таймаут — yt-dlp убит вместе с группой, stderr тут не показателен.
+	if ctx.Err() != nil {
+		log.Info("yt-dlp interrupted", zap.Error(ctx.Err()))
+		return out, fmt.Errorf("yt-dlp interrupted: %w", ctx.Err())
+	}
+
+	classified := classifyYtDlpError(out.Stderr)
+	log.Error("yt-dlp failed",
+		zap.Error(err),
+		zap.String("classified_error", classified.Error()),
+		zap.String("stderr", out.Stderr),
+		zap.String("stdout", out.Stdout),
+		zap.Bool("output_truncated", out.Truncated),
+	)
+	return out, fmt.Errorf("%w: %s", classified, out.Stderr)
 }
 
-func classifyYtDlpError(stderr string) error {
-	lower := strings.ToLower(stderr)
-	switch {
-	case strings.Contains(lower, "login required"), strings.Contains(lower, "cookies"), strings.Contains(lower, "authentication"):
-		return ErrYtDlpAuth
-	case strings.Contains(lower, "unsupported url"):
-		return ErrYtDlpUnsupported
-	default:
-		return ErrYtDlp
+func lastLine(s string) string {
+	s = strings.TrimSpace(s)
+	if i := strings.LastIndexByte(s, '\n'); i >= 0 {
+		s = s[i+1:]
 	}
+	return strings.TrimSpace(s)
 }
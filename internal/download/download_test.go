@@ -1,6 +1,20 @@
 package download
 
-import "testing"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+	"xa4yy_vidsave/internal/proc"
+
+	"go.uber.org/zap"
+)
 
 func TestClassifyYtDlpError(t *testing.T) {
 	tests := []struct {
@@ -18,6 +32,81 @@ func TestClassifyYtDlpError(t *testing.T) {
 			stderr: "ERROR: Unsupported URL: https://www.tiktok.com/@user/photo/123",
 			want:   ErrYtDlpUnsupported,
 		},
+		{
+			name:   "instagram empty response needs login",
+			stderr: "ERROR: [Instagram] C8xQ2lJtPqR: Main webpage is locked behind the login page. Unable to extract video url; please report this issue on  https://github.com/yt-dlp/yt-dlp/issues?q= , filling out the appropriate issue template. Confirm you are on the latest version using  yt-dlp -U (authentication)",
+			want:   ErrYtDlpAuth,
+		},
+		{
+			name:   "youtube private video suggests cookies",
+			stderr: "ERROR: [youtube] dQw4w9WgXcQ: Private video. Sign in if you've been granted access to this video. Use --cookies-from-browser or --cookies for the authentication. See  https://github.com/yt-dlp/yt-dlp/wiki/FAQ#how-do-i-pass-cookies-to-yt-dlp  for how to manually pass cookies",
+			want:   ErrYtDlpPrivate,
+		},
+		{
+			name:   "tiktok private video",
+			stderr: "ERROR: [TikTok] 7312345678901234567: This video is private",
+			want:   ErrYtDlpPrivate,
+		},
+		{
+			name:   "youtube removed by uploader",
+			stderr: "ERROR: [youtube] a1b2c3d4e5f: Video unavailable. This video has been removed by the uploader",
+			want:   ErrYtDlpRemoved,
+		},
+		{
+			name:   "tiktok video not available",
+			stderr: "ERROR: [TikTok] 7312345678901234567: Video not available, status code 10204",
+			want:   ErrYtDlpRemoved,
+		},
+		{
+			name:   "instagram 404",
+			stderr: "ERROR: [Instagram] DbJLODStVAd: Unable to download webpage: HTTP Error 404: Not Found (caused by <HTTPError 404: Not Found>)",
+			want:   ErrYtDlpRemoved,
+		},
+		{
+			name:   "geo restricted",
+			stderr: "ERROR: [vimeo] 123456: The uploader has not made this video available in your country. This video is available in: RU, BY. You might want to use a VPN or a proxy server (with --proxy) to workaround.",
+			want:   ErrYtDlpGeoRestricted,
+		},
+		{
+			name:   "tiktok blocked ip",
+			stderr: "ERROR: [TikTok] 7312345678901234567: Your IP address is blocked from accessing this post",
+			want:   ErrYtDlpRateLimited,
+		},
+		{
+			name:   "rate limited",
+			stderr: "ERROR: [TikTok] 7312345678901234567: Unable to download webpage: HTTP Error 429: Too Many Requests (caused by <HTTPError 429: Too Many Requests>)",
+			want:   ErrYtDlpRateLimited,
+		},
+		{
+			name:   "file larger than max-filesize",
+			stderr: "[download] File is larger than max-filesize (73400320 bytes > 52428800 bytes). Aborting.",
+			want:   ErrYtDlpTooLarge,
+		},
+		{
+			name:   "live event not started",
+			stderr: "ERROR: [youtube] jfKfPfyJRdk: This live event will begin in 3 hours.",
+			want:   ErrYtDlpLiveStream,
+		},
+		{
+			name:   "age restricted",
+			stderr: "ERROR: [youtube] a1b2c3d4e5f: Sign in to confirm your age. This video may be inappropriate for some users. Use --cookies-from-browser or --cookies for the authentication.",
+			want:   ErrYtDlpAgeRestricted,
+		},
+		{
+			name:   "tiktok sensitive content",
+			stderr: "ERROR: [TikTok] 7312345678901234567: This post may not be comfortable for some audiences. Log in for access. Use --cookies-from-browser or --cookies for the authentication.",
+			want:   ErrYtDlpAgeRestricted,
+		},
+		{
+			name:   "proxy unreachable",
+			stderr: "ERROR: [TikTok] 7312345678901234567: Unable to download webpage: ('Unable to connect to proxy', OSError('Tunnel connection failed: 407 Proxy Authentication Required')) (caused by ProxyError(\"('Unable to connect to proxy', OSError('Tunnel connection failed: 407 Proxy Authentication Required'))\"))",
+			want:   ErrYtDlpNetwork,
+		},
+		{
+			name:   "read timed out",
+			stderr: "ERROR: [Instagram] DbJLODStVAd: Unable to download webpage: The read operation timed out (caused by TransportError('The read operation timed out'))",
+			want:   ErrYtDlpNetwork,
+		},
 		{
 			name:   "generic error",
 			stderr: "ERROR: something else broke",
@@ -34,3 +123,198 @@ func TestClassifyYtDlpError(t *testing.T) {
 		})
 	}
 }
+
+func TestIsRetryable(t *testing.T) {
+	retryable := []error{ErrYtDlp, ErrYtDlpRateLimited, ErrVideoNotFound, fmt.Errorf("wrapped: %w", ErrYtDlpRateLimited)}
+	permanent := []error{
+		ErrYtDlpAuth, ErrYtDlpUnsupported, ErrYtDlpPrivate, ErrYtDlpRemoved, ErrYtDlpGeoRestricted,
+		ErrYtDlpTooLarge, ErrYtDlpLiveStream, ErrYtDlpAgeRestricted, fmt.Errorf("wrapped: %w", ErrYtDlpPrivate),
+	}
+
+	for _, err := range retryable {
+		if !IsRetryable(err) {
+			t.Errorf("IsRetryable(%v) = false, want true", err)
+		}
+	}
+	for _, err := range permanent {
+		if IsRetryable(err) {
+			t.Errorf("IsRetryable(%v) = true, want false", err)
+		}
+	}
+}
+
+func TestDownloadVideoReportsSkippedOversizeFile(t *testing.T) {
+	bin := fakeYtDlp(t, `
+echo "[download] File is larger than max-filesize (73400320 bytes > 52428800 bytes). Aborting."
+`)
+
+	_, err := DownloadVideo(context.Background(), "https://www.tiktok.com/@u/video/1", testOptions(t, bin), zap.NewNop())
+	if !errors.Is(err, ErrYtDlpTooLarge) {
+		t.Fatalf("DownloadVideo() error = %v, want ErrYtDlpTooLarge", err)
+	}
+}
+
+// fakeYtDlp пишет shell-скрипт, притворяющийся yt-dlp, и возвращает путь к нему.
+// Скрипт получает те же аргументы, что и настоящий yt-dlp; $OUT — значение -o.
+func fakeYtDlp(t *testing.T, body string) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("fake yt-dlp needs /bin/sh")
+	}
+
+	script := `#!/bin/sh
+OUT=""
+prev=""
+for arg in "$@"; do
+	if [ "$prev" = "-o" ]; then OUT="$arg"; fi
+	prev="$arg"
+done
+` + body
+	path := filepath.Join(t.TempDir(), "yt-dlp")
+	if err := os.WriteFile(path, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func testOptions(t *testing.T, binary string) Options {
+	t.Helper()
+	workDir, err := NewWorkDir(t.TempDir(), 0, 0, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return Options{
+		WorkDir: workDir,
+		Binary:  binary,
+		Limits:  proc.Limits{CPUTime: time.Minute, Memory: 512 * 1024 * 1024, MaxOutput: 4096},
+	}
+}
+
+func TestDownloadVideoWithFakeYtDlp(t *testing.T) {
+	bin := fakeYtDlp(t, `
+file=$(echo "$OUT" | sed 's/%(ext)s/mp4/')
+printf '\000\000\000\030ftypisom fake video' > "$file"
+echo "[download] 100%"
+echo "$file"
+`)
+
+	result, err := DownloadVideo(context.Background(), "https://www.tiktok.com/@u/video/1", testOptions(t, bin), zap.NewNop())
+	if err != nil {
+		t.Fatalf("DownloadVideo() error = %v", err)
+	}
+	video, ok := result.Video()
+	if !ok || filepath.Base(video.Path) != "video.mp4" {
+		t.Fatalf("Video() = %+v, %v, want video.mp4", video, ok)
+	}
+	data, err := os.ReadFile(video.Path)
+	if err != nil || !strings.HasSuffix(string(data), "fake video") {
+		t.Fatalf("downloaded file = %q, %v", data, err)
+	}
+}
+
+func TestDownloadVideoPicksVideoAmongArtifacts(t *testing.T) {
+	// Миниатюра, метаданные и субтитры рядом с видео; путь не напечатан,
+	// а имя миниатюры идёт в списке первым.
+	bin := fakeYtDlp(t, `
+dir=$(dirname "$OUT")
+printf '\377\330\377\340 thumbnail' > "$dir/a.jpg"
+printf '{"id": "1"}' > "$dir/video.info.json"
+printf 'WEBVTT\n\n00:00.000 --> 00:01.000\nhi\n' > "$dir/video.en.vtt"
+printf '\000\000\000\030ftypmp42 video' > "$dir/video.mp4"
+printf 'partial' > "$dir/video.f1.mp4.part"
+`)
+
+	result, err := DownloadVideo(context.Background(), "https://www.tiktok.com/@u/video/1", testOptions(t, bin), zap.NewNop())
+	if err != nil {
+		t.Fatalf("DownloadVideo() error = %v", err)
+	}
+	if len(result.Artifacts) != 4 {
+		t.Fatalf("Artifacts = %+v, want 4 (partial skipped)", result.Artifacts)
+	}
+	video, ok := result.Video()
+	if !ok || filepath.Base(video.Path) != "video.mp4" {
+		t.Fatalf("Video() = %+v, %v, want video.mp4", video, ok)
+	}
+	for kind, want := range map[ArtifactKind]string{
+		ArtifactImage:    "a.jpg",
+		ArtifactMetadata: "video.info.json",
+		ArtifactSubtitle: "video.en.vtt",
+	} {
+		got := result.ByKind(kind)
+		if len(got) != 1 || filepath.Base(got[0].Path) != want {
+			t.Errorf("ByKind(%s) = %+v, want %s", kind, got, want)
+		}
+	}
+}
+
+func TestDownloadVideoCapsOutputAndClassifiesTail(t *testing.T) {
+	bin := fakeYtDlp(t, `
+i=0
+while [ $i -lt 2000 ]; do
+	echo "[debug] some very noisy yt-dlp diagnostics line number $i" >&2
+	i=$((i+1))
+done
+echo "ERROR: Unsupported URL: https://example.com" >&2
+exit 1
+`)
+
+	opts := testOptions(t, bin)
+	_, err := DownloadVideo(context.Background(), "https://example.com", opts, zap.NewNop())
+	if !errors.Is(err, ErrYtDlpUnsupported) {
+		t.Fatalf("DownloadVideo() error = %v, want ErrYtDlpUnsupported", err)
+	}
+	if len(err.Error()) > opts.Limits.MaxOutput+256 {
+		t.Fatalf("error message length = %d, want output capped near %d", len(err.Error()), opts.Limits.MaxOutput)
+	}
+
+	dirs, _ := opts.WorkDir.downloadDirs()
+	if len(dirs) != 0 {
+		t.Fatalf("tmp dirs left after failure: %v", dirs)
+	}
+}
+
+func TestDownloadVideoKillsProcessGroupOnCancel(t *testing.T) {
+	pidFile := filepath.Join(t.TempDir(), "child.pid")
+	// yt-dlp запускает «ffmpeg» (sleep) в фоне и ждёт его.
+	bin := fakeYtDlp(t, `
+sleep 60 &
+echo $! > `+pidFile+`
+wait
+`)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := DownloadVideo(ctx, "https://www.tiktok.com/@u/video/1", testOptions(t, bin), zap.NewNop())
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("DownloadVideo() error = %v, want context.DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Fatalf("DownloadVideo() returned after %v, process was not killed in time", elapsed)
+	}
+
+	raw, err := os.ReadFile(pidFile)
+	if err != nil {
+		t.Fatalf("child pid file: %v", err)
+	}
+	pid, _ := strconv.Atoi(strings.TrimSpace(string(raw)))
+
+	deadline := time.Now().Add(5 * time.Second)
+	for processAlive(pid) {
+		if time.Now().After(deadline) {
+			t.Fatalf("child process %d survived cancellation", pid)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// processAlive проверяет процесс через /proc; зомби считаются мёртвыми.
+func processAlive(pid int) bool {
+	stat, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return false
+	}
+	fields := strings.Fields(string(stat[strings.LastIndexByte(string(stat), ')')+1:]))
+	return len(fields) > 0 && fields[0] != "Z"
+}
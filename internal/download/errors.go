@@ -0,0 +1,99 @@
+package download
+
+import (
+	"errors"
+	"strings"
+)
+
+var (
+	ErrVideoNotFound      = errors.New("video not found")
+	ErrYtDlp              = errors.New("yt-dlp error")
+	ErrYtDlpAuth          = errors.New("yt-dlp authentication required")
+	ErrYtDlpUnsupported   = errors.New("yt-dlp unsupported url")
+	ErrYtDlpPrivate       = errors.New("video is private")
+	ErrYtDlpRemoved       = errors.New("video was removed")
+	ErrYtDlpGeoRestricted = errors.New("video is geo-restricted")
+	ErrYtDlpRateLimited   = errors.New("rate limited by platform")
+	ErrYtDlpTooLarge      = errors.New("video exceeds max filesize")
+	ErrYtDlpLiveStream    = errors.New("video is a live stream")
+	ErrYtDlpAgeRestricted = errors.New("video is age-restricted")
+	ErrYtDlpNetwork       = errors.New("network error")
+)
+
+// ytDlpErrorRules — признаки ошибок в выводе yt-dlp (в нижнем регистре).
+// Порядок важен: сообщения о приватных и 18+ видео тоже советуют --cookies,
+// а ошибки прокси содержат "Proxy Authentication Required", поэтому всё это
+// проверяется раньше общей ошибки авторизации.
+var ytDlpErrorRules = []struct {
+	err      error
+	patterns []string
+}{
+	{ErrYtDlpAgeRestricted, []string{
+		"confirm your age", "age-restricted", "age restricted", "inappropriate for some users",
+		"not be comfortable for some audiences",
+	}},
+	{ErrYtDlpPrivate, []string{
+		"private video", "video is private", "account is private", "this post is private",
+	}},
+	{ErrYtDlpGeoRestricted, []string{
+		"geo restriction", "geo-restrict", "available in your country", "available from your location",
+	}},
+	{ErrYtDlpRemoved, []string{
+		"has been removed", "has been deleted", "video unavailable", "no longer available",
+		"video not available, status code", "http error 404", "post was deleted",
+	}},
+	{ErrYtDlpLiveStream, []string{
+		"live event", "is live", "live stream", "currently live", "premieres in",
+	}},
+	{ErrYtDlpTooLarge, []string{
+		"larger than max-filesize",
+	}},
+	{ErrYtDlpNetwork, []string{
+		"unable to connect to proxy", "tunnel connection failed", "connection refused", "connection reset",
+		"timed out", "network is unreachable", "temporary failure in name resolution",
+		"name or service not known", "remote end closed connection", "eof occurred in violation of protocol",
+	}},
+	{ErrYtDlpAuth, []string{
+		"login required", "cookies", "authentication",
+	}},
+	{ErrYtDlpRateLimited, []string{
+		"http error 429", "too many requests", "rate limit", "rate-limit",
+		// Блокировка IP — свойство нашего адреса, а не видео: лечится другим прокси
+		"ip address is blocked",
+	}},
+	{ErrYtDlpUnsupported, []string{
+		"unsupported url",
+	}},
+}
+
+func classifyYtDlpError(stderr string) error {
+	lower := strings.ToLower(stderr)
+	for _, rule := range ytDlpErrorRules {
+		for _, pattern := range rule.patterns {
+			if strings.Contains(lower, pattern) {
+				return rule.err
+			}
+		}
+	}
+	return ErrYtDlp
+}
+
+// IsRetryable сообщает, может ли повтор скачивания дать другой результат.
+// Приватные, удалённые, слишком большие и прочие «окончательные» ошибки не ретраятся.
+func IsRetryable(err error) bool {
+	for _, permanent := range []error{
+		ErrYtDlpAuth,
+		ErrYtDlpUnsupported,
+		ErrYtDlpPrivate,
+		ErrYtDlpRemoved,
+		ErrYtDlpGeoRestricted,
+		ErrYtDlpTooLarge,
+		ErrYtDlpLiveStream,
+		ErrYtDlpAgeRestricted,
+	} {
+		if errors.Is(err, permanent) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,247 @@
+package download
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+
+	"go.uber.org/zap"
+)
+
+const (
+	instagramOrigin = "https://www.instagram.com"
+	// instagramAppID и instagramDocID — id веб-приложения и запроса PolarisPostRootQuery,
+	// те же, что использует instagram.com (и yt-dlp).
+	instagramAppID = "936619743392459"
+	instagramDocID = "8845758582119845"
+)
+
+var (
+	reInstagramShortcode = regexp.MustCompile(`/(?:reels?|p|tv)/([A-Za-z0-9_-]+)`)
+	// Старый формат embed: данные поста вызовом window.__additionalDataLoaded.
+	reInstagramAdditionalData = regexp.MustCompile(`(?s)window\.__additionalDataLoaded\(\s*'extra'\s*,\s*(\{.*?\})\s*\);`)
+	// Новый формат embed: JSON поста строкой внутри JSON ("contextJSON":"{\"gql_data\":...}").
+	reInstagramContextJSON = regexp.MustCompile(`"contextJSON"\s*:\s*("(?:[^"\\]|\\.)*")`)
+)
+
+type instagramMedia struct {
+	ID            string  `json:"id"`
+	Shortcode     string  `json:"shortcode"`
+	IsVideo       bool    `json:"is_video"`
+	VideoURL      string  `json:"video_url"`
+	VideoDuration float64 `json:"video_duration"`
+	Sidecar       *struct {
+		Edges []struct {
+			Node instagramMedia `json:"node"`
+		} `json:"edges"`
+	} `json:"edge_sidecar_to_children"`
+}
+
+// video возвращает сам пост, если это видео, или первое видео карусели.
+func (m *instagramMedia) video() *instagramMedia {
+	if m.IsVideo && m.VideoURL != "" {
+		return m
+	}
+	if m.Sidecar != nil {
+		for i := range m.Sidecar.Edges {
+			if v := m.Sidecar.Edges[i].Node.video(); v != nil {
+				return v
+			}
+		}
+	}
+	return nil
+}
+
+type instagramGraphQLResponse struct {
+	Data struct {
+		XDTShortcodeMedia *instagramMedia `json:"xdt_shortcode_media"`
+		ShortcodeMedia    *instagramMedia `json:"shortcode_media"`
+	} `json:"data"`
+}
+
+// InstagramBackend достаёт ссылку на видео рилса или поста из embed-страницы,
+// а если там её нет — из GraphQL-запроса веб-версии. Cookies платформы
+// (если настроены) подставляются в оба запроса.
+type InstagramBackend struct{}
+
+func NewInstagramBackend() *InstagramBackend {
+	return &InstagramBackend{}
+}
+
+func (*InstagramBackend) Name() string { return "instagram" }
+
+func (i *InstagramBackend) Probe(ctx context.Context, rawURL string, opts Options, log *zap.Logger) (*Metadata, error) {
+	// Ни live-статуса, ни доступности Instagram в этих данных не отдаёт — оставляем пустыми,
+	// а размер спрашиваем у CDN
+	meta, err := withNativeProxy(ctx, opts, log, func(client *http.Client) (*Metadata, error) {
+		media, err := i.extract(ctx, client, rawURL, opts, log)
+		if err != nil {
+			return nil, err
+		}
+		return &Metadata{
+			ID:       media.Shortcode,
+			Duration: media.VideoDuration,
+			Filesize: mediaSize(ctx, client, media.VideoURL, instagramOrigin+"/"),
+		}, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	log.Debug("video probed natively", zap.String("id", meta.ID), zap.Float64("duration", meta.Duration))
+	return meta, nil
+}
+
+func (i *InstagramBackend) Download(ctx context.Context, rawURL string, opts Options, log *zap.Logger) (*VideoResult, error) {
+	return withNativeProxy(ctx, opts, log, func(client *http.Client) (*VideoResult, error) {
+		media, err := i.extract(ctx, client, rawURL, opts, log)
+		if err != nil {
+			return nil, err
+		}
+		return saveMedia(ctx, client, media.VideoURL, instagramOrigin+"/", opts, log)
+	})
+}
+
+func (i *InstagramBackend) extract(ctx context.Context, client *http.Client, rawURL string, opts Options, log *zap.Logger) (*instagramMedia, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrNativeExtract, err)
+	}
+	m := reInstagramShortcode.FindStringSubmatch(u.Path)
+	if m == nil {
+		return nil, fmt.Errorf("%w: no shortcode in instagram url", ErrNativeExtract)
+	}
+	shortcode := m[1]
+
+	jar := opts.Cookies.Acquire(opts.Platform, nil)
+	if jar != nil {
+		if err := loadInstagramCookies(client, jar); err != nil {
+			return nil, fmt.Errorf("%w: failed to load cookies: %v", ErrNativeExtract, err)
+		}
+		log.Debug("using cookie jar", zap.String("platform", jar.Platform), zap.String("name", jar.Name))
+	}
+
+	media, err := i.extractFromEmbed(ctx, client, shortcode)
+	if err != nil || media == nil {
+		if err != nil {
+			log.Debug("instagram embed failed, trying graphql", zap.Error(err))
+		}
+		// Embed-страница ставит csrftoken, без которого GraphQL не отвечает
+		media, err = i.extractFromGraphQL(ctx, client, shortcode)
+	}
+
+	if jar != nil {
+		switch {
+		case err == nil:
+			opts.Cookies.ReportSuccess(jar)
+		case errors.Is(err, ErrYtDlpAuth):
+			opts.Cookies.ReportFailure(jar)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	video := media.video()
+	if video == nil {
+		return nil, fmt.Errorf("%w: instagram post %s has no video", ErrNativeExtract, shortcode)
+	}
+	if video.Shortcode == "" {
+		video.Shortcode = shortcode
+	}
+	log.Debug("instagram video url extracted", zap.String("shortcode", shortcode))
+	return video, nil
+}
+
+// extractFromEmbed разбирает /p/<code>/embed/captioned/. nil без ошибки — в embed нет данных
+// (например, для 18+ и приватных постов там только заглушка).
+func (i *InstagramBackend) extractFromEmbed(ctx context.Context, client *http.Client, shortcode string) (*instagramMedia, error) {
+	body, _, err := fetchPage(ctx, client, instagramOrigin+"/p/"+shortcode+"/embed/captioned/", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if m := reInstagramAdditionalData.FindSubmatch(body); m != nil {
+		var data struct {
+			ShortcodeMedia *instagramMedia `json:"shortcode_media"`
+		}
+		if err := json.Unmarshal(m[1], &data); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrNativeExtract, err)
+		}
+		if data.ShortcodeMedia != nil && data.ShortcodeMedia.video() != nil {
+			return data.ShortcodeMedia, nil
+		}
+	}
+
+	if m := reInstagramContextJSON.FindSubmatch(body); m != nil {
+		var raw string
+		if err := json.Unmarshal(m[1], &raw); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrNativeExtract, err)
+		}
+		var ctxData struct {
+			GqlData struct {
+				ShortcodeMedia *instagramMedia `json:"shortcode_media"`
+			} `json:"gql_data"`
+		}
+		if err := json.Unmarshal([]byte(raw), &ctxData); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrNativeExtract, err)
+		}
+		if media := ctxData.GqlData.ShortcodeMedia; media != nil && media.video() != nil {
+			return media, nil
+		}
+	}
+	return nil, nil
+}
+
+func (i *InstagramBackend) extractFromGraphQL(ctx context.Context, client *http.Client, shortcode string) (*instagramMedia, error) {
+	variables, _ := json.Marshal(map[string]string{"shortcode": shortcode})
+	form := url.Values{
+		"doc_id":    {instagramDocID},
+		"variables": {string(variables)},
+	}
+
+	headers := map[string]string{
+		"X-IG-App-ID": instagramAppID,
+		"Referer":     instagramOrigin + "/p/" + shortcode + "/",
+		"Origin":      instagramOrigin,
+	}
+	origin, _ := url.Parse(instagramOrigin)
+	for _, c := range client.Jar.Cookies(origin) {
+		if c.Name == "csrftoken" {
+			headers["X-CSRFToken"] = c.Value
+		}
+	}
+
+	body, _, err := fetch(ctx, client, http.MethodPost, instagramOrigin+"/graphql/query/", form, headers)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp instagramGraphQLResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrNativeExtract, err)
+	}
+	media := resp.Data.XDTShortcodeMedia
+	if media == nil {
+		media = resp.Data.ShortcodeMedia
+	}
+	if media == nil {
+		// Приватный, удалённый или требующий входа пост — различить может только yt-dlp
+		return nil, fmt.Errorf("%w: instagram post %s unavailable via graphql", ErrNativeExtract, shortcode)
+	}
+	return media, nil
+}
+
+// loadInstagramCookies кладёт cookies из файла в jar клиента.
+func loadInstagramCookies(client *http.Client, jar *CookieJar) error {
+	cookies, err := jar.httpCookies()
+	if err != nil {
+		return err
+	}
+	origin, _ := url.Parse(instagramOrigin)
+	client.Jar.SetCookies(origin, cookies)
+	return nil
+}
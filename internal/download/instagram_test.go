@@ -0,0 +1,130 @@
+package download
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"xa4yy_vidsave/internal/httpreplay"
+
+	"go.uber.org/zap"
+)
+
+const instagramTestURL = "https://www.instagram.com/reel/C1a2B3c4D5e/?igsh=abc"
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }
+
+func instagramTestOptions(t *testing.T, cassette, ytDlpBody string) Options {
+	t.Helper()
+	opts := testOptions(t, fakeYtDlp(t, ytDlpBody))
+	opts.Platform = "instagram"
+	opts.Native = NewInstagramBackend()
+	opts.Transport = httpreplay.Transport(t, filepath.Join("testdata", "instagram", cassette))
+	return opts
+}
+
+func TestInstagramBackendDownload(t *testing.T) {
+	tests := []struct {
+		cassette string
+		duration float64
+		size     int64 // из HEAD к CDN; 0 — в кассете HEAD нет, размер неизвестен
+	}{
+		{"reel_embed.json", 21.4, 2457600},
+		{"reel_context_json.json", 21.4, 0},
+		{"sidecar_graphql.json", 9.5, 0}, // embed без данных → GraphQL, видео из карусели
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.cassette, func(t *testing.T) {
+			opts := instagramTestOptions(t, tt.cassette, ytDlpMustNotRun)
+
+			meta, err := ProbeVideo(context.Background(), instagramTestURL, opts, zap.NewNop())
+			if err != nil {
+				t.Fatalf("ProbeVideo() error = %v", err)
+			}
+			if meta.ID != "C1a2B3c4D5e" || meta.Duration != tt.duration || meta.EstimatedSize() != tt.size {
+				t.Fatalf("ProbeVideo() = %+v", meta)
+			}
+			if meta.LiveStatus != "" || meta.Availability != "" {
+				t.Fatalf("ProbeVideo() live status, availability = %q, %q; want unknown", meta.LiveStatus, meta.Availability)
+			}
+
+			result, err := DownloadVideo(context.Background(), instagramTestURL, opts, zap.NewNop())
+			if err != nil {
+				t.Fatalf("DownloadVideo() error = %v", err)
+			}
+			video, ok := result.Video()
+			if !ok {
+				t.Fatalf("no video artifact in %+v", result.Artifacts)
+			}
+			data, err := os.ReadFile(video.Path)
+			if err != nil || !strings.HasSuffix(string(data), "reel bytes") {
+				t.Fatalf("downloaded file = %q, %v", data, err)
+			}
+		})
+	}
+}
+
+func TestInstagramBackendFallsBackToYtDlp(t *testing.T) {
+	opts := instagramTestOptions(t, "unavailable.json", `
+file=$(echo "$OUT" | sed 's/%(ext)s/mp4/')
+printf '\000\000\000\030ftypisom from yt-dlp' > "$file"
+echo "$file"
+`)
+
+	result, err := DownloadVideo(context.Background(), instagramTestURL, opts, zap.NewNop())
+	if err != nil {
+		t.Fatalf("DownloadVideo() error = %v", err)
+	}
+	video, _ := result.Video()
+	data, err := os.ReadFile(video.Path)
+	if err != nil || !strings.HasSuffix(string(data), "from yt-dlp") {
+		t.Fatalf("downloaded file = %q, %v, want yt-dlp output", data, err)
+	}
+}
+
+func TestInstagramBackendUsesCookies(t *testing.T) {
+	dir := t.TempDir()
+	writeCookieFile(t, dir, "instagram", "main.txt", "session-value")
+	jars, err := LoadCookieJars(dir, time.Hour, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	opts := instagramTestOptions(t, "login_required.json", `echo "ERROR: [Instagram] C1a2B3c4D5e: login required" >&2; exit 1`)
+	opts.Cookies = jars
+	replay := opts.Transport
+	var graphQL *http.Request
+	opts.Transport = roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		if req.Method == http.MethodPost {
+			graphQL = req
+		}
+		return replay.RoundTrip(req)
+	})
+
+	_, err = DownloadVideo(context.Background(), instagramTestURL, opts, zap.NewNop())
+	if !errors.Is(err, ErrYtDlpAuth) {
+		t.Fatalf("DownloadVideo() error = %v, want ErrYtDlpAuth", err)
+	}
+
+	if graphQL == nil {
+		t.Fatal("graphql request was not sent")
+	}
+	if c, err := graphQL.Cookie("sessionid"); err != nil || c.Value != "session-value" {
+		t.Errorf("graphql sessionid cookie = %v, %v", c, err)
+	}
+	if got := graphQL.Header.Get("X-CSRFToken"); got != "Xk2cR9fQ3bT7" {
+		t.Errorf("X-CSRFToken = %q, want token from embed response", got)
+	}
+
+	health := jars.Health()
+	if len(health) != 1 || health[0].Available || health[0].Failures != 1 {
+		t.Fatalf("cookie health = %+v, want one failure and cooldown", health)
+	}
+}
@@ -0,0 +1,278 @@
+package download
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// ErrNativeExtract — нативный extractor не смог разобрать страницу или скачать файл.
+var ErrNativeExtract = errors.New("native extractor failed")
+
+// Backend — извлечение видео на чистом Go, без запуска yt-dlp.
+// Окончательные ошибки (удалено, приватное, слишком большое) возвращаются как есть,
+// всё остальное — повод откатиться на yt-dlp.
+type Backend interface {
+	Name() string
+	Probe(ctx context.Context, rawURL string, opts Options, log *zap.Logger) (*Metadata, error)
+	Download(ctx context.Context, rawURL string, opts Options, log *zap.Logger) (*VideoResult, error)
+}
+
+const (
+	// nativeUserAgent — платформы отдают встроенный JSON только «браузерам».
+	nativeUserAgent = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/126.0.0.0 Safari/537.36"
+	// maxPageBytes — больше HTML/JSON-ответа не читаем.
+	maxPageBytes = 8 * 1024 * 1024
+	// nativeTimeout — таймаут на запрос страницы; скачивание файла ограничено только ctx.
+	nativeTimeout = 30 * time.Second
+)
+
+// withNative пробует нативный backend и откатывается на fallback (yt-dlp),
+// если backend не задан или ошибка не окончательная.
+func withNative[T any](ctx context.Context, opts Options, log *zap.Logger, op string, native func(Backend) (T, error), fallback func() (T, error)) (T, error) {
+	if opts.Native == nil {
+		return fallback()
+	}
+
+	started := time.Now()
+	result, err := native(opts.Native)
+	if err == nil {
+		log.Info("native backend succeeded",
+			zap.String("backend", opts.Native.Name()),
+			zap.String("op", op),
+			zap.Duration("took", time.Since(started)),
+		)
+		return result, nil
+	}
+	if !shouldFallback(ctx, err) {
+		return result, err
+	}
+
+	log.Warn("native backend failed, falling back to yt-dlp",
+		zap.String("backend", opts.Native.Name()),
+		zap.String("op", op),
+		zap.Error(err),
+	)
+	return fallback()
+}
+
+// shouldFallback — yt-dlp не поможет при отмене и окончательных ошибках.
+func shouldFallback(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	for _, final := range []error{ErrYtDlpRemoved, ErrYtDlpPrivate, ErrYtDlpTooLarge, ErrYtDlpLiveStream} {
+		if errors.Is(err, final) {
+			return false
+		}
+	}
+	return true
+}
+
+// withNativeProxy выполняет attempt с HTTP-клиентом через прокси платформы.
+// Как и withRotation, при сетевой ошибке или rate-limit сообщает о сбое прокси
+// и пробует следующий; об удачной попытке тоже сообщает пулу.
+func withNativeProxy[T any](ctx context.Context, opts Options, log *zap.Logger, attempt func(client *http.Client) (T, error)) (T, error) {
+	tried := make(map[*Proxy]bool)
+	var zero T
+	var lastErr error
+
+	for i := 1; i <= maxDownloadAttempts; i++ {
+		proxy := opts.Proxies.Acquire(opts.Platform, tried)
+		if proxy == nil && len(tried) > 0 {
+			// Все прокси платформы опробованы
+			return zero, lastErr
+		}
+
+		result, err := attempt(newNativeClient(opts, proxy))
+		if proxy == nil {
+			return result, err
+		}
+		if err == nil {
+			opts.Proxies.ReportSuccess(proxy)
+			return result, nil
+		}
+		lastErr = err
+
+		if ctx.Err() != nil || !(errors.Is(err, ErrYtDlpNetwork) || errors.Is(err, ErrYtDlpRateLimited)) {
+			return zero, err
+		}
+		opts.Proxies.ReportFailure(proxy, err)
+		tried[proxy] = true
+		log.Info("retrying native request via next proxy", zap.String("platform", opts.Platform))
+	}
+	return zero, lastErr
+}
+
+// newNativeClient — HTTP-клиент с cookie jar (платформы ставят токены на странице
+// и проверяют их на CDN) и прокси (nil — напрямую).
+func newNativeClient(opts Options, proxy *Proxy) *http.Client {
+	jar, _ := cookiejar.New(nil)
+	if opts.Transport != nil {
+		return &http.Client{Jar: jar, Transport: opts.Transport}
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if proxy != nil {
+		if u, err := url.Parse(proxy.URL); err == nil {
+			transport.Proxy = http.ProxyURL(u)
+		}
+	}
+	return &http.Client{Jar: jar, Transport: transport}
+}
+
+// fetchPage загружает страницу и возвращает тело и итоговый URL после редиректов.
+func fetchPage(ctx context.Context, client *http.Client, pageURL string, headers map[string]string) ([]byte, *url.URL, error) {
+	return fetch(ctx, client, http.MethodGet, pageURL, nil, headers)
+}
+
+// fetch выполняет запрос с «браузерными» заголовками; form — тело POST (nil — без тела).
+// 401/403 — ErrYtDlpAuth, 429 — ErrYtDlpRateLimited, сбой соединения — ErrYtDlpNetwork.
+// 404 — ErrNativeExtract: платформы отвечают им и на живые посты (сменился URL,
+// нужен вход), так что «удалено» решают только явные признаки страницы или yt-dlp.
+func fetch(ctx context.Context, client *http.Client, method, target string, form url.Values, headers map[string]string) ([]byte, *url.URL, error) {
+	ctx, cancel := context.WithTimeout(ctx, nativeTimeout)
+	defer cancel()
+
+	var body io.Reader
+	if form != nil {
+		body = strings.NewReader(form.Encode())
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, body)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrNativeExtract, err)
+	}
+	req.Header.Set("User-Agent", nativeUserAgent)
+	req.Header.Set("Accept-Language", "en-US,en;q=0.9")
+	if form != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrYtDlpNetwork, err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return nil, nil, fmt.Errorf("%w: status %d", ErrYtDlpAuth, resp.StatusCode)
+	case http.StatusTooManyRequests:
+		return nil, nil, ErrYtDlpRateLimited
+	default:
+		return nil, nil, fmt.Errorf("%w: status %d", ErrNativeExtract, resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxPageBytes))
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrNativeExtract, err)
+	}
+	return data, resp.Request.URL, nil
+}
+
+// mediaSize узнаёт размер файла по Content-Length ответа на HEAD; 0 — неизвестен.
+// Ошибки не важны: размер нужен только для ранней проверки лимита.
+func mediaSize(ctx context.Context, client *http.Client, mediaURL, referer string) int64 {
+	ctx, cancel := context.WithTimeout(ctx, nativeTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, mediaURL, nil)
+	if err != nil {
+		return 0
+	}
+	req.Header.Set("User-Agent", nativeUserAgent)
+	req.Header.Set("Referer", referer)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.ContentLength < 0 {
+		return 0
+	}
+	return resp.ContentLength
+}
+
+// saveMedia скачивает mediaURL в новую временную директорию, не больше opts.maxFilesize().
+func saveMedia(ctx context.Context, client *http.Client, mediaURL, referer string, opts Options, log *zap.Logger) (*VideoResult, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, mediaURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrNativeExtract, err)
+	}
+	req.Header.Set("User-Agent", nativeUserAgent)
+	req.Header.Set("Referer", referer)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrYtDlpNetwork, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: media status %d", ErrNativeExtract, resp.StatusCode)
+	}
+
+	limit := opts.maxFilesize()
+	if resp.ContentLength > limit {
+		return nil, ErrYtDlpTooLarge
+	}
+
+	dir, err := opts.WorkDir.MkdirTemp()
+	if err != nil {
+		return nil, err
+	}
+	path := filepath.Join(dir, "video.mp4")
+
+	if err := writeLimited(path, resp.Body, limit); err != nil {
+		opts.WorkDir.Remove(dir, "native download failed")
+		return nil, err
+	}
+
+	artifacts, err := collectArtifacts(dir)
+	if err != nil {
+		opts.WorkDir.Remove(dir, "failed to list output")
+		return nil, fmt.Errorf("failed to list download dir: %w", err)
+	}
+	result := &VideoResult{Dir: dir, Artifacts: artifacts, printed: path}
+	if _, ok := result.Video(); !ok {
+		opts.WorkDir.Remove(dir, "native download is not a video")
+		return nil, fmt.Errorf("%w: media response is not a video", ErrNativeExtract)
+	}
+
+	log.Info("video downloaded", zap.String("path", path), zap.Int("artifacts", len(artifacts)))
+	return result, nil
+}
+
+// writeLimited копирует r в path; больше limit байт — ErrYtDlpTooLarge.
+func writeLimited(path string, r io.Reader, limit int64) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	n, err := io.Copy(f, io.LimitReader(r, limit+1))
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrNativeExtract, err)
+	}
+	if n > limit {
+		return ErrYtDlpTooLarge
+	}
+	return nil
+}
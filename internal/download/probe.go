@@ -0,0 +1,106 @@
+package download
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"go.uber.org/zap"
+)
+
+// probeTemplate — только нужные поля метаданных одной JSON-строкой:
+// полный --dump-json с форматами и миниатюрами не влезает в лимит вывода.
+const probeTemplate = "%(.{id,duration,filesize,filesize_approx,is_live,live_status,availability})j"
+
+// Metadata — метаданные видео, полученные без скачивания.
+type Metadata struct {
+	ID             string  `json:"id"`
+	Duration       float64 `json:"duration"`        // секунды; 0 — неизвестно
+	Filesize       int64   `json:"filesize"`        // точный размер выбранного формата, если известен
+	FilesizeApprox int64   `json:"filesize_approx"` // оценка yt-dlp по битрейту
+	IsLive         bool    `json:"is_live"`
+	LiveStatus     string  `json:"live_status"`  // not_live, is_live, is_upcoming, was_live, post_live
+	Availability   string  `json:"availability"` // public, unlisted, private, needs_auth, premium_only, ...
+}
+
+// EstimatedSize — размер файла в байтах (точный или оценка); 0 — неизвестен.
+func (m *Metadata) EstimatedSize() int64 {
+	if m.Filesize > 0 {
+		return m.Filesize
+	}
+	return m.FilesizeApprox
+}
+
+// Live — эфир идёт сейчас или ещё не начался.
+func (m *Metadata) Live() bool {
+	return m.IsLive || m.LiveStatus == "is_live" || m.LiveStatus == "is_upcoming"
+}
+
+// Err переводит доступность и live-статус в типизированную ошибку (nil — можно качать).
+func (m *Metadata) Err() error {
+	switch {
+	case m.Live():
+		return ErrYtDlpLiveStream
+	case m.Availability == "private":
+		return ErrYtDlpPrivate
+	case m.Availability == "needs_auth", m.Availability == "premium_only", m.Availability == "subscriber_only":
+		return ErrYtDlpAuth
+	default:
+		return nil
+	}
+}
+
+// ProbeVideo быстро получает метаданные видео через yt-dlp без скачивания
+// (--print подразумевает --simulate). Cookies и прокси ротируются так же, как при скачивании,
+// а opts.Native, если задан, пробуется первым.
+func ProbeVideo(ctx context.Context, rawURL string, opts Options, log *zap.Logger) (*Metadata, error) {
+	return withNative(ctx, opts, log, "probe",
+		func(native Backend) (*Metadata, error) {
+			return native.Probe(ctx, rawURL, opts, log)
+		},
+		func() (*Metadata, error) {
+			return withRotation(opts, log, func(jar *CookieJar, proxy string) (*Metadata, error) {
+				return probeOnce(ctx, rawURL, opts, jar, proxy, log)
+			})
+		},
+	)
+}
+
+func probeOnce(ctx context.Context, rawURL string, opts Options, jar *CookieJar, proxy string, log *zap.Logger) (*Metadata, error) {
+	args := []string{
+		"--no-warnings",
+		"--no-playlist",
+		"-f", "best",
+		"--socket-timeout", "30",
+		"--print", probeTemplate,
+	}
+
+	// Директория нужна только под копию cookies
+	dir := ""
+	if jar != nil {
+		var err error
+		if dir, err = opts.WorkDir.MkdirTemp(); err != nil {
+			return nil, err
+		}
+		defer opts.WorkDir.Remove(dir, "probe finished")
+	}
+
+	out, err := runYtDlp(ctx, rawURL, args, dir, opts, jar, proxy, log)
+	if err != nil {
+		return nil, err
+	}
+
+	var meta Metadata
+	if err := json.Unmarshal([]byte(lastLine(out.Stdout)), &meta); err != nil {
+		return nil, fmt.Errorf("failed to parse yt-dlp metadata: %w", err)
+	}
+
+	log.Debug("video probed",
+		zap.String("id", meta.ID),
+		zap.Float64("duration", meta.Duration),
+		zap.Int64("estimated_size", meta.EstimatedSize()),
+		zap.String("live_status", meta.LiveStatus),
+		zap.String("availability", meta.Availability),
+	)
+	return &meta, nil
+}
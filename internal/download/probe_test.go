@@ -0,0 +1,69 @@
+package download
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestProbeVideoWithFakeYtDlp(t *testing.T) {
+	bin := fakeYtDlp(t, `
+case "$*" in
+	*--print*) ;;
+	*) echo "probe must not download" >&2; exit 1 ;;
+esac
+echo '{"id": "7312345678901234567", "duration": 734.5, "filesize": null, "filesize_approx": 31457280, "is_live": false, "live_status": "not_live", "availability": "public"}'
+`)
+
+	meta, err := ProbeVideo(context.Background(), "https://www.tiktok.com/@u/video/7312345678901234567", testOptions(t, bin), zap.NewNop())
+	if err != nil {
+		t.Fatalf("ProbeVideo() error = %v", err)
+	}
+	if meta.Duration != 734.5 {
+		t.Errorf("Duration = %v, want 734.5", meta.Duration)
+	}
+	if got := meta.EstimatedSize(); got != 31457280 {
+		t.Errorf("EstimatedSize() = %d, want approx size 31457280", got)
+	}
+	if err := meta.Err(); err != nil {
+		t.Errorf("Err() = %v, want nil", err)
+	}
+}
+
+func TestMetadataErr(t *testing.T) {
+	tests := []struct {
+		meta Metadata
+		want error
+	}{
+		{meta: Metadata{IsLive: true}, want: ErrYtDlpLiveStream},
+		{meta: Metadata{LiveStatus: "is_upcoming"}, want: ErrYtDlpLiveStream},
+		{meta: Metadata{LiveStatus: "was_live"}, want: nil},
+		{meta: Metadata{Availability: "private"}, want: ErrYtDlpPrivate},
+		{meta: Metadata{Availability: "needs_auth"}, want: ErrYtDlpAuth},
+		{meta: Metadata{Availability: "unlisted"}, want: nil},
+	}
+
+	for _, tt := range tests {
+		if got := tt.meta.Err(); !errors.Is(got, tt.want) || (tt.want == nil && got != nil) {
+			t.Errorf("%+v.Err() = %v, want %v", tt.meta, got, tt.want)
+		}
+	}
+}
+
+func TestProbeVideoClassifiesErrors(t *testing.T) {
+	bin := fakeYtDlp(t, `
+echo "ERROR: [TikTok] 7312345678901234567: This video is private" >&2
+exit 1
+`)
+
+	_, err := ProbeVideo(context.Background(), "https://www.tiktok.com/@u/video/7312345678901234567", testOptions(t, bin), zap.NewNop())
+	if !errors.Is(err, ErrYtDlpPrivate) {
+		t.Fatalf("ProbeVideo() error = %v, want ErrYtDlpPrivate", err)
+	}
+	if !strings.Contains(err.Error(), "private") {
+		t.Fatalf("error %q should keep yt-dlp stderr", err)
+	}
+}
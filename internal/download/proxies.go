@@ -0,0 +1,209 @@
+package download
+
+import (
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// DefaultProxyPlatform — ключ списка прокси для платформ без собственного списка.
+const DefaultProxyPlatform = "*"
+
+// ProxyStrategy — как выбирать прокси из списка платформы.
+type ProxyStrategy string
+
+const (
+	ProxyRoundRobin    ProxyStrategy = "round_robin"
+	ProxyLeastFailures ProxyStrategy = "least_failures"
+)
+
+// Proxy — прокси из пула со счётчиками здоровья.
+type Proxy struct {
+	URL string
+
+	// Поля ниже защищены ProxyPool.mu.
+	consecutiveFailures int
+	failures            int
+	successes           int
+	quarantinedUntil    time.Time
+}
+
+// ProxyHealth — снимок состояния прокси для логов и админ-команды.
+type ProxyHealth struct {
+	Platform         string
+	Proxy            string // без логина и пароля
+	Available        bool
+	QuarantinedUntil time.Time
+	Failures         int
+	Successes        int
+}
+
+// ProxyPool — прокси по платформам. После maxFailures сетевых ошибок или
+// rate-limit подряд прокси уходит в карантин на quarantine.
+type ProxyPool struct {
+	mu          sync.Mutex
+	proxies     map[string][]*Proxy
+	next        map[string]int
+	strategy    ProxyStrategy
+	maxFailures int
+	quarantine  time.Duration
+	log         *zap.Logger
+}
+
+// NewProxyPool создаёт пул. lists — платформа → URL прокси; DefaultProxyPlatform — общий список.
+func NewProxyPool(lists map[string][]string, strategy ProxyStrategy, maxFailures int, quarantine time.Duration, log *zap.Logger) *ProxyPool {
+	if strategy != ProxyLeastFailures {
+		strategy = ProxyRoundRobin
+	}
+	p := &ProxyPool{
+		proxies:     make(map[string][]*Proxy),
+		next:        make(map[string]int),
+		strategy:    strategy,
+		maxFailures: max(1, maxFailures),
+		quarantine:  quarantine,
+		log:         log,
+	}
+	for platform, urls := range lists {
+		redacted := make([]string, 0, len(urls))
+		for _, u := range urls {
+			p.proxies[platform] = append(p.proxies[platform], &Proxy{URL: u})
+			redacted = append(redacted, RedactProxy(u))
+		}
+		log.Info("proxy pool loaded",
+			zap.String("platform", platform),
+			zap.Strings("proxies", redacted),
+			zap.String("strategy", string(strategy)),
+		)
+	}
+	return p
+}
+
+// Acquire выбирает прокси для платформы, пропуская exclude и прокси в карантине.
+// Если в карантине все, берёт тот, чей карантин кончится раньше: прямое подключение
+// для платформы с прокси обычно заблокировано. nil — прокси не настроены
+// или все уже опробованы в этом скачивании.
+func (p *ProxyPool) Acquire(platform string, exclude map[*Proxy]bool) *Proxy {
+	if p == nil {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	key := platform
+	if len(p.proxies[key]) == 0 {
+		key = DefaultProxyPlatform
+	}
+	list := p.proxies[key]
+	now := time.Now()
+
+	var candidates []*Proxy
+	var soonest *Proxy
+	start := p.next[key]
+	for i := range list {
+		proxy := list[(start+i)%len(list)]
+		if exclude[proxy] {
+			continue
+		}
+		if now.Before(proxy.quarantinedUntil) {
+			if soonest == nil || proxy.quarantinedUntil.Before(soonest.quarantinedUntil) {
+				soonest = proxy
+			}
+			continue
+		}
+		candidates = append(candidates, proxy)
+	}
+
+	if len(candidates) == 0 {
+		return soonest
+	}
+
+	chosen := candidates[0]
+	if p.strategy == ProxyLeastFailures {
+		for _, proxy := range candidates[1:] {
+			if proxy.failures < chosen.failures {
+				chosen = proxy
+			}
+		}
+	}
+	for i, proxy := range list {
+		if proxy == chosen {
+			p.next[key] = i + 1
+		}
+	}
+	return chosen
+}
+
+// ReportFailure учитывает сетевую ошибку или rate-limit через прокси.
+func (p *ProxyPool) ReportFailure(proxy *Proxy, cause error) {
+	p.mu.Lock()
+	proxy.failures++
+	proxy.consecutiveFailures++
+	quarantined := proxy.consecutiveFailures >= p.maxFailures
+	if quarantined {
+		proxy.consecutiveFailures = 0
+		proxy.quarantinedUntil = time.Now().Add(p.quarantine)
+	}
+	p.mu.Unlock()
+
+	if quarantined {
+		p.log.Warn("proxy quarantined",
+			zap.String("proxy", RedactProxy(proxy.URL)),
+			zap.Duration("quarantine", p.quarantine),
+			zap.Error(cause),
+		)
+		return
+	}
+	p.log.Info("proxy failure", zap.String("proxy", RedactProxy(proxy.URL)), zap.Error(cause))
+}
+
+// ReportSuccess сбрасывает счётчик ошибок подряд.
+func (p *ProxyPool) ReportSuccess(proxy *Proxy) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	proxy.successes++
+	proxy.consecutiveFailures = 0
+}
+
+// Health возвращает состояние всех прокси, отсортированное по платформе.
+func (p *ProxyPool) Health() []ProxyHealth {
+	if p == nil {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	var out []ProxyHealth
+	for platform, list := range p.proxies {
+		for _, proxy := range list {
+			out = append(out, ProxyHealth{
+				Platform:         platform,
+				Proxy:            RedactProxy(proxy.URL),
+				Available:        !now.Before(proxy.quarantinedUntil),
+				QuarantinedUntil: proxy.quarantinedUntil,
+				Failures:         proxy.failures,
+				Successes:        proxy.successes,
+			})
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Platform < out[j].Platform })
+	return out
+}
+
+// RedactProxy убирает логин и пароль из URL прокси — для логов.
+func RedactProxy(raw string) string {
+	if raw == "" {
+		return ""
+	}
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return "<invalid proxy>"
+	}
+	if u.User != nil {
+		u.User = url.User("***")
+	}
+	return u.String()
+}
@@ -0,0 +1,109 @@
+package download
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// ErrNoSubtitles — у видео нет субтитров на подходящих языках.
+var ErrNoSubtitles = errors.New("no subtitles available")
+
+// SubtitleResult — файл субтитров во временной директории Dir (удалить после отправки).
+type SubtitleResult struct {
+	Dir    string
+	Path   string
+	Lang   string // язык дорожки, как его назвал yt-dlp ("en", "eng-US", "ru-orig")
+	Format string // "vtt" или "srt"
+}
+
+// DownloadSubtitles скачивает через yt-dlp авторские или автоматические субтитры
+// без самого видео. langs — языки по убыванию предпочтения ("ru", "en");
+// дорожка выбирается по первому языку, для которого она нашлась.
+func DownloadSubtitles(ctx context.Context, rawURL string, langs []string, opts Options, log *zap.Logger) (*SubtitleResult, error) {
+	return withRotation(opts, log, func(jar *CookieJar, proxy string) (*SubtitleResult, error) {
+		return subtitlesOnce(ctx, rawURL, langs, opts, jar, proxy, log)
+	})
+}
+
+func subtitlesOnce(ctx context.Context, rawURL string, langs []string, opts Options, jar *CookieJar, proxy string, log *zap.Logger) (*SubtitleResult, error) {
+	dir, err := opts.WorkDir.MkdirTemp()
+	if err != nil {
+		return nil, err
+	}
+
+	// "ru.*" ловит и "ru", и трёхбуквенные коды TikTok вроде "rus-RU"
+	patterns := make([]string, 0, len(langs))
+	for _, lang := range langs {
+		patterns = append(patterns, lang+".*")
+	}
+
+	args := []string{
+		"--no-warnings",
+		"--no-playlist",
+		"--skip-download",
+		"--write-subs",
+		"--write-auto-subs",
+		"--sub-langs", strings.Join(patterns, ","),
+		"--sub-format", "vtt/srt/best",
+		"-o", filepath.Join(dir, "subs.%(ext)s"),
+		"--socket-timeout", "30",
+	}
+
+	if _, err := runYtDlp(ctx, rawURL, args, dir, opts, jar, proxy, log); err != nil {
+		opts.WorkDir.Remove(dir, "yt-dlp failed")
+		return nil, err
+	}
+
+	artifacts, err := collectArtifacts(dir)
+	if err != nil {
+		opts.WorkDir.Remove(dir, "failed to list output")
+		return nil, err
+	}
+	result := &VideoResult{Dir: dir, Artifacts: artifacts}
+
+	best := pickSubtitle(result.ByKind(ArtifactSubtitle), langs)
+	if best == nil {
+		opts.WorkDir.Remove(dir, "no subtitles")
+		return nil, ErrNoSubtitles
+	}
+
+	log.Info("subtitles downloaded", zap.String("lang", best.Lang), zap.String("format", best.Format))
+	best.Dir = dir
+	return best, nil
+}
+
+// pickSubtitle выбирает файл subs.<lang>.<ext> по порядку langs.
+// Форматы, которые мы не умеем конвертировать в SRT (ASS, TTML), пропускаются.
+func pickSubtitle(files []Artifact, langs []string) *SubtitleResult {
+	var candidates []SubtitleResult
+	for _, f := range files {
+		format := ""
+		switch f.MIME {
+		case "text/vtt":
+			format = "vtt"
+		case "application/x-subrip":
+			format = "srt"
+		default:
+			continue
+		}
+		name := strings.TrimPrefix(filepath.Base(f.Path), "subs.")
+		lang := strings.TrimSuffix(name, filepath.Ext(name))
+		candidates = append(candidates, SubtitleResult{Path: f.Path, Lang: lang, Format: format})
+	}
+
+	for _, want := range langs {
+		for i := range candidates {
+			if strings.HasPrefix(strings.ToLower(candidates[i].Lang), want) {
+				return &candidates[i]
+			}
+		}
+	}
+	if len(candidates) > 0 {
+		return &candidates[0]
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package download
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestDownloadSubtitlesPicksPreferredLanguage(t *testing.T) {
+	bin := fakeYtDlp(t, `
+case "$*" in *"--sub-langs ru.*,en.*"*) ;; *) echo "unexpected args: $*" >&2; exit 1 ;; esac
+dir=$(dirname "$OUT")
+printf 'WEBVTT\n\n00:00.000 --> 00:01.000\nhello\n' > "$dir/subs.eng-US.vtt"
+printf '[Script Info]\nTitle: x\n' > "$dir/subs.rus-RU.ass"
+printf 'WEBVTT\n\n00:00.000 --> 00:01.000\nпривет\n' > "$dir/subs.rus-RU.vtt"
+`)
+
+	result, err := DownloadSubtitles(context.Background(), "https://www.tiktok.com/@u/video/1", []string{"ru", "en"}, testOptions(t, bin), zap.NewNop())
+	if err != nil {
+		t.Fatalf("DownloadSubtitles() error = %v", err)
+	}
+	if result.Lang != "rus-RU" || result.Format != "vtt" {
+		t.Fatalf("DownloadSubtitles() = %+v, want rus-RU vtt", result)
+	}
+	data, err := os.ReadFile(result.Path)
+	if err != nil || !strings.Contains(string(data), "привет") {
+		t.Fatalf("subtitle file = %q, %v", data, err)
+	}
+}
+
+func TestDownloadSubtitlesNone(t *testing.T) {
+	bin := fakeYtDlp(t, `echo "[info] There are no subtitles for the requested languages"`)
+
+	opts := testOptions(t, bin)
+	_, err := DownloadSubtitles(context.Background(), "https://www.tiktok.com/@u/video/1", []string{"en"}, opts, zap.NewNop())
+	if !errors.Is(err, ErrNoSubtitles) {
+		t.Fatalf("DownloadSubtitles() error = %v, want ErrNoSubtitles", err)
+	}
+	if usage, _ := opts.WorkDir.Usage(); usage != 0 {
+		t.Fatalf("work dir usage = %d, want temp dir removed", usage)
+	}
+}
@@ -0,0 +1,199 @@
+package download
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+
+	"go.uber.org/zap"
+)
+
+// Коды statusCode в webapp.video-detail.
+const (
+	tikTokStatusNotFound = 10204
+	tikTokStatusPrivate  = 10222
+)
+
+var (
+	// reTikTokState — встроенное состояние страницы: новый формат и старый SIGI_STATE.
+	reTikTokState = regexp.MustCompile(`(?s)<script[^>]+id="(__UNIVERSAL_DATA_FOR_REHYDRATION__|SIGI_STATE)"[^>]*>(.*?)</script>`)
+	reTikTokID    = regexp.MustCompile(`/video/(\d+)`)
+)
+
+type tikTokItem struct {
+	ID          string `json:"id"`
+	PrivateItem *bool  `json:"privateItem"` // nil — в состоянии страницы флага нет
+	Video       struct {
+		Duration    float64     `json:"duration"`
+		Bitrate     json.Number `json:"bitrate"`  // бит/с; TikTok отдаёт то числом, то строкой
+		PlayAddr    string      `json:"playAddr"` // без водяного знака, в отличие от downloadAddr
+		BitrateInfo []struct {
+			Bitrate  json.Number `json:"Bitrate"`
+			PlayAddr struct {
+				DataSize json.Number `json:"DataSize"`
+				URLList  []string    `json:"UrlList"`
+			} `json:"PlayAddr"`
+		} `json:"bitrateInfo"`
+	} `json:"video"`
+}
+
+// metadata — то, что известно о видео из состояния страницы. Эфиров на странице
+// видео не бывает, но live_status TikTok не сообщает — оставляем пустым.
+func (item *tikTokItem) metadata() *Metadata {
+	meta := &Metadata{ID: item.ID, Duration: item.Video.Duration}
+	if item.PrivateItem != nil {
+		meta.Availability = "public"
+		if *item.PrivateItem {
+			meta.Availability = "private"
+		}
+	}
+
+	// Размер конкретного файла неизвестен: playAddr может не совпадать ни с одним
+	// из bitrateInfo, поэтому это оценка — по DataSize или по битрейту
+	bitrate, _ := item.Video.Bitrate.Int64()
+	for _, b := range item.Video.BitrateInfo {
+		if size, _ := b.PlayAddr.DataSize.Int64(); size > 0 {
+			meta.FilesizeApprox = size
+			return meta
+		}
+		if bitrate <= 0 {
+			bitrate, _ = b.Bitrate.Int64()
+		}
+	}
+	if bitrate > 0 {
+		meta.FilesizeApprox = int64(float64(bitrate) / 8 * item.Video.Duration)
+	}
+	return meta
+}
+
+type tikTokUniversalData struct {
+	DefaultScope struct {
+		VideoDetail *struct {
+			StatusCode int `json:"statusCode"`
+			ItemInfo   struct {
+				ItemStruct tikTokItem `json:"itemStruct"`
+			} `json:"itemInfo"`
+		} `json:"webapp.video-detail"`
+	} `json:"__DEFAULT_SCOPE__"`
+}
+
+type tikTokSigiState struct {
+	ItemModule map[string]tikTokItem `json:"ItemModule"`
+}
+
+// TikTokBackend достаёт ссылку на видео без водяного знака из JSON-состояния страницы.
+type TikTokBackend struct{}
+
+func NewTikTokBackend() *TikTokBackend {
+	return &TikTokBackend{}
+}
+
+func (*TikTokBackend) Name() string { return "tiktok" }
+
+func (t *TikTokBackend) Probe(ctx context.Context, rawURL string, opts Options, log *zap.Logger) (*Metadata, error) {
+	item, err := withNativeProxy(ctx, opts, log, func(client *http.Client) (*tikTokItem, error) {
+		item, _, _, err := t.extract(ctx, client, rawURL)
+		return item, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	meta := item.metadata()
+	log.Debug("video probed natively", zap.String("id", meta.ID), zap.Float64("duration", meta.Duration))
+	return meta, nil
+}
+
+func (t *TikTokBackend) Download(ctx context.Context, rawURL string, opts Options, log *zap.Logger) (*VideoResult, error) {
+	return withNativeProxy(ctx, opts, log, func(client *http.Client) (*VideoResult, error) {
+		item, pageURL, playURL, err := t.extract(ctx, client, rawURL)
+		if err != nil {
+			return nil, err
+		}
+		log.Debug("tiktok play url extracted", zap.String("id", item.ID))
+		return saveMedia(ctx, client, playURL, pageURL.String(), opts, log)
+	})
+}
+
+// extract загружает страницу (короткие ссылки раскрываются редиректом)
+// и возвращает видео, итоговый URL страницы и ссылку на файл.
+func (t *TikTokBackend) extract(ctx context.Context, client *http.Client, rawURL string) (*tikTokItem, *url.URL, string, error) {
+	body, pageURL, err := fetchPage(ctx, client, rawURL, nil)
+	if err != nil {
+		return nil, nil, "", err
+	}
+
+	videoID := ""
+	if m := reTikTokID.FindStringSubmatch(pageURL.Path); m != nil {
+		videoID = m[1]
+	}
+
+	item, err := parseTikTokPage(body, videoID)
+	if err != nil {
+		return nil, nil, "", err
+	}
+
+	playURL := item.Video.PlayAddr
+	if playURL == "" {
+		for _, b := range item.Video.BitrateInfo {
+			if len(b.PlayAddr.URLList) > 0 {
+				playURL = b.PlayAddr.URLList[0]
+				break
+			}
+		}
+	}
+	if playURL == "" {
+		return nil, nil, "", fmt.Errorf("%w: no play url in tiktok state", ErrNativeExtract)
+	}
+
+	resolved, err := pageURL.Parse(playURL)
+	if err != nil {
+		return nil, nil, "", fmt.Errorf("%w: bad play url: %v", ErrNativeExtract, err)
+	}
+	return item, pageURL, resolved.String(), nil
+}
+
+// parseTikTokPage находит видео во встроенном JSON. videoID может быть пустым.
+func parseTikTokPage(body []byte, videoID string) (*tikTokItem, error) {
+	m := reTikTokState.FindSubmatch(body)
+	if m == nil {
+		return nil, fmt.Errorf("%w: tiktok state script not found", ErrNativeExtract)
+	}
+
+	if string(m[1]) == "SIGI_STATE" {
+		var state tikTokSigiState
+		if err := json.Unmarshal(m[2], &state); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrNativeExtract, err)
+		}
+		if item, ok := state.ItemModule[videoID]; ok {
+			return &item, nil
+		}
+		if len(state.ItemModule) == 1 {
+			for _, item := range state.ItemModule {
+				return &item, nil
+			}
+		}
+		return nil, fmt.Errorf("%w: video %q not in SIGI_STATE", ErrNativeExtract, videoID)
+	}
+
+	var data tikTokUniversalData
+	if err := json.Unmarshal(m[2], &data); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrNativeExtract, err)
+	}
+	detail := data.DefaultScope.VideoDetail
+	if detail == nil {
+		return nil, fmt.Errorf("%w: no video detail in tiktok state", ErrNativeExtract)
+	}
+	switch detail.StatusCode {
+	case 0:
+		return &detail.ItemInfo.ItemStruct, nil
+	case tikTokStatusNotFound:
+		return nil, ErrYtDlpRemoved
+	case tikTokStatusPrivate:
+		return nil, ErrYtDlpPrivate
+	default:
+		return nil, fmt.Errorf("%w: tiktok status code %d", ErrNativeExtract, detail.StatusCode)
+	}
+}
@@ -0,0 +1,199 @@
+package download
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+const tikTokTestPath = "/@catlover/video/7301234567890123456"
+
+// fakeMP4 — минимальный заголовок ftyp и n байт «видео».
+func fakeMP4(n int) []byte {
+	return append([]byte("\x00\x00\x00\x18ftypisom\x00\x00\x02\x00isomiso2mp41"), bytes.Repeat([]byte{0}, n)...)
+}
+
+// tikTokServer отдаёт сохранённую страницу fixture по tikTokTestPath и видео по /play/.
+// Видео отдаётся только с cookie, выставленным страницей, как на настоящем CDN.
+func tikTokServer(t *testing.T, fixture string, video []byte) *httptest.Server {
+	t.Helper()
+	page, err := os.ReadFile(filepath.Join("testdata", "tiktok", fixture))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mux := http.NewServeMux()
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	mux.HandleFunc("/t/ZSshort/", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, tikTokTestPath+"?_r=1", http.StatusMovedPermanently)
+	})
+	mux.HandleFunc(tikTokTestPath, func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "tt_chain_token", Value: "token", Path: "/"})
+		w.Write(bytes.ReplaceAll(page, []byte("{{BASE}}"), []byte(srv.URL)))
+	})
+	mux.HandleFunc("/play/", func(w http.ResponseWriter, r *http.Request) {
+		if c, err := r.Cookie("tt_chain_token"); err != nil || c.Value != "token" || r.Referer() == "" {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		w.Write(video)
+	})
+	mux.HandleFunc("/watermarked.mp4", func(w http.ResponseWriter, r *http.Request) {
+		t.Error("watermarked downloadAddr must not be used")
+	})
+	return srv
+}
+
+func nativeTestOptions(t *testing.T, ytDlpBody string) Options {
+	t.Helper()
+	opts := testOptions(t, fakeYtDlp(t, ytDlpBody))
+	opts.Platform = "tiktok"
+	opts.Native = NewTikTokBackend()
+	return opts
+}
+
+// ytDlpMustNotRun — fake yt-dlp для случаев, когда фолбэка быть не должно.
+const ytDlpMustNotRun = `echo "ERROR: Unsupported URL: yt-dlp must not run" >&2; exit 1`
+
+func TestTikTokBackendDownload(t *testing.T) {
+	video := fakeMP4(4096)
+
+	tests := []struct {
+		name    string
+		fixture string
+		path    string
+	}{
+		{"universal data", "video.html", tikTokTestPath},
+		{"short link redirect", "video.html", "/t/ZSshort/"},
+		{"legacy SIGI_STATE with relative url", "sigi.html", tikTokTestPath},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := tikTokServer(t, tt.fixture, video)
+			opts := nativeTestOptions(t, ytDlpMustNotRun)
+
+			result, err := DownloadVideo(context.Background(), srv.URL+tt.path, opts, zap.NewNop())
+			if err != nil {
+				t.Fatalf("DownloadVideo() error = %v", err)
+			}
+			got, ok := result.Video()
+			if !ok {
+				t.Fatalf("no video artifact in %+v", result.Artifacts)
+			}
+			data, err := os.ReadFile(got.Path)
+			if err != nil || !bytes.Equal(data, video) {
+				t.Fatalf("downloaded %d bytes, want %d (%v)", len(data), len(video), err)
+			}
+		})
+	}
+}
+
+func TestTikTokBackendProbe(t *testing.T) {
+	srv := tikTokServer(t, "video.html", fakeMP4(16))
+	opts := nativeTestOptions(t, ytDlpMustNotRun)
+
+	meta, err := ProbeVideo(context.Background(), srv.URL+tikTokTestPath, opts, zap.NewNop())
+	if err != nil {
+		t.Fatalf("ProbeVideo() error = %v", err)
+	}
+	if meta.ID != "7301234567890123456" || meta.Duration != 15 || meta.Err() != nil {
+		t.Fatalf("ProbeVideo() = %+v", meta)
+	}
+	if meta.Availability != "public" || meta.LiveStatus != "" || meta.EstimatedSize() != 4210 {
+		t.Fatalf("ProbeVideo() availability, live status, size = %q, %q, %d; want public, unknown, 4210",
+			meta.Availability, meta.LiveStatus, meta.EstimatedSize())
+	}
+}
+
+func TestTikTokItemMetadata(t *testing.T) {
+	tests := []struct {
+		name             string
+		item             string
+		wantAvailability string
+		wantSize         int64
+	}{
+		{"no flags", `{"id":"1","video":{"duration":10}}`, "", 0},
+		{"private", `{"id":"1","privateItem":true,"video":{"duration":10}}`, "private", 0},
+		{"data size as string", `{"id":"1","privateItem":false,"video":{"duration":10,"bitrateInfo":[{"Bitrate":800000,"PlayAddr":{"DataSize":"123456"}}]}}`, "public", 123456},
+		{"size from bitrate", `{"id":"1","video":{"duration":10,"bitrateInfo":[{"Bitrate":800000,"PlayAddr":{}}]}}`, "", 1000000},
+		{"size from video bitrate", `{"id":"1","video":{"duration":10,"bitrate":"400000"}}`, "", 500000},
+	}
+	for _, tt := range tests {
+		var item tikTokItem
+		if err := json.Unmarshal([]byte(tt.item), &item); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		meta := item.metadata()
+		if meta.Availability != tt.wantAvailability || meta.EstimatedSize() != tt.wantSize || meta.LiveStatus != "" {
+			t.Errorf("%s: metadata() = %+v; want availability %q, size %d", tt.name, meta, tt.wantAvailability, tt.wantSize)
+		}
+	}
+}
+
+func TestTikTokBackendFinalErrorsSkipFallback(t *testing.T) {
+	t.Run("removed", func(t *testing.T) {
+		srv := tikTokServer(t, "removed.html", nil)
+		_, err := DownloadVideo(context.Background(), srv.URL+tikTokTestPath, nativeTestOptions(t, ytDlpMustNotRun), zap.NewNop())
+		if !errors.Is(err, ErrYtDlpRemoved) {
+			t.Fatalf("DownloadVideo() error = %v, want ErrYtDlpRemoved", err)
+		}
+	})
+
+	t.Run("too large", func(t *testing.T) {
+		srv := tikTokServer(t, "video.html", fakeMP4(4096))
+		opts := nativeTestOptions(t, ytDlpMustNotRun)
+		opts.MaxFilesize = 1024
+
+		_, err := DownloadVideo(context.Background(), srv.URL+tikTokTestPath, opts, zap.NewNop())
+		if !errors.Is(err, ErrYtDlpTooLarge) {
+			t.Fatalf("DownloadVideo() error = %v, want ErrYtDlpTooLarge", err)
+		}
+		if usage, _ := opts.WorkDir.Usage(); usage != 0 {
+			t.Fatalf("work dir usage = %d after oversize download, want 0", usage)
+		}
+	})
+}
+
+func TestTikTokBackendFallsBackToYtDlp(t *testing.T) {
+	tests := []struct {
+		name string
+		path string
+	}{
+		{"captcha page", tikTokTestPath},
+		// 404 без признаков удаления в состоянии страницы — не повод считать видео удалённым
+		{"http 404", "/@catlover/video/7309999999999999999"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := tikTokServer(t, "captcha.html", nil)
+			opts := nativeTestOptions(t, `
+file=$(echo "$OUT" | sed 's/%(ext)s/mp4/')
+printf '\000\000\000\030ftypisom from yt-dlp' > "$file"
+echo "$file"
+`)
+
+			result, err := DownloadVideo(context.Background(), srv.URL+tt.path, opts, zap.NewNop())
+			if err != nil {
+				t.Fatalf("DownloadVideo() error = %v", err)
+			}
+			video, _ := result.Video()
+			data, err := os.ReadFile(video.Path)
+			if err != nil || !strings.HasSuffix(string(data), "from yt-dlp") {
+				t.Fatalf("downloaded file = %q, %v, want yt-dlp output", data, err)
+			}
+		})
+	}
+}
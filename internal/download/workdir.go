@@ -0,0 +1,241 @@
+package download
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// ErrWorkDirFull — в рабочей директории не осталось места под новое скачивание.
+var ErrWorkDirFull = errors.New("work dir quota exceeded")
+
+const (
+	// tmpDirPrefix — префикс директорий отдельных скачиваний.
+	tmpDirPrefix = "vidsave_"
+	// downloadReserve — сколько места резервируем под одно скачивание (совпадает с --max-filesize).
+	downloadReserve = 50 * 1024 * 1024
+)
+
+// WorkDir — рабочая директория для временных файлов скачивания
+// с общей квотой на диск и уборкой осиротевших директорий.
+// Квота учитывает только этот процесс: чужие скачивания в той же директории
+// видны лишь по уже записанным байтам.
+type WorkDir struct {
+	root  string
+	quota int64 // байт; 0 — без лимита
+	log   *zap.Logger
+
+	mu     sync.Mutex
+	active map[string]bool // директории, выданные MkdirTemp и ещё не удалённые
+}
+
+// NewWorkDir создаёт (при необходимости) рабочую директорию root и один раз
+// убирает директории скачиваний старше maxAge, оставшиеся прямо в os.TempDir()
+// от версий без WORK_DIR — их janitor не видит.
+func NewWorkDir(root string, quota int64, maxAge time.Duration, log *zap.Logger) (*WorkDir, error) {
+	if root == "" {
+		root = filepath.Join(os.TempDir(), "vidsave")
+	}
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create work dir: %w", err)
+	}
+	w := &WorkDir{root: root, quota: quota, log: log, active: make(map[string]bool)}
+	w.sweepTempDir(maxAge)
+	return w, nil
+}
+
+// sweepTempDir убирает устаревшие vidsave_* из os.TempDir(), если рабочая директория — другая.
+func (w *WorkDir) sweepTempDir(maxAge time.Duration) {
+	tmp := filepath.Clean(os.TempDir())
+	if maxAge <= 0 || filepath.Clean(w.root) == tmp {
+		return
+	}
+	legacy := &WorkDir{root: tmp, log: w.log, active: make(map[string]bool)}
+	removed, err := legacy.Clean(maxAge)
+	if err != nil {
+		w.log.Warn("failed to sweep temp dir", zap.Error(err), zap.String("dir", tmp))
+		return
+	}
+	if removed > 0 {
+		w.log.Info("stale download dirs removed from temp dir",
+			zap.String("dir", tmp),
+			zap.Int("removed_dirs", removed),
+		)
+	}
+}
+
+// Root возвращает путь к рабочей директории.
+func (w *WorkDir) Root() string {
+	return w.root
+}
+
+// Usage считает, сколько байт занимают директории скачиваний.
+func (w *WorkDir) Usage() (int64, error) {
+	dirs, err := w.downloadDirs()
+	if err != nil {
+		return 0, err
+	}
+
+	var total int64
+	for _, dir := range dirs {
+		total += dirSize(dir)
+	}
+	return total, nil
+}
+
+// MkdirTemp создаёт директорию под одно скачивание, предварительно проверив квоту.
+// Каждая выданная директория, пока её не удалят, занимает в квоте не меньше
+// downloadReserve — иначе параллельные скачивания прошли бы проверку одновременно.
+func (w *WorkDir) MkdirTemp() (string, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.quota > 0 {
+		usage, err := w.reservedUsage()
+		if err != nil {
+			return "", fmt.Errorf("failed to measure work dir usage: %w", err)
+		}
+		if usage+downloadReserve > w.quota {
+			w.log.Warn("work dir quota exceeded",
+				zap.Int64("usage_bytes", usage),
+				zap.Int64("quota_bytes", w.quota),
+			)
+			return "", ErrWorkDirFull
+		}
+	}
+
+	dir, err := os.MkdirTemp(w.root, tmpDirPrefix+"*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temp dir: %w", err)
+	}
+	w.active[dir] = true
+	return dir, nil
+}
+
+// reservedUsage — занятое место с резервом под незавершённые скачивания. Вызывается под mu.
+func (w *WorkDir) reservedUsage() (int64, error) {
+	dirs, err := w.downloadDirs()
+	if err != nil {
+		return 0, err
+	}
+
+	var total int64
+	for _, dir := range dirs {
+		size := dirSize(dir)
+		if w.active[dir] {
+			size = max(size, downloadReserve)
+		}
+		total += size
+	}
+	return total, nil
+}
+
+// Remove удаляет директорию скачивания. reason попадает в лог.
+func (w *WorkDir) Remove(dir string, reason string) {
+	if dir == "" {
+		return
+	}
+	size := dirSize(dir)
+	if err := os.RemoveAll(dir); err != nil {
+		w.log.Warn("failed to cleanup tmp dir", zap.Error(err), zap.String("dir", dir))
+		return
+	}
+	w.mu.Lock()
+	delete(w.active, dir)
+	w.mu.Unlock()
+	w.log.Info("tmp dir removed",
+		zap.String("dir", dir),
+		zap.String("reason", reason),
+		zap.Int64("freed_bytes", size),
+	)
+}
+
+// Clean удаляет директории скачиваний старше maxAge — остатки упавших процессов.
+// Директории, которые этот процесс ещё использует, не трогает, даже если они старые:
+// скачивание или отправка без JOB_TIMEOUT может идти сколько угодно.
+func (w *WorkDir) Clean(maxAge time.Duration) (removed int, err error) {
+	dirs, err := w.downloadDirs()
+	if err != nil {
+		return 0, err
+	}
+
+	threshold := time.Now().Add(-maxAge)
+	for _, dir := range dirs {
+		w.mu.Lock()
+		active := w.active[dir]
+		w.mu.Unlock()
+		if active {
+			continue
+		}
+		info, err := os.Stat(dir)
+		if err != nil || info.ModTime().After(threshold) {
+			continue
+		}
+		w.Remove(dir, "stale")
+		removed++
+	}
+	return removed, nil
+}
+
+// RunJanitor чистит устаревшие директории сразу и затем каждые interval. Блокирует до отмены ctx.
+func (w *WorkDir) RunJanitor(ctx context.Context, interval, maxAge time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		removed, err := w.Clean(maxAge)
+		if err != nil {
+			w.log.Error("janitor failed", zap.Error(err), zap.String("work_dir", w.root))
+		} else {
+			usage, _ := w.Usage()
+			w.log.Info("janitor run finished",
+				zap.Int("removed_dirs", removed),
+				zap.Int64("usage_bytes", usage),
+			)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// downloadDirs возвращает пути директорий скачиваний (vidsave_*).
+func (w *WorkDir) downloadDirs() ([]string, error) {
+	entries, err := os.ReadDir(w.root)
+	if err != nil {
+		return nil, err
+	}
+
+	dirs := make([]string, 0, len(entries))
+	for _, e := range entries {
+		if e.IsDir() && strings.HasPrefix(e.Name(), tmpDirPrefix) {
+			dirs = append(dirs, filepath.Join(w.root, e.Name()))
+		}
+	}
+	return dirs, nil
+}
+
+func dirSize(dir string) int64 {
+	var size int64
+	_ = filepath.WalkDir(dir, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return nil
+		}
+		if info, err := d.Info(); err == nil {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size
+}
@@ -0,0 +1,137 @@
+package download
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestWorkDirQuota(t *testing.T) {
+	w, err := NewWorkDir(t.TempDir(), downloadReserve+1024, 0, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir, err := w.MkdirTemp()
+	if err != nil {
+		t.Fatalf("first MkdirTemp() error = %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "video.mp4"), make([]byte, 2048), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := w.MkdirTemp(); !errors.Is(err, ErrWorkDirFull) {
+		t.Fatalf("second MkdirTemp() error = %v, want ErrWorkDirFull", err)
+	}
+
+	w.Remove(dir, "test")
+	if _, err := w.MkdirTemp(); err != nil {
+		t.Fatalf("MkdirTemp() after cleanup error = %v", err)
+	}
+}
+
+func TestWorkDirQuotaReservesActiveDirs(t *testing.T) {
+	w, err := NewWorkDir(t.TempDir(), 2*downloadReserve, 0, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Пустые директории ещё качаются — каждая держит резерв
+	first, err := w.MkdirTemp()
+	if err != nil {
+		t.Fatalf("first MkdirTemp() error = %v", err)
+	}
+	if _, err := w.MkdirTemp(); err != nil {
+		t.Fatalf("second MkdirTemp() error = %v", err)
+	}
+	if _, err := w.MkdirTemp(); !errors.Is(err, ErrWorkDirFull) {
+		t.Fatalf("third MkdirTemp() error = %v, want ErrWorkDirFull", err)
+	}
+
+	w.Remove(first, "test")
+	if _, err := w.MkdirTemp(); err != nil {
+		t.Fatalf("MkdirTemp() after cleanup error = %v", err)
+	}
+}
+
+func TestNewWorkDirSweepsTempDir(t *testing.T) {
+	tmp := t.TempDir()
+	t.Setenv("TMPDIR", tmp)
+
+	stale := filepath.Join(tmp, tmpDirPrefix+"stale")
+	fresh := filepath.Join(tmp, tmpDirPrefix+"fresh")
+	for _, dir := range []string{stale, fresh} {
+		if err := os.Mkdir(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	old := time.Now().Add(-2 * time.Hour)
+	if err := os.Chtimes(stale, old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := NewWorkDir(filepath.Join(tmp, "vidsave"), 0, time.Hour, zap.NewNop()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(stale); !os.IsNotExist(err) {
+		t.Errorf("stale temp dir stat error = %v, want removed", err)
+	}
+	if _, err := os.Stat(fresh); err != nil {
+		t.Errorf("fresh temp dir stat error = %v, want kept", err)
+	}
+}
+
+func TestWorkDirCleanRemovesOnlyStaleDownloadDirs(t *testing.T) {
+	root := t.TempDir()
+	w, err := NewWorkDir(root, 0, 0, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// stale и fresh — остатки упавшего процесса, active — идущее долгое скачивание
+	stale := filepath.Join(root, tmpDirPrefix+"stale")
+	fresh := filepath.Join(root, tmpDirPrefix+"fresh")
+	foreign := filepath.Join(root, "keep_me")
+	for _, dir := range []string{stale, fresh, foreign} {
+		if err := os.Mkdir(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	active, err := w.MkdirTemp()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	old := time.Now().Add(-2 * time.Hour)
+	for _, dir := range []string{stale, foreign, active} {
+		if err := os.Chtimes(dir, old, old); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	removed, err := w.Clean(time.Hour)
+	if err != nil {
+		t.Fatalf("Clean() error = %v", err)
+	}
+	if removed != 1 {
+		t.Fatalf("Clean() removed = %d, want 1", removed)
+	}
+	for dir, wantExists := range map[string]bool{stale: false, fresh: true, foreign: true, active: true} {
+		_, err := os.Stat(dir)
+		if exists := err == nil; exists != wantExists {
+			t.Errorf("%s exists = %v, want %v", filepath.Base(dir), exists, wantExists)
+		}
+	}
+
+	// Директорию больше не используют — теперь janitor её уберёт
+	w.mu.Lock()
+	delete(w.active, active)
+	w.mu.Unlock()
+	if removed, err := w.Clean(time.Hour); err != nil || removed != 1 {
+		t.Fatalf("Clean() after download finished = %d, %v; want 1", removed, err)
+	}
+}
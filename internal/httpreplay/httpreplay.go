@@ -0,0 +1,234 @@
+// Package httpreplay записывает HTTP-ответы в JSON-«кассету» и воспроизводит их,
+// чтобы тесты extractor'ов работали офлайн на сохранённых ответах платформ.
+//
+// В тестах: opts.Transport = httpreplay.Transport(t, "testdata/instagram/reel.json").
+// Перезапись кассет с реальной сетью: HTTPREPLAY_RECORD=1 go test ./...
+package httpreplay
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"unicode/utf8"
+)
+
+// RecordEnv — переменная окружения, включающая запись кассет.
+const RecordEnv = "HTTPREPLAY_RECORD"
+
+// keptHeaders — заголовки ответа, которые сохраняются в кассете. Остальные
+// (трекинг, серверные id, CSP) только раздувают файлы и меняются от запуска к запуску.
+// Content-Length нужен ответам на HEAD — тела у них нет.
+var keptHeaders = []string{"Content-Type", "Content-Length", "Location", "Set-Cookie"}
+
+// Request — запрос; по методу, URL и телу ищется ответ при воспроизведении.
+type Request struct {
+	Method string `json:"method"`
+	URL    string `json:"url"`
+	Body   string `json:"body,omitempty"`
+}
+
+// Response — сохранённый ответ. Бинарное тело хранится в BodyBase64.
+type Response struct {
+	Status     int         `json:"status"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       string      `json:"body,omitempty"`
+	BodyBase64 string      `json:"body_base64,omitempty"`
+}
+
+type Interaction struct {
+	Request  Request  `json:"request"`
+	Response Response `json:"response"`
+}
+
+// Cassette — записанный диалог с сервером.
+type Cassette struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+// Load читает кассету из файла.
+func Load(path string) (*Cassette, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var c Cassette
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("httpreplay: bad cassette %s: %w", path, err)
+	}
+	return &c, nil
+}
+
+// Save записывает кассету в файл, создавая директорию.
+func (c *Cassette) Save(path string) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+// Transport возвращает RoundTripper для теста: воспроизводит кассету path,
+// а при RecordEnv=1 ходит в сеть и перезаписывает кассету после теста.
+func Transport(t testing.TB, path string) http.RoundTripper {
+	t.Helper()
+
+	if os.Getenv(RecordEnv) == "1" {
+		rec := NewRecorder(http.DefaultTransport)
+		t.Cleanup(func() {
+			if err := rec.Cassette().Save(path); err != nil {
+				t.Errorf("httpreplay: failed to save cassette: %v", err)
+			}
+		})
+		return rec
+	}
+
+	c, err := Load(path)
+	if err != nil {
+		t.Fatalf("httpreplay: %v", err)
+	}
+	return NewReplayer(c)
+}
+
+// Recorder пропускает запросы в next и запоминает ответы.
+type Recorder struct {
+	next http.RoundTripper
+
+	mu       sync.Mutex
+	cassette Cassette
+}
+
+func NewRecorder(next http.RoundTripper) *Recorder {
+	return &Recorder{next: next}
+}
+
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	reqBody, err := readBody(&req.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := r.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	respBody, err := readBody(&resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	saved := Response{Status: resp.StatusCode, Header: make(http.Header)}
+	for _, k := range keptHeaders {
+		if v := resp.Header.Values(k); len(v) > 0 {
+			saved.Header[k] = v
+		}
+	}
+	if utf8.Valid(respBody) {
+		saved.Body = string(respBody)
+	} else {
+		saved.BodyBase64 = base64.StdEncoding.EncodeToString(respBody)
+	}
+
+	r.mu.Lock()
+	r.cassette.Interactions = append(r.cassette.Interactions, Interaction{
+		Request:  Request{Method: req.Method, URL: req.URL.String(), Body: string(reqBody)},
+		Response: saved,
+	})
+	r.mu.Unlock()
+	return resp, nil
+}
+
+// Cassette возвращает копию записанного.
+func (r *Recorder) Cassette() *Cassette {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return &Cassette{Interactions: append([]Interaction(nil), r.cassette.Interactions...)}
+}
+
+// Replayer отвечает из кассеты. Одинаковые запросы получают записанные
+// ответы по очереди; последний повторяется. Незаписанный запрос — ошибка.
+type Replayer struct {
+	mu    sync.Mutex
+	byKey map[Request][]Response
+}
+
+func NewReplayer(c *Cassette) *Replayer {
+	r := &Replayer{byKey: make(map[Request][]Response)}
+	for _, in := range c.Interactions {
+		r.byKey[in.Request] = append(r.byKey[in.Request], in.Response)
+	}
+	return r
+}
+
+func (r *Replayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := readBody(&req.Body)
+	if err != nil {
+		return nil, err
+	}
+	key := Request{Method: req.Method, URL: req.URL.String(), Body: string(body)}
+
+	r.mu.Lock()
+	queue := r.byKey[key]
+	if len(queue) == 0 {
+		r.mu.Unlock()
+		return nil, fmt.Errorf("httpreplay: no recorded response for %s %s", req.Method, req.URL)
+	}
+	saved := queue[0]
+	if len(queue) > 1 {
+		r.byKey[key] = queue[1:]
+	}
+	r.mu.Unlock()
+
+	respBody := []byte(saved.Body)
+	if saved.BodyBase64 != "" {
+		if respBody, err = base64.StdEncoding.DecodeString(saved.BodyBase64); err != nil {
+			return nil, fmt.Errorf("httpreplay: bad body_base64 for %s: %w", req.URL, err)
+		}
+	}
+
+	header := saved.Header.Clone()
+	if header == nil {
+		header = make(http.Header)
+	}
+	contentLength := int64(len(respBody))
+	if req.Method == http.MethodHead {
+		if n, err := strconv.ParseInt(header.Get("Content-Length"), 10, 64); err == nil {
+			contentLength = n
+		}
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", saved.Status, http.StatusText(saved.Status)),
+		StatusCode:    saved.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(respBody)),
+		ContentLength: contentLength,
+		Request:       req,
+	}, nil
+}
+
+// readBody вычитывает тело и подменяет его копией, чтобы запрос/ответ остался читаемым.
+func readBody(body *io.ReadCloser) ([]byte, error) {
+	if *body == nil || *body == http.NoBody {
+		return nil, nil
+	}
+	data, err := io.ReadAll(*body)
+	(*body).Close()
+	if err != nil {
+		return nil, err
+	}
+	*body = io.NopCloser(bytes.NewReader(data))
+	return data, nil
+}
@@ -0,0 +1,103 @@
+package httpreplay
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func get(t *testing.T, client *http.Client, method, url, body string) (int, string) {
+	t.Helper()
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, url, err)
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, string(data)
+}
+
+func TestRecordThenReplay(t *testing.T) {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		switch r.URL.Path {
+		case "/page":
+			w.Header().Set("X-Request-Id", "dropped")
+			http.SetCookie(w, &http.Cookie{Name: "token", Value: "abc"})
+			io.WriteString(w, "page #"+strings.Repeat("!", calls))
+		case "/graphql":
+			body, _ := io.ReadAll(r.Body)
+			io.WriteString(w, `{"echo":"`+string(body)+`"}`)
+		case "/video":
+			w.Write([]byte{0x00, 0xff, 0xfe, 0x00})
+		}
+	}))
+	defer srv.Close()
+
+	rec := NewRecorder(http.DefaultTransport)
+	live := &http.Client{Transport: rec}
+	get(t, live, http.MethodGet, srv.URL+"/page", "")
+	get(t, live, http.MethodGet, srv.URL+"/page", "")
+	get(t, live, http.MethodPost, srv.URL+"/graphql", "a=1")
+	get(t, live, http.MethodPost, srv.URL+"/graphql", "a=2")
+	get(t, live, http.MethodGet, srv.URL+"/video", "")
+	get(t, live, http.MethodHead, srv.URL+"/video", "")
+
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	if err := rec.Cassette().Save(path); err != nil {
+		t.Fatal(err)
+	}
+	srv.Close()
+
+	c, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h := c.Interactions[0].Response.Header; h.Get("X-Request-Id") != "" || h.Get("Set-Cookie") == "" {
+		t.Fatalf("recorded headers = %v, want Set-Cookie only", h)
+	}
+
+	replay := &http.Client{Transport: NewReplayer(c)}
+	tests := []struct {
+		method, path, body, want string
+	}{
+		{http.MethodGet, "/page", "", "page #!"},
+		{http.MethodPost, "/graphql", "a=2", `{"echo":"a=2"}`},
+		{http.MethodGet, "/page", "", "page #!!"},
+		{http.MethodGet, "/page", "", "page #!!"}, // последний ответ повторяется
+		{http.MethodPost, "/graphql", "a=1", `{"echo":"a=1"}`},
+		{http.MethodGet, "/video", "", "\x00\xff\xfe\x00"},
+	}
+	for _, tt := range tests {
+		status, body := get(t, replay, tt.method, srv.URL+tt.path, tt.body)
+		if status != http.StatusOK || body != tt.want {
+			t.Errorf("%s %s = %d %q, want %q", tt.method, tt.path, status, body, tt.want)
+		}
+	}
+
+	// У HEAD тела нет — размер берётся из записанного Content-Length
+	resp, err := replay.Head(srv.URL + "/video")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.ContentLength != 4 {
+		t.Errorf("HEAD /video ContentLength = %d, want 4", resp.ContentLength)
+	}
+}
+
+func TestReplayUnknownRequest(t *testing.T) {
+	client := &http.Client{Transport: NewReplayer(&Cassette{})}
+	_, err := client.Get("https://example.com/missing")
+	if err == nil || !strings.Contains(err.Error(), "no recorded response") {
+		t.Fatalf("Get() error = %v, want no recorded response", err)
+	}
+}
@@ -133,6 +133,31 @@ func parseInstagram(p Parsed) (Parsed, error) {
 	return Parsed{}, ErrUnknownFormat
 }
 
+// CanonicalURL восстанавливает ссылку на видео по платформе и ID (например, из source_key кэша).
+// Пустая строка — платформа неизвестна.
+func CanonicalURL(t Type, videoID string) string {
+	switch t {
+	case TypeTikTok:
+		if isDigits(videoID) {
+			// Имя автора TikTok не проверяет — по ID откроется нужное видео
+			return "https://www.tiktok.com/@_/video/" + videoID
+		}
+		return "https://www.tiktok.com/t/" + videoID + "/"
+	case TypeInstagram:
+		return "https://www.instagram.com/p/" + videoID + "/"
+	}
+	return ""
+}
+
+func isDigits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
+
 func isAllowed(p Parsed, allowed map[string]struct{}) bool {
 	if _, ok := allowed[p.Host]; ok {
 		return true
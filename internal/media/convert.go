@@ -0,0 +1,74 @@
+package media
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"xa4yy_vidsave/internal/proc"
+)
+
+const (
+	// AnimationMaxWidth — ширина «гифки»: для реакции больше не нужно, а файл в разы меньше.
+	AnimationMaxWidth = 480
+	// VideoNoteSize — сторона кружка; Telegram показывает его не больше 640px.
+	VideoNoteSize = 384
+	// VideoNoteMaxDuration — лимит Telegram на длину кружка.
+	VideoNoteMaxDuration = 60 * time.Second
+)
+
+// h264Args — общие настройки кодирования, которые Telegram воспроизводит везде.
+var h264Args = []string{
+	"-c:v", "libx264",
+	"-preset", "veryfast",
+	"-crf", "26",
+	"-pix_fmt", "yuv420p",
+	"-movflags", "+faststart",
+}
+
+// Animation делает из видео зацикленную анимацию для sendAnimation:
+// без звука, не шире AnimationMaxWidth, не длиннее maxDuration.
+func (t Tools) Animation(ctx context.Context, in, out string, maxDuration time.Duration) error {
+	args := []string{
+		"-hide_banner", "-loglevel", "error",
+		"-i", in,
+		"-t", seconds(maxDuration),
+		"-an",
+		"-vf", fmt.Sprintf("scale='min(%d,iw)':-2,fps=30", AnimationMaxWidth),
+	}
+	args = append(args, h264Args...)
+	return t.encode(ctx, "animation", append(args, "-y", out))
+}
+
+// VideoNote делает квадратный кружок для sendVideoNote: центр кадра,
+// VideoNoteSize×VideoNoteSize, не длиннее VideoNoteMaxDuration, звук сохраняется.
+func (t Tools) VideoNote(ctx context.Context, in, out string) error {
+	args := []string{
+		"-hide_banner", "-loglevel", "error",
+		"-i", in,
+		"-t", seconds(VideoNoteMaxDuration),
+		"-vf", fmt.Sprintf("crop='min(iw,ih)':'min(iw,ih)',scale=%d:%d", VideoNoteSize, VideoNoteSize),
+		"-c:a", "aac", "-b:a", "64k",
+	}
+	args = append(args, h264Args...)
+	return t.encode(ctx, "video note", append(args, "-y", out))
+}
+
+func (t Tools) encode(ctx context.Context, what string, args []string) error {
+	out, err := proc.Run(ctx, t.ffmpeg(), args, t.Limits)
+	if err != nil {
+		os.Remove(args[len(args)-1])
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return fmt.Errorf("ffmpeg %s failed: %w: %s", what, err, strings.TrimSpace(out.Stderr))
+	}
+	return nil
+}
+
+// seconds форматирует длительность для ffmpeg (-t, -ss) с точностью до миллисекунд.
+func seconds(d time.Duration) string {
+	return strconv.FormatFloat(d.Seconds(), 'f', 3, 64)
+}
@@ -0,0 +1,69 @@
+package media
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"xa4yy_vidsave/internal/proc"
+)
+
+// fakeFFmpeg — скрипт, который записывает свои аргументы в выходной файл (последний аргумент).
+func fakeFFmpeg(t *testing.T) Tools {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "ffmpeg")
+	script := "#!/bin/sh\nfor last; do :; done\necho \"$*\" > \"$last\"\n"
+	if err := os.WriteFile(path, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return Tools{FFmpeg: path, Limits: proc.Limits{CPUTime: time.Minute}}
+}
+
+func TestConvertPresets(t *testing.T) {
+	tools := fakeFFmpeg(t)
+	dir := t.TempDir()
+
+	tests := []struct {
+		name    string
+		convert func(out string) error
+		want    []string
+		notWant []string
+	}{
+		{
+			name:    "animation",
+			convert: func(out string) error { return tools.Animation(context.Background(), "in.mp4", out, 15*time.Second) },
+			want:    []string{"-t 15.000", "-an", "scale='min(480,iw)':-2", "-c:v libx264", "+faststart"},
+		},
+		{
+			name:    "video note",
+			convert: func(out string) error { return tools.VideoNote(context.Background(), "in.mp4", out) },
+			want:    []string{"-t 60.000", "crop='min(iw,ih)':'min(iw,ih)',scale=384:384", "-c:a aac"},
+			notWant: []string{"-an"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := filepath.Join(dir, strings.ReplaceAll(tt.name, " ", "_")+".mp4")
+			if err := tt.convert(out); err != nil {
+				t.Fatalf("convert error = %v", err)
+			}
+			args, err := os.ReadFile(out)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, w := range tt.want {
+				if !strings.Contains(string(args), w) {
+					t.Errorf("ffmpeg args %q missing %q", args, w)
+				}
+			}
+			for _, w := range tt.notWant {
+				if strings.Contains(string(args), " "+w+" ") {
+					t.Errorf("ffmpeg args %q must not contain %q", args, w)
+				}
+			}
+		})
+	}
+}
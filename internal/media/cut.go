@@ -0,0 +1,79 @@
+package media
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"xa4yy_vidsave/internal/proc"
+)
+
+// keyframeTolerance — насколько граница может не совпасть с ключевым кадром
+// и всё равно резаться без перекодирования.
+const keyframeTolerance = 0.05
+
+// Keyframes возвращает время ключевых кадров первого видеопотока в секундах.
+// ffprobe декодирует только ключевые кадры, так что это быстро.
+func (t Tools) Keyframes(ctx context.Context, path string) ([]float64, error) {
+	out, err := proc.Run(ctx, t.ffprobe(), []string{
+		"-v", "error",
+		"-select_streams", "v:0",
+		"-skip_frame", "nokey",
+		"-show_entries", "frame=best_effort_timestamp_time",
+		"-of", "csv=p=0",
+		path,
+	}, t.Limits)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, fmt.Errorf("%w: %s", ErrBrokenContainer, strings.TrimSpace(out.Stderr))
+	}
+
+	var keyframes []float64
+	scanner := bufio.NewScanner(strings.NewReader(out.Stdout))
+	for scanner.Scan() {
+		ts, err := strconv.ParseFloat(strings.Trim(strings.TrimSpace(scanner.Text()), ","), 64)
+		if err == nil {
+			keyframes = append(keyframes, ts)
+		}
+	}
+	return keyframes, nil
+}
+
+// OnKeyframes — можно ли вырезать [start, end] без перекодирования: начало попадает
+// в ключевой кадр, конец — в ключевой кадр или в конец видео (duration).
+func OnKeyframes(keyframes []float64, start, end, duration float64) bool {
+	near := func(ts float64) bool {
+		for _, k := range keyframes {
+			if math.Abs(k-ts) <= keyframeTolerance {
+				return true
+			}
+		}
+		return false
+	}
+	endOK := near(end) || (duration > 0 && end >= duration-keyframeTolerance)
+	return near(start) && endOK
+}
+
+// Cut вырезает кусок [start, end). copy — без перекодирования (границы должны быть
+// на ключевых кадрах, см. OnKeyframes), иначе H.264/AAC с точными границами.
+func (t Tools) Cut(ctx context.Context, in, out string, start, end time.Duration, copy bool) error {
+	args := []string{
+		"-hide_banner", "-loglevel", "error",
+		"-ss", seconds(start),
+		"-i", in,
+		"-t", seconds(end - start),
+		"-map", "0:v:0", "-map", "0:a:0?",
+	}
+	if copy {
+		args = append(args, "-c", "copy", "-avoid_negative_ts", "make_zero", "-movflags", "+faststart")
+	} else {
+		args = append(args, h264Args...)
+		args = append(args, "-c:a", "aac", "-b:a", "128k")
+	}
+	return t.encode(ctx, "cut", append(args, "-y", out))
+}
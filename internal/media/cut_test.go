@@ -0,0 +1,49 @@
+package media
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCutArgs(t *testing.T) {
+	tools := fakeFFmpeg(t)
+	dir := t.TempDir()
+
+	for _, copy := range []bool{true, false} {
+		out := filepath.Join(dir, "cut.mp4")
+		if err := tools.Cut(context.Background(), "in.mp4", out, 12*time.Second, 27500*time.Millisecond, copy); err != nil {
+			t.Fatalf("Cut(copy=%v) error = %v", copy, err)
+		}
+		args, _ := os.ReadFile(out)
+		if !strings.Contains(string(args), "-ss 12.000 -i in.mp4 -t 15.500") {
+			t.Errorf("Cut(copy=%v) args %q: wrong boundaries", copy, args)
+		}
+		if got := strings.Contains(string(args), "-c copy"); got != copy {
+			t.Errorf("Cut(copy=%v) args %q: stream copy = %v", copy, args, got)
+		}
+	}
+}
+
+func TestOnKeyframes(t *testing.T) {
+	keyframes := []float64{0, 2.002, 4.004, 6.006}
+
+	tests := []struct {
+		name       string
+		start, end float64
+		want       bool
+	}{
+		{"both on keyframes", 2, 6, true},
+		{"end at video end", 4, 7.5, true},
+		{"start between keyframes", 3, 6, false},
+		{"end between keyframes", 0, 5, false},
+	}
+	for _, tt := range tests {
+		if got := OnKeyframes(keyframes, tt.start, tt.end, 7.5); got != tt.want {
+			t.Errorf("%s: OnKeyframes(%v, %v) = %v, want %v", tt.name, tt.start, tt.end, got, tt.want)
+		}
+	}
+}
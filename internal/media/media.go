@@ -0,0 +1,148 @@
+// Package media проверяет и обрабатывает видеофайлы через ffprobe/ffmpeg.
+package media
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"xa4yy_vidsave/internal/proc"
+)
+
+var (
+	ErrBrokenContainer = errors.New("broken media container")
+	ErrNoVideoStream   = errors.New("file has no video stream")
+	ErrZeroDuration    = errors.New("file has zero duration")
+)
+
+// Tools — пути к ffmpeg/ffprobe и лимиты их процессов.
+type Tools struct {
+	FFmpeg  string // пусто — "ffmpeg" из PATH
+	FFprobe string // пусто — "ffprobe" из PATH
+	Limits  proc.Limits
+}
+
+func (t Tools) ffmpeg() string {
+	if t.FFmpeg != "" {
+		return t.FFmpeg
+	}
+	return "ffmpeg"
+}
+
+func (t Tools) ffprobe() string {
+	if t.FFprobe != "" {
+		return t.FFprobe
+	}
+	return "ffprobe"
+}
+
+// Stream — поток внутри контейнера.
+type Stream struct {
+	CodecType string `json:"codec_type"` // video, audio, subtitle, data
+	CodecName string `json:"codec_name"`
+	Width     int    `json:"width"`
+	Height    int    `json:"height"`
+	Duration  string `json:"duration"`
+}
+
+// Info — результат ffprobe.
+type Info struct {
+	Streams []Stream `json:"streams"`
+	Format  struct {
+		FormatName string `json:"format_name"`
+		Duration   string `json:"duration"`
+	} `json:"format"`
+}
+
+// Video возвращает первый видеопоток.
+func (i *Info) Video() (Stream, bool) {
+	for _, s := range i.Streams {
+		// Обложка (mjpeg/png attached_pic) — тоже «video», но это картинка.
+		if s.CodecType == "video" && s.CodecName != "mjpeg" && s.CodecName != "png" {
+			return s, true
+		}
+	}
+	return Stream{}, false
+}
+
+// Duration — длительность в секундах (по контейнеру, иначе по видеопотоку).
+func (i *Info) Duration() float64 {
+	if d, err := strconv.ParseFloat(i.Format.Duration, 64); err == nil && d > 0 {
+		return d
+	}
+	if v, ok := i.Video(); ok {
+		if d, err := strconv.ParseFloat(v.Duration, 64); err == nil {
+			return d
+		}
+	}
+	return 0
+}
+
+// IsMP4 — контейнер семейства ISO BMFF (mp4/mov), где бывает moov в конце.
+func (i *Info) IsMP4() bool {
+	return strings.Contains(i.Format.FormatName, "mp4") || strings.Contains(i.Format.FormatName, "mov")
+}
+
+// Probe читает потоки и формат файла через ffprobe.
+func (t Tools) Probe(ctx context.Context, path string) (*Info, error) {
+	out, err := proc.Run(ctx, t.ffprobe(), []string{
+		"-v", "error",
+		"-show_entries", "stream=codec_type,codec_name,width,height,duration:format=format_name,duration",
+		"-of", "json",
+		path,
+	}, t.Limits)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, fmt.Errorf("%w: %s", ErrBrokenContainer, strings.TrimSpace(out.Stderr))
+	}
+
+	var info Info
+	if err := json.Unmarshal([]byte(out.Stdout), &info); err != nil {
+		return nil, fmt.Errorf("%w: failed to parse ffprobe output: %v", ErrBrokenContainer, err)
+	}
+	return &info, nil
+}
+
+// Validate проверяет, что файл — настоящее видео: контейнер читается,
+// есть видеопоток и ненулевая длительность.
+func (t Tools) Validate(ctx context.Context, path string) (*Info, error) {
+	info, err := t.Probe(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := info.Video(); !ok {
+		return info, ErrNoVideoStream
+	}
+	if info.Duration() <= 0 {
+		return info, ErrZeroDuration
+	}
+	return info, nil
+}
+
+// Faststart переносит moov atom в начало mp4 без перекодирования,
+// чтобы Telegram мог стримить видео. Файл заменяется на месте.
+func (t Tools) Faststart(ctx context.Context, path string) error {
+	tmp := filepath.Join(filepath.Dir(path), "faststart_"+filepath.Base(path))
+	out, err := proc.Run(ctx, t.ffmpeg(), []string{
+		"-hide_banner", "-loglevel", "error",
+		"-i", path,
+		"-map", "0",
+		"-c", "copy",
+		"-movflags", "+faststart",
+		"-y", tmp,
+	}, t.Limits)
+	if err != nil {
+		os.Remove(tmp)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return fmt.Errorf("ffmpeg faststart failed: %w: %s", err, strings.TrimSpace(out.Stderr))
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,63 @@
+package media
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+)
+
+// NeedsFaststart читает заголовки верхнеуровневых MP4-боксов и сообщает,
+// идут ли данные (mdat) раньше индекса (moov). Такой файл Telegram не может
+// стримить, пока не скачает целиком.
+func NeedsFaststart(path string) (bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return false, err
+	}
+	return mdatBeforeMoov(f, info.Size())
+}
+
+func mdatBeforeMoov(r io.ReadSeeker, size int64) (bool, error) {
+	var offset int64
+	header := make([]byte, 16)
+
+	for offset+8 <= size {
+		if _, err := r.Seek(offset, io.SeekStart); err != nil {
+			return false, err
+		}
+		if _, err := io.ReadFull(r, header[:8]); err != nil {
+			return false, err
+		}
+
+		boxSize := int64(binary.BigEndian.Uint32(header[:4]))
+		boxType := string(header[4:8])
+		switch boxSize {
+		case 0: // бокс до конца файла
+			boxSize = size - offset
+		case 1: // 64-битный размер следом за типом
+			if _, err := io.ReadFull(r, header[8:16]); err != nil {
+				return false, err
+			}
+			boxSize = int64(binary.BigEndian.Uint64(header[8:16]))
+		}
+		if boxSize < 8 {
+			return false, fmt.Errorf("%w: invalid mp4 box %q size %d", ErrBrokenContainer, boxType, boxSize)
+		}
+
+		switch boxType {
+		case "moov":
+			return false, nil
+		case "mdat":
+			return true, nil
+		}
+		offset += boxSize
+	}
+	return false, fmt.Errorf("%w: mp4 has no moov box", ErrBrokenContainer)
+}
@@ -0,0 +1,56 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+)
+
+func box(typ string, payload int) []byte {
+	b := make([]byte, 8+payload)
+	binary.BigEndian.PutUint32(b[:4], uint32(8+payload))
+	copy(b[4:8], typ)
+	return b
+}
+
+func largeBox(typ string, payload int) []byte {
+	b := make([]byte, 16+payload)
+	binary.BigEndian.PutUint32(b[:4], 1)
+	copy(b[4:8], typ)
+	binary.BigEndian.PutUint64(b[8:16], uint64(16+payload))
+	return b
+}
+
+func TestMdatBeforeMoov(t *testing.T) {
+	tests := []struct {
+		name    string
+		boxes   [][]byte
+		want    bool
+		wantErr error
+	}{
+		{name: "faststart layout", boxes: [][]byte{box("ftyp", 16), box("moov", 64), box("mdat", 256)}, want: false},
+		{name: "moov at the end", boxes: [][]byte{box("ftyp", 16), box("free", 8), box("mdat", 256), box("moov", 64)}, want: true},
+		{name: "64-bit mdat before moov", boxes: [][]byte{box("ftyp", 16), largeBox("mdat", 128), box("moov", 64)}, want: true},
+		{name: "no moov", boxes: [][]byte{box("ftyp", 16), box("free", 8)}, wantErr: ErrBrokenContainer},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := bytes.Join(tt.boxes, nil)
+			got, err := mdatBeforeMoov(bytes.NewReader(data), int64(len(data)))
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("mdatBeforeMoov() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("mdatBeforeMoov() error = %v", err)
+			}
+			if got != tt.want {
+				t.Fatalf("mdatBeforeMoov() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,127 @@
+package media
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// ErrNoCues — в файле субтитров не нашлось ни одной реплики.
+var ErrNoCues = errors.New("subtitles have no cues")
+
+var (
+	// reVTTTiming — строка тайминга WebVTT; настройки позиции после таймкодов отбрасываются.
+	reVTTTiming = regexp.MustCompile(`^((?:\d+:)?\d{2}:\d{2}\.\d{3})\s+-->\s+((?:\d+:)?\d{2}:\d{2}\.\d{3})`)
+	// reVTTTag — теги WebVTT (<c.color>, <v Name>, <00:00:01.000>, <ruby>...), кроме <b>, <i>, <u>,
+	// которые SRT тоже понимает.
+	reVTTTag = regexp.MustCompile(`</?([a-z0-9.]*)(?:[ .][^>]*)?>|<\d[^>]*>`)
+)
+
+var vttEntities = strings.NewReplacer("&amp;", "&", "&lt;", "<", "&gt;", ">", "&nbsp;", " ", "&lrm;", "", "&rlm;", "")
+
+type cue struct {
+	start, end string
+	text       string
+}
+
+// VTTToSRT конвертирует WebVTT в SubRip. Блоки NOTE/STYLE/REGION пропускаются,
+// идущие подряд одинаковые реплики (так выглядят авто-субтитры) склеиваются в одну.
+func VTTToSRT(data []byte) ([]byte, error) {
+	data = bytes.TrimPrefix(data, []byte("\xEF\xBB\xBF"))
+	data = bytes.ReplaceAll(data, []byte("\r\n"), []byte("\n"))
+
+	var cues []cue
+	for _, block := range strings.Split(string(data), "\n\n") {
+		lines := strings.Split(strings.Trim(block, "\n"), "\n")
+		// Таймкод — в первой или второй строке (перед ним может быть id реплики)
+		timing := -1
+		for i := 0; i < len(lines) && i < 2; i++ {
+			if reVTTTiming.MatchString(strings.TrimSpace(lines[i])) {
+				timing = i
+				break
+			}
+		}
+		if timing < 0 {
+			continue // WEBVTT, NOTE, STYLE, REGION
+		}
+
+		m := reVTTTiming.FindStringSubmatch(strings.TrimSpace(lines[timing]))
+		text := cleanCueText(lines[timing+1:])
+		if text == "" {
+			continue
+		}
+
+		c := cue{start: srtTimestamp(m[1]), end: srtTimestamp(m[2]), text: text}
+		if n := len(cues); n > 0 && cues[n-1].text == c.text {
+			cues[n-1].end = c.end
+			continue
+		}
+		cues = append(cues, c)
+	}
+
+	if len(cues) == 0 {
+		return nil, ErrNoCues
+	}
+
+	var out bytes.Buffer
+	for i, c := range cues {
+		fmt.Fprintf(&out, "%d\n%s --> %s\n%s\n\n", i+1, c.start, c.end, c.text)
+	}
+	return out.Bytes(), nil
+}
+
+// NormalizeSRT приводит готовый SRT к виду, который понимают все плееры:
+// без BOM, с \n и пустой строкой после каждой реплики.
+func NormalizeSRT(data []byte) ([]byte, error) {
+	data = bytes.TrimPrefix(data, []byte("\xEF\xBB\xBF"))
+	data = bytes.ReplaceAll(data, []byte("\r\n"), []byte("\n"))
+
+	var out bytes.Buffer
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	cues := 0
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \t")
+		if strings.Contains(line, "-->") {
+			cues++
+		}
+		out.WriteString(line)
+		out.WriteByte('\n')
+	}
+	if cues == 0 {
+		return nil, ErrNoCues
+	}
+	return append(bytes.TrimRight(out.Bytes(), "\n"), '\n', '\n'), nil
+}
+
+func cleanCueText(lines []string) string {
+	var kept []string
+	for _, line := range lines {
+		line = reVTTTag.ReplaceAllStringFunc(line, func(tag string) string {
+			name := strings.TrimLeft(strings.TrimSuffix(tag, ">"), "</")
+			if name == "b" || name == "i" || name == "u" {
+				return tag
+			}
+			return ""
+		})
+		line = strings.TrimSpace(vttEntities.Replace(line))
+		if line != "" {
+			kept = append(kept, line)
+		}
+	}
+	return strings.Join(kept, "\n")
+}
+
+// srtTimestamp переводит "mm:ss.ttt" или "h:mm:ss.ttt" в "hh:mm:ss,ttt".
+func srtTimestamp(ts string) string {
+	parts := strings.Split(ts, ":")
+	if len(parts) == 2 {
+		parts = append([]string{"00"}, parts...)
+	}
+	if len(parts[0]) < 2 {
+		parts[0] = "0" + parts[0]
+	}
+	return strings.Replace(strings.Join(parts, ":"), ".", ",", 1)
+}
@@ -0,0 +1,65 @@
+package media
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestVTTToSRT(t *testing.T) {
+	tests := []struct {
+		name    string
+		vtt     string
+		want    string
+		wantErr error
+	}{
+		{
+			name: "basic with header, note and cue ids",
+			vtt: "\xEF\xBB\xBFWEBVTT\r\nKind: captions\r\nLanguage: en\r\n\r\n" +
+				"NOTE generated by TikTok\r\n\r\n" +
+				"1\r\n00:00.000 --> 00:02.500 align:start position:0%\r\nHello <b>world</b>\r\n\r\n" +
+				"intro-2\r\n01:02:03.040 --> 01:02:04.000\r\n<v Alice>Tom &amp; Jerry</v>\r\n<c.yellow>second line</c>\r\n",
+			want: "1\n00:00:00,000 --> 00:00:02,500\nHello <b>world</b>\n\n" +
+				"2\n01:02:03,040 --> 01:02:04,000\nTom & Jerry\nsecond line\n\n",
+		},
+		{
+			name: "auto captions duplicates are merged, karaoke timings stripped",
+			vtt: "WEBVTT\n\n" +
+				"00:01.000 --> 00:02.000\nwe<00:01.500><c> go</c>\n\n" +
+				"00:02.000 --> 00:03.000\nwe go\n\n" +
+				"00:03.000 --> 00:04.000\n \n\n" +
+				"00:04.000 --> 00:05.000\nnext\n",
+			want: "1\n00:00:01,000 --> 00:00:03,000\nwe go\n\n" +
+				"2\n00:00:04,000 --> 00:00:05,000\nnext\n\n",
+		},
+		{
+			name:    "no cues",
+			vtt:     "WEBVTT\n\nSTYLE\n::cue { color: white }\n",
+			wantErr: ErrNoCues,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := VTTToSRT([]byte(tt.vtt))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("VTTToSRT() error = %v, want %v", err, tt.wantErr)
+			}
+			if string(got) != tt.want {
+				t.Fatalf("VTTToSRT() =\n%q\nwant\n%q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeSRT(t *testing.T) {
+	got, err := NormalizeSRT([]byte("\xEF\xBB\xBF1\r\n00:00:01,000 --> 00:00:02,000\r\nhi  \r\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "1\n00:00:01,000 --> 00:00:02,000\nhi\n\n"; string(got) != want {
+		t.Fatalf("NormalizeSRT() = %q, want %q", got, want)
+	}
+	if _, err := NormalizeSRT([]byte("just text")); !errors.Is(err, ErrNoCues) {
+		t.Fatalf("NormalizeSRT(text) error = %v, want ErrNoCues", err)
+	}
+}
@@ -0,0 +1,109 @@
+// Package proc запускает внешние программы (yt-dlp, ffmpeg) в «песочнице»:
+// отдельная группа процессов, лимиты ресурсов и ограниченный захват вывода.
+package proc
+
+import (
+	"context"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultMaxOutput — сколько байт stdout/stderr сохраняем по умолчанию.
+	DefaultMaxOutput = 64 * 1024
+	// waitDelay — сколько ждём закрытия pipe'ов после kill, прежде чем бросить их.
+	waitDelay = 5 * time.Second
+)
+
+// Limits — ограничения ресурсов дочернего процесса. Нулевые значения — без лимита.
+type Limits struct {
+	CPUTime   time.Duration // RLIMIT_CPU: процессорное время каждого процесса группы, не суммарно
+	Memory    int64         // RLIMIT_AS: адресное пространство, байт
+	MaxOutput int           // сколько последних байт stdout/stderr сохраняем (0 — DefaultMaxOutput)
+}
+
+// Result — захваченный вывод процесса.
+type Result struct {
+	Stdout    string
+	Stderr    string
+	Truncated bool // вывод был длиннее MaxOutput и обрезан с начала
+}
+
+// Run запускает name с аргументами args и ждёт завершения.
+// Процесс и все его потомки живут в отдельной группе: при отмене ctx
+// (а на Linux и после выхода лидера) вся группа получает SIGKILL — ffmpeg не переживёт yt-dlp.
+func Run(ctx context.Context, name string, args []string, limits Limits) (Result, error) {
+	name, args = wrapLimits(name, args, limits)
+	cmd := exec.CommandContext(ctx, name, args...)
+	setProcessGroup(cmd)
+	cmd.WaitDelay = waitDelay
+
+	maxOutput := limits.MaxOutput
+	if maxOutput <= 0 {
+		maxOutput = DefaultMaxOutput
+	}
+	stdout := newTailBuffer(maxOutput)
+	stderr := newTailBuffer(maxOutput)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	err := cmd.Start()
+	if err == nil {
+		// Добиваем осиротевших потомков, даже если лидер вышел сам, — но до того,
+		// как Wait его приберёт: после этого номер группы может стать чужим.
+		if waitExited(cmd) {
+			killProcessGroup(cmd)
+		}
+		err = cmd.Wait()
+	}
+
+	return Result{
+		Stdout:    stdout.String(),
+		Stderr:    stderr.String(),
+		Truncated: stdout.Truncated() || stderr.Truncated(),
+	}, err
+}
+
+// tailBuffer — io.Writer, хранящий только последние max байт.
+// Для yt-dlp полезен именно хвост: там итоговый путь и строка ERROR.
+type tailBuffer struct {
+	mu        sync.Mutex
+	buf       []byte
+	max       int
+	truncated bool
+}
+
+func newTailBuffer(max int) *tailBuffer {
+	return &tailBuffer{max: max}
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	n := len(p)
+	if len(p) >= b.max {
+		b.buf = append(b.buf[:0], p[len(p)-b.max:]...)
+		b.truncated = true
+		return n, nil
+	}
+	if overflow := len(b.buf) + len(p) - b.max; overflow > 0 {
+		b.buf = append(b.buf[:0], b.buf[overflow:]...)
+		b.truncated = true
+	}
+	b.buf = append(b.buf, p...)
+	return n, nil
+}
+
+func (b *tailBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return string(b.buf)
+}
+
+func (b *tailBuffer) Truncated() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.truncated
+}
@@ -0,0 +1,14 @@
+//go:build !unix
+
+package proc
+
+import "os/exec"
+
+// На не-unix системах групп процессов и rlimit нет — убиваем только сам процесс.
+func setProcessGroup(*exec.Cmd) {}
+
+func killProcessGroup(*exec.Cmd) {}
+
+func wrapLimits(name string, args []string, _ Limits) (string, []string) {
+	return name, args
+}
@@ -0,0 +1,129 @@
+//go:build unix
+
+package proc
+
+import (
+	"context"
+	"errors"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestTailBuffer(t *testing.T) {
+	tests := []struct {
+		name          string
+		writes        []string
+		want          string
+		wantTruncated bool
+	}{
+		{"fits", []string{"abc", "de"}, "abcde", false},
+		{"exactly max", []string{"abcd", "e"}, "abcde", false},
+		{"overflow keeps tail", []string{"abc", "defg"}, "cdefg", true},
+		{"single write longer than max", []string{"0123456789"}, "56789", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newTailBuffer(5)
+			for _, w := range tt.writes {
+				if n, err := b.Write([]byte(w)); n != len(w) || err != nil {
+					t.Fatalf("Write(%q) = %d, %v", w, n, err)
+				}
+			}
+			if got := b.String(); got != tt.want {
+				t.Errorf("String() = %q, want %q", got, tt.want)
+			}
+			if got := b.Truncated(); got != tt.wantTruncated {
+				t.Errorf("Truncated() = %v, want %v", got, tt.wantTruncated)
+			}
+		})
+	}
+}
+
+func TestRunCapsOutput(t *testing.T) {
+	res, err := Run(context.Background(), "/bin/sh", []string{"-c", "printf 0123456789; printf oops >&2"}, Limits{MaxOutput: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Stdout != "6789" || res.Stderr != "oops" || !res.Truncated {
+		t.Errorf("Run = %+v; want stdout tail 6789, stderr oops, truncated", res)
+	}
+}
+
+func TestRunLimitsKeepArguments(t *testing.T) {
+	args := []string{"-c", `ulimit -t; printf '%s|' "$@"`, "sh", "a b", "it's", `"quoted"`, "$HOME", "*"}
+	res, err := Run(context.Background(), "/bin/sh", args, Limits{CPUTime: 7 * time.Second, Memory: 1 << 30})
+	if err != nil {
+		t.Fatalf("Run error = %v, stderr %q", err, res.Stderr)
+	}
+	want := "7\na b|it's|\"quoted\"|$HOME|*|"
+	if res.Stdout != want {
+		t.Errorf("stdout = %q, want %q", res.Stdout, want)
+	}
+}
+
+func TestRunKillsGrandchildren(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+		ctx    func() (context.Context, context.CancelFunc)
+	}{
+		{
+			// Лидер вышел сам, а фоновый потомок остался бы сиротой
+			name:   "leader exits",
+			script: "sleep 30 >/dev/null 2>&1 & echo $!",
+			ctx:    func() (context.Context, context.CancelFunc) { return context.WithCancel(context.Background()) },
+		},
+		{
+			name:   "context cancelled",
+			script: "sleep 30 >/dev/null 2>&1 & echo $!; sleep 30",
+			ctx: func() (context.Context, context.CancelFunc) {
+				return context.WithTimeout(context.Background(), 300*time.Millisecond)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.name == "leader exits" && runtime.GOOS != "linux" {
+				t.Skip("orphans of an exited leader are only killed on linux")
+			}
+			ctx, cancel := tt.ctx()
+			defer cancel()
+
+			start := time.Now()
+			res, _ := Run(ctx, "/bin/sh", []string{"-c", tt.script}, Limits{})
+			if elapsed := time.Since(start); elapsed > 10*time.Second {
+				t.Fatalf("Run took %v, want it to return without waiting for sleep", elapsed)
+			}
+			pid, err := strconv.Atoi(strings.TrimSpace(res.Stdout))
+			if err != nil {
+				t.Fatalf("no grandchild pid in stdout %q", res.Stdout)
+			}
+			waitDead(t, pid)
+		})
+	}
+}
+
+// waitDead ждёт, пока процесс pid исчезнет (или станет зомби, которого ещё не прибрал init).
+func waitDead(t *testing.T, pid int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if err := syscall.Kill(pid, 0); errors.Is(err, syscall.ESRCH) {
+			return
+		}
+		if stat, err := os.ReadFile("/proc/" + strconv.Itoa(pid) + "/stat"); err == nil {
+			// Формат: pid (comm) state ...
+			if fields := strings.Fields(string(stat[strings.LastIndexByte(string(stat), ')')+1:])); len(fields) > 0 && fields[0] == "Z" {
+				return
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	_ = syscall.Kill(pid, syscall.SIGKILL)
+	t.Fatalf("grandchild %d outlived Run", pid)
+}
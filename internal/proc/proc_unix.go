@@ -0,0 +1,43 @@
+//go:build unix
+
+package proc
+
+import (
+	"fmt"
+	"os/exec"
+	"syscall"
+)
+
+// setProcessGroup запускает процесс лидером новой группы и при отмене
+// контекста убивает всю группу, а не только лидера.
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+}
+
+func killProcessGroup(cmd *exec.Cmd) {
+	if cmd.Process != nil {
+		_ = syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+}
+
+// wrapLimits выставляет rlimit'ы через ulimit в sh и затем exec'ает саму программу,
+// так что PID (и группа процессов) остаются теми же. rlimit'ы наследуются, но считаются
+// на процесс: каждый ffmpeg, запущенный yt-dlp, получает свой полный лимит CPU.
+// Общую длительность задачи ограничивает только контекст (JOB_TIMEOUT).
+func wrapLimits(name string, args []string, limits Limits) (string, []string) {
+	script := ""
+	if limits.CPUTime > 0 {
+		script += fmt.Sprintf("ulimit -t %d && ", max(1, int64(limits.CPUTime.Seconds())))
+	}
+	if limits.Memory > 0 {
+		script += fmt.Sprintf("ulimit -v %d && ", max(1, limits.Memory/1024))
+	}
+	if script == "" {
+		return name, args
+	}
+	script += `exec "$0" "$@"`
+	return "/bin/sh", append([]string{"-c", script, name}, args...)
+}
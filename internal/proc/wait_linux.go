@@ -0,0 +1,31 @@
+//go:build linux
+
+package proc
+
+import (
+	"os/exec"
+	"syscall"
+	"unsafe"
+)
+
+// pPID — idtype P_PID для waitid(2); в пакете syscall его нет.
+const pPID = 1
+
+// waitExited ждёт выхода лидера, не прибирая его (WNOWAIT): пока лидер — зомби,
+// его PID, а значит и номер группы, никому не достанется, и SIGKILL группе
+// не попадёт в чужие процессы. false — дождаться не удалось, группу не трогаем.
+func waitExited(cmd *exec.Cmd) bool {
+	var info [128]byte // siginfo_t, содержимое не нужно
+	for {
+		_, _, errno := syscall.Syscall6(syscall.SYS_WAITID, pPID, uintptr(cmd.Process.Pid),
+			uintptr(unsafe.Pointer(&info)), syscall.WEXITED|syscall.WNOWAIT, 0, 0)
+		switch errno {
+		case 0:
+			return true
+		case syscall.EINTR:
+			continue
+		default:
+			return false
+		}
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+
+package proc
+
+import "os/exec"
+
+// Дождаться выхода лидера, не прибирая его, здесь нечем, а после cmd.Wait номер
+// группы может достаться чужим процессам. Поэтому группа убивается только при
+// отмене ctx (cmd.Cancel), а потомков лидера, вышедшего сам, не трогаем.
+func waitExited(*exec.Cmd) bool { return false }
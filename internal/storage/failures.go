@@ -0,0 +1,38 @@
+package storage
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// --- Негативный кэш ---
+
+// LookupFailure возвращает ещё не истёкшую запись о неудаче по source_key.
+func (s *Storage) LookupFailure(sourceKey string) (*Failure, error) {
+	var entry Failure
+	result := s.db.Where("source_key = ? AND expires_at > ?", sourceKey, time.Now()).First(&entry)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, result.Error
+	}
+	return &entry, nil
+}
+
+// SaveFailure запоминает неудачу; повторная неудача того же ключа перезаписывает класс и срок.
+func (s *Storage) SaveFailure(entry *Failure) error {
+	return s.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "source_key"}},
+		DoUpdates: clause.AssignmentColumns([]string{"class", "text", "expires_at", "updated_at"}),
+	}).Create(entry).Error
+}
+
+// PurgeFailures удаляет записи, истёкшие до expiredBefore.
+func (s *Storage) PurgeFailures(expiredBefore time.Time) (int64, error) {
+	result := s.db.Where("expires_at < ?", expiredBefore).Delete(&Failure{})
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,182 @@
+package storage
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// maxJobErrorLen — сколько символов ошибки храним в jobs.last_error.
+const maxJobErrorLen = 1024
+
+// errLeaseExpired — last_error задачи, чей инстанс перестал продлевать аренду.
+const errLeaseExpired = "job lease expired: worker instance died"
+
+// --- Очередь задач ---
+
+// EnqueueJob ставит задачу в очередь. Задача готова к выполнению сразу.
+func (s *Storage) EnqueueJob(job *Job) error {
+	job.Status = JobPending
+	job.NextRunAt = time.Now()
+	if job.MaxAttempts < 1 {
+		job.MaxAttempts = 1
+	}
+	return s.db.Create(job).Error
+}
+
+// ClaimJob забирает одну готовую задачу, помечает её running и берёт в аренду на lease.
+// SELECT ... FOR UPDATE SKIP LOCKED позволяет нескольким воркерам (и инстансам)
+// разбирать очередь параллельно, не блокируя друг друга.
+// Если готовых задач нет — возвращает ErrNoJobs.
+func (s *Storage) ClaimJob(lease time.Duration) (*Job, error) {
+	var job Job
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: clause.LockingOptionsSkipLocked}).
+			Where("status = ? AND next_run_at <= ?", JobPending, time.Now()).
+			Order("next_run_at, id").
+			First(&job)
+		if result.Error != nil {
+			return result.Error
+		}
+
+		now := time.Now()
+		leaseUntil := now.Add(lease)
+		job.Status = JobRunning
+		job.Attempts++
+		job.StartedAt = &now
+		job.LeaseUntil = &leaseUntil
+		return tx.Model(&job).Updates(map[string]interface{}{
+			"status":      job.Status,
+			"attempts":    job.Attempts,
+			"started_at":  now,
+			"lease_until": leaseUntil,
+		}).Error
+	})
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNoJobs
+		}
+		return nil, err
+	}
+	return &job, nil
+}
+
+// RenewJobLease продлевает аренду выполняющейся задачи на lease от текущего момента.
+func (s *Storage) RenewJobLease(id uint, lease time.Duration) error {
+	return s.db.Model(&Job{}).Where("id = ? AND status = ?", id, JobRunning).
+		Update("lease_until", time.Now().Add(lease)).Error
+}
+
+// RetryJob возвращает задачу в очередь; следующая попытка не раньше nextRunAt.
+func (s *Storage) RetryJob(id uint, lastError string, nextRunAt time.Time) error {
+	return s.db.Model(&Job{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"status":      JobPending,
+		"next_run_at": nextRunAt,
+		"last_error":  truncate(lastError, maxJobErrorLen),
+	}).Error
+}
+
+// FinishJob записывает финальный статус задачи (done/failed/cancelled).
+func (s *Storage) FinishJob(id uint, status JobStatus, lastError string) error {
+	return s.db.Model(&Job{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"status":      status,
+		"last_error":  truncate(lastError, maxJobErrorLen),
+		"finished_at": time.Now(),
+	}).Error
+}
+
+// SetJobStatusMessage обновляет ID статус-сообщения задачи.
+func (s *Storage) SetJobStatusMessage(id uint, messageID int) error {
+	return s.db.Model(&Job{}).Where("id = ?", id).Update("status_message_id", messageID).Error
+}
+
+// JobByStatusMessage ищет последнюю задачу по статус-сообщению (для кнопки «Отмена»).
+func (s *Storage) JobByStatusMessage(chatID int64, messageID int) (*Job, error) {
+	var job Job
+	result := s.db.Where("chat_id = ? AND status_message_id = ?", chatID, messageID).Order("id DESC").First(&job)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, ErrJobNotFound
+		}
+		return nil, result.Error
+	}
+	return &job, nil
+}
+
+// CancelPendingJob отменяет задачу, если её ещё не взял воркер.
+// Возвращает false, если задача уже выполняется или завершена.
+func (s *Storage) CancelPendingJob(id uint) (bool, error) {
+	result := s.db.Model(&Job{}).Where("id = ? AND status = ?", id, JobPending).Updates(map[string]interface{}{
+		"status":      JobCancelled,
+		"finished_at": time.Now(),
+	})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// RequeueExpiredJobs подбирает задачи, чья аренда истекла: инстанс, который их выполнял,
+// упал или был убит. Прерванная попытка засчитывается — задача, которая роняет процесс,
+// не должна крутиться вечно: исчерпавшие попытки помечаются failed, остальные
+// возвращаются в очередь. Возвращает их с новым статусом — чтобы предупредить пользователей.
+// Задачи живых инстансов (аренда продлевается) не трогаются.
+func (s *Storage) RequeueExpiredJobs() ([]Job, error) {
+	var jobs []Job
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: clause.LockingOptionsSkipLocked}).
+			Where("status = ? AND (lease_until IS NULL OR lease_until < ?)", JobRunning, now).
+			Order("id").
+			Find(&jobs).Error
+		if err != nil {
+			return err
+		}
+
+		var requeue, fail []uint
+		for i := range jobs {
+			if jobs[i].Attempts >= jobs[i].MaxAttempts {
+				jobs[i].Status = JobFailed
+				jobs[i].LastError = errLeaseExpired
+				jobs[i].FinishedAt = &now
+				fail = append(fail, jobs[i].ID)
+			} else {
+				jobs[i].Status = JobPending
+				jobs[i].NextRunAt = now
+				requeue = append(requeue, jobs[i].ID)
+			}
+		}
+		if len(requeue) > 0 {
+			err := tx.Model(&Job{}).Where("id IN ?", requeue).Updates(map[string]interface{}{
+				"status":      JobPending,
+				"next_run_at": now,
+				"last_error":  errLeaseExpired,
+			}).Error
+			if err != nil {
+				return err
+			}
+		}
+		if len(fail) > 0 {
+			return tx.Model(&Job{}).Where("id IN ?", fail).Updates(map[string]interface{}{
+				"status":      JobFailed,
+				"last_error":  errLeaseExpired,
+				"finished_at": now,
+			}).Error
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return jobs, nil
+}
+
+func truncate(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n])
+}
@@ -0,0 +1,248 @@
+package storage
+
+import (
+	"container/list"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// LRU — Store с ограниченным кэшем записей media_cache в памяти процесса перед базой.
+// Популярные видео ищутся сотни раз в час; из LRU они отдаются без похода в PostgreSQL.
+// Кэшируются Lookup (по source_key) и LookupBySHA256 (по хэшу), промахи не кэшируются.
+//
+// Записи сбрасываются при Save, Upsert, Invalidate и массовом удалении (TTL, вытеснение).
+// Изменения, сделанные другими инстансами (в том числе их hit_count), видны не позже
+// чем через ttl; протухший file_id Telegram отвергнет, и бот сам вызовет Invalidate.
+type LRU struct {
+	Store
+
+	maxEntries int
+	ttl        time.Duration
+
+	mu      sync.Mutex
+	order   *list.List               // спереди — самые недавно использованные
+	byKey   map[string]*list.Element // source_key → элемент order
+	byHash  map[string]string        // sha256 → source_key самой ранней записи с этим хэшем
+	gen     uint64                   // растёт при каждой записи: чтение, начатое до неё, не кэшируется
+	hits    atomic.Int64
+	misses  atomic.Int64
+	evicted atomic.Int64
+}
+
+// lruItem — элемент списка LRU.
+type lruItem struct {
+	entry     MediaCache
+	expiresAt time.Time
+}
+
+// LRUStats — статистика LRU с запуска.
+type LRUStats struct {
+	Hits      int64
+	Misses    int64
+	Evictions int64 // вытеснено сверх maxEntries
+	Entries   int
+}
+
+var _ Store = (*LRU)(nil)
+
+// NewLRU оборачивает store кэшем на maxEntries записей, каждая живёт не дольше ttl.
+func NewLRU(store Store, maxEntries int, ttl time.Duration) *LRU {
+	return &LRU{
+		Store:      store,
+		maxEntries: maxEntries,
+		ttl:        ttl,
+		order:      list.New(),
+		byKey:      make(map[string]*list.Element),
+		byHash:     make(map[string]string),
+	}
+}
+
+// Stats возвращает статистику попаданий.
+func (c *LRU) Stats() LRUStats {
+	c.mu.Lock()
+	entries := c.order.Len()
+	c.mu.Unlock()
+	return LRUStats{
+		Hits:      c.hits.Load(),
+		Misses:    c.misses.Load(),
+		Evictions: c.evicted.Load(),
+		Entries:   entries,
+	}
+}
+
+// Lookup отдаёт запись из памяти, а при промахе — из базы, запоминая её.
+func (c *LRU) Lookup(sourceKey string) (*MediaCache, error) {
+	entry, gen, ok := c.get(sourceKey)
+	if ok {
+		c.hits.Add(1)
+		return entry, nil
+	}
+	c.misses.Add(1)
+	entry, err := c.Store.Lookup(sourceKey)
+	if err != nil {
+		return nil, err
+	}
+	c.put(entry, false, gen)
+	return entry, nil
+}
+
+// LookupBySHA256 отдаёт запись по хэшу из памяти, а при промахе — из базы.
+func (c *LRU) LookupBySHA256(hash string) (*MediaCache, error) {
+	c.mu.Lock()
+	sourceKey, ok := c.byHash[hash]
+	gen := c.gen
+	c.mu.Unlock()
+	if ok {
+		if entry, _, ok := c.get(sourceKey); ok && entry.SHA256 == hash {
+			c.hits.Add(1)
+			return entry, nil
+		}
+	}
+	c.misses.Add(1)
+	entry, err := c.Store.LookupBySHA256(hash)
+	if err != nil {
+		return nil, err
+	}
+	c.put(entry, true, gen)
+	return entry, nil
+}
+
+// Save сохраняет запись в базу и сбрасывает её из памяти.
+func (c *LRU) Save(entry *MediaCache) error {
+	defer c.forget(entry.SourceKey, entry.SHA256)
+	return c.Store.Save(entry)
+}
+
+// Upsert сохраняет запись в базу и сбрасывает из памяти старую версию.
+// Ожившая запись могла стать самой ранней для своего хэша — сбрасывается и он.
+func (c *LRU) Upsert(entry *MediaCache) error {
+	defer c.forget(entry.SourceKey, entry.SHA256)
+	return c.Store.Upsert(entry)
+}
+
+// Invalidate удаляет запись в базе и в памяти.
+func (c *LRU) Invalidate(sourceKey string) error {
+	defer c.forget(sourceKey, "")
+	return c.Store.Invalidate(sourceKey)
+}
+
+// RecordHits пишет счётчики в базу и прибавляет их к записям в памяти.
+func (c *LRU) RecordHits(hits []CacheHits) error {
+	if err := c.Store.RecordHits(hits); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, hit := range hits {
+		elem, ok := c.byKey[hit.SourceKey]
+		if !ok {
+			continue
+		}
+		item := elem.Value.(*lruItem)
+		item.entry.HitCount += hit.Count
+		if hit.LastUsedAt.After(item.entry.LastUsedAt) {
+			item.entry.LastUsedAt = hit.LastUsedAt
+		}
+	}
+	return nil
+}
+
+// ExpireCache удаляет записи в базе; если что-то удалено — память очищается целиком.
+func (c *LRU) ExpireCache(unusedSince time.Time) (int64, error) {
+	n, err := c.Store.ExpireCache(unusedSince)
+	if n > 0 {
+		c.reset()
+	}
+	return n, err
+}
+
+// EvictCache вытесняет записи в базе; если что-то вытеснено — память очищается целиком.
+func (c *LRU) EvictCache(maxEntries int, policy EvictionPolicy) (int64, error) {
+	n, err := c.Store.EvictCache(maxEntries, policy)
+	if n > 0 {
+		c.reset()
+	}
+	return n, err
+}
+
+// get возвращает копию живой записи и поднимает её в начало списка.
+// При промахе gen — поколение, которое нужно передать в put после чтения из базы.
+func (c *LRU) get(sourceKey string) (entry *MediaCache, gen uint64, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.byKey[sourceKey]
+	if !ok {
+		return nil, c.gen, false
+	}
+	item := elem.Value.(*lruItem)
+	if !time.Now().Before(item.expiresAt) {
+		c.remove(elem)
+		return nil, c.gen, false
+	}
+	c.order.MoveToFront(elem)
+	found := item.entry
+	return &found, c.gen, true
+}
+
+// put запоминает копию записи, прочитанной из базы в поколении gen; byHash — найдена по хэшу.
+// Если с тех пор что-то записали, запись могла устареть — её не запоминаем.
+func (c *LRU) put(entry *MediaCache, byHash bool, gen uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if gen != c.gen {
+		return
+	}
+
+	item := &lruItem{entry: *entry, expiresAt: time.Now().Add(c.ttl)}
+	if elem, ok := c.byKey[entry.SourceKey]; ok {
+		elem.Value = item
+		c.order.MoveToFront(elem)
+	} else {
+		c.byKey[entry.SourceKey] = c.order.PushFront(item)
+	}
+	if byHash && entry.SHA256 != "" {
+		c.byHash[entry.SHA256] = entry.SourceKey
+	}
+
+	for c.order.Len() > c.maxEntries {
+		c.remove(c.order.Back())
+		c.evicted.Add(1)
+	}
+}
+
+// forget сбрасывает запись sourceKey и хэш hash (если задан) после записи в базу.
+func (c *LRU) forget(sourceKey, hash string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.gen++
+	if elem, ok := c.byKey[sourceKey]; ok {
+		c.remove(elem)
+	}
+	if hash != "" {
+		delete(c.byHash, hash)
+	}
+}
+
+// remove убирает элемент из списка и индексов. Вызывается под mu.
+func (c *LRU) remove(elem *list.Element) {
+	item := c.order.Remove(elem).(*lruItem)
+	delete(c.byKey, item.entry.SourceKey)
+	if c.byHash[item.entry.SHA256] == item.entry.SourceKey {
+		delete(c.byHash, item.entry.SHA256)
+	}
+}
+
+func (c *LRU) reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.gen++
+	c.order.Init()
+	clear(c.byKey)
+	clear(c.byHash)
+}
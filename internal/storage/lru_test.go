@@ -0,0 +1,101 @@
+package storage
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// countingStore считает обращения к базе за записями кэша.
+type countingStore struct {
+	Store
+	lookups int
+}
+
+func (s *countingStore) Lookup(sourceKey string) (*MediaCache, error) {
+	s.lookups++
+	return s.Store.Lookup(sourceKey)
+}
+
+func (s *countingStore) LookupBySHA256(hash string) (*MediaCache, error) {
+	s.lookups++
+	return s.Store.LookupBySHA256(hash)
+}
+
+func TestLRU(t *testing.T) {
+	db := &countingStore{Store: NewMemory(zap.NewNop())}
+	c := NewLRU(db, 2, time.Minute)
+	for _, key := range []string{"tiktok:1", "tiktok:2", "tiktok:3"} {
+		if err := c.Upsert(&MediaCache{SourceKey: key, SHA256: "h-" + key, TgFileID: "old-" + key, TgFileUniqueID: key}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Первый раз — из базы, дальше из памяти; по хэшу — тоже
+	for range 3 {
+		if got, err := c.Lookup("tiktok:1"); err != nil || got.TgFileID != "old-tiktok:1" {
+			t.Fatalf("Lookup = %+v, %v", got, err)
+		}
+	}
+	for range 2 {
+		if got, err := c.LookupBySHA256("h-tiktok:1"); err != nil || got.SourceKey != "tiktok:1" {
+			t.Fatalf("LookupBySHA256 = %+v, %v", got, err)
+		}
+	}
+	if db.lookups != 2 {
+		t.Errorf("database lookups = %d, want 2", db.lookups)
+	}
+
+	// Upsert сбрасывает старую версию
+	if err := c.Upsert(&MediaCache{SourceKey: "tiktok:1", SHA256: "h-tiktok:1", TgFileID: "new", TgFileUniqueID: "u"}); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := c.Lookup("tiktok:1"); got.TgFileID != "new" {
+		t.Errorf("Lookup after Upsert = %q, want new", got.TgFileID)
+	}
+
+	// Invalidate сбрасывает и запись, и хэш
+	if err := c.Invalidate("tiktok:1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Lookup("tiktok:1"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Lookup after Invalidate error = %v, want ErrNotFound", err)
+	}
+	if _, err := c.LookupBySHA256("h-tiktok:1"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("LookupBySHA256 after Invalidate error = %v, want ErrNotFound", err)
+	}
+
+	// Больше maxEntries не держим: tiktok:4 вытесняет самую давнюю запись — tiktok:2
+	c.Lookup("tiktok:2")
+	c.Lookup("tiktok:3")
+	if err := c.Upsert(&MediaCache{SourceKey: "tiktok:4", TgFileID: "f4", TgFileUniqueID: "u4"}); err != nil {
+		t.Fatal(err)
+	}
+	c.Lookup("tiktok:4")
+	before := db.lookups
+	c.Lookup("tiktok:2")
+	if db.lookups != before+1 {
+		t.Error("evicted tiktok:2 was served from memory")
+	}
+
+	stats := c.Stats()
+	if stats.Entries != 2 || stats.Evictions == 0 || stats.Hits != 3 || stats.Misses == 0 {
+		t.Errorf("Stats = %+v", stats)
+	}
+}
+
+func TestLRUExpires(t *testing.T) {
+	db := &countingStore{Store: NewMemory(zap.NewNop())}
+	c := NewLRU(db, 10, time.Millisecond)
+	if err := c.Upsert(&MediaCache{SourceKey: "tiktok:1", TgFileID: "f", TgFileUniqueID: "u"}); err != nil {
+		t.Fatal(err)
+	}
+	c.Lookup("tiktok:1")
+	time.Sleep(2 * time.Millisecond)
+	c.Lookup("tiktok:1")
+	if db.lookups != 2 {
+		t.Errorf("database lookups = %d, want 2: expired entry must be reloaded", db.lookups)
+	}
+}
@@ -0,0 +1,132 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// EvictionPolicy — какие записи кэша вытеснять первыми, когда их больше лимита.
+type EvictionPolicy string
+
+const (
+	EvictLRU EvictionPolicy = "lru" // давно не отправлявшиеся (last_used_at)
+	EvictLFU EvictionPolicy = "lfu" // реже всего отправлявшиеся (hit_count, затем last_used_at)
+)
+
+// MaintenanceConfig — пороги обслуживания кэша. Нулевое значение отключает шаг.
+type MaintenanceConfig struct {
+	TTL        time.Duration  // мягко удалять записи, не использованные дольше TTL
+	MaxEntries int            // оставлять не больше стольких живых записей
+	Eviction   EvictionPolicy // кого вытеснять сверх MaxEntries
+	PurgeAfter time.Duration  // окончательно удалять записи, мягко удалённые раньше чем PurgeAfter назад
+
+	RequestRetention time.Duration // сколько хранить историю запросов
+}
+
+// MaintenanceReport — что сделал один проход обслуживания.
+type MaintenanceReport struct {
+	Expired  int64
+	Evicted  int64
+	Purged   int64
+	Failures int64 // удалено истёкших записей негативного кэша
+	Requests int64 // удалено записей истории старше RequestRetention
+}
+
+// Maintain делает один проход: TTL, лимит записей, затем окончательное удаление.
+// Мягко удалённые записи не отдаются Lookup, но до purge их ещё видно в базе.
+// Истёкшие записи негативного кэша удаляются всегда.
+func Maintain(s Store, cfg MaintenanceConfig, now time.Time) (MaintenanceReport, error) {
+	var report MaintenanceReport
+	var err error
+
+	if cfg.TTL > 0 {
+		if report.Expired, err = s.ExpireCache(now.Add(-cfg.TTL)); err != nil {
+			return report, err
+		}
+	}
+	if cfg.MaxEntries > 0 {
+		if report.Evicted, err = s.EvictCache(cfg.MaxEntries, cfg.Eviction); err != nil {
+			return report, err
+		}
+	}
+	if cfg.PurgeAfter > 0 {
+		if report.Purged, err = s.PurgeCache(now.Add(-cfg.PurgeAfter)); err != nil {
+			return report, err
+		}
+	}
+	if report.Failures, err = s.PurgeFailures(now); err != nil {
+		return report, err
+	}
+	if cfg.RequestRetention > 0 {
+		if report.Requests, err = s.PurgeRequests(now.Add(-cfg.RequestRetention)); err != nil {
+			return report, err
+		}
+	}
+	return report, nil
+}
+
+// RunMaintenance обслуживает кэш сразу и затем каждые interval. Блокирует до отмены ctx.
+func RunMaintenance(ctx context.Context, s Store, interval time.Duration, cfg MaintenanceConfig, log *zap.Logger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		report, err := Maintain(s, cfg, time.Now())
+		if err != nil {
+			log.Error("cache maintenance failed", zap.Error(err))
+		} else {
+			log.Info("cache maintenance finished",
+				zap.Int64("expired", report.Expired),
+				zap.Int64("evicted", report.Evicted),
+				zap.String("eviction", string(cfg.Eviction)),
+				zap.Int64("purged", report.Purged),
+				zap.Int64("failures_purged", report.Failures),
+				zap.Int64("requests_purged", report.Requests),
+			)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// evictionOrder — ORDER BY для выбора вытесняемых записей: первые — кандидаты.
+func evictionOrder(policy EvictionPolicy) string {
+	if policy == EvictLFU {
+		return "hit_count, last_used_at, id"
+	}
+	return "last_used_at, id"
+}
+
+// ExpireCache мягко удаляет записи, которые не использовались с unusedSince.
+func (s *Storage) ExpireCache(unusedSince time.Time) (int64, error) {
+	result := s.db.Where("last_used_at < ?", unusedSince).Delete(&MediaCache{})
+	return result.RowsAffected, result.Error
+}
+
+// EvictCache мягко удаляет живые записи сверх maxEntries по политике policy.
+func (s *Storage) EvictCache(maxEntries int, policy EvictionPolicy) (int64, error) {
+	var live int64
+	if err := s.db.Model(&MediaCache{}).Count(&live).Error; err != nil {
+		return 0, err
+	}
+	excess := live - int64(maxEntries)
+	if excess <= 0 {
+		return 0, nil
+	}
+
+	victims := s.db.Model(&MediaCache{}).Select("id").Order(evictionOrder(policy)).Limit(int(excess))
+	result := s.db.Where("id IN (?)", victims).Delete(&MediaCache{})
+	return result.RowsAffected, result.Error
+}
+
+// PurgeCache окончательно удаляет записи, мягко удалённые до deletedBefore.
+func (s *Storage) PurgeCache(deletedBefore time.Time) (int64, error) {
+	result := s.db.Unscoped().Where("deleted_at IS NOT NULL AND deleted_at < ?", deletedBefore).Delete(&MediaCache{})
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,637 @@
+package storage
+
+import (
+	"cmp"
+	"slices"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// Memory — Store в памяти процесса (DATABASE_URL=memory://).
+// Повторяет поведение Storage, но ничего не переживает рестарт и не делится
+// между инстансами — для локального запуска без PostgreSQL и для тестов.
+type Memory struct {
+	mu sync.Mutex
+
+	cache     map[string]*MediaCache // по source_key
+	subtitles map[subtitleKey]*Subtitle
+	failures  map[string]*Failure // по source_key
+	requests  []Request
+	users     map[int64]*User
+	chats     map[int64]*Chat
+	jobs      map[uint]*Job
+
+	nextCacheID    uint
+	nextSubtitleID uint
+	nextFailureID  uint
+	nextRequestID  uint
+	nextJobID      uint
+}
+
+type subtitleKey struct {
+	sourceKey, lang string
+}
+
+// NewMemory возвращает пустое хранилище в памяти.
+func NewMemory(log *zap.Logger) *Memory {
+	log.Info("storage initialized (in-memory, data is lost on restart)")
+	return &Memory{
+		cache:     make(map[string]*MediaCache),
+		subtitles: make(map[subtitleKey]*Subtitle),
+		failures:  make(map[string]*Failure),
+		users:     make(map[int64]*User),
+		chats:     make(map[int64]*Chat),
+		jobs:      make(map[uint]*Job),
+	}
+}
+
+// Close ничего не делает: закрывать нечего.
+func (m *Memory) Close() error {
+	return nil
+}
+
+// --- Операции с кэшем ---
+
+// Lookup ищет запись по source_key. Только читает, как и Storage.
+func (m *Memory) Lookup(sourceKey string) (*MediaCache, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry, ok := m.cache[sourceKey]
+	if !ok || entry.DeletedAt.Valid {
+		return nil, ErrNotFound
+	}
+	found := *entry
+	return &found, nil
+}
+
+// LookupBySHA256 ищет запись по хэшу файла; при нескольких — самую раннюю.
+func (m *Memory) LookupBySHA256(hash string) (*MediaCache, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var found *MediaCache
+	for _, entry := range m.cache {
+		if entry.SHA256 == hash && !entry.DeletedAt.Valid && (found == nil || entry.ID < found.ID) {
+			found = entry
+		}
+	}
+	if found == nil {
+		return nil, ErrNotFound
+	}
+	entry := *found
+	return &entry, nil
+}
+
+// Save сохраняет новую запись в кэш.
+func (m *Memory) Save(entry *MediaCache) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.insertCache(entry)
+}
+
+func (m *Memory) insertCache(entry *MediaCache) error {
+	if _, ok := m.cache[entry.SourceKey]; ok {
+		return ErrDuplicateKey
+	}
+	m.nextCacheID++
+	entry.ID = m.nextCacheID
+	entry.CreatedAt = time.Now()
+	entry.LastUsedAt = entry.CreatedAt
+	saved := *entry
+	m.cache[entry.SourceKey] = &saved
+	return nil
+}
+
+// Upsert — вставка или обновление по source_key.
+// Запись, удалённая через Invalidate, оживает с новым file_id.
+func (m *Memory) Upsert(entry *MediaCache) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	existing, ok := m.cache[entry.SourceKey]
+	if !ok {
+		return m.insertCache(entry)
+	}
+	existing.SHA256 = entry.SHA256
+	existing.TgFileID = entry.TgFileID
+	existing.TgFileUniqueID = entry.TgFileUniqueID
+	existing.SizeBytes = entry.SizeBytes
+	existing.LastUsedAt = time.Now()
+	existing.DeletedAt = gorm.DeletedAt{}
+	entry.ID = existing.ID
+	return nil
+}
+
+// RecordHits прибавляет накопленные отправки к hit_count и сдвигает last_used_at.
+func (m *Memory) RecordHits(hits []CacheHits) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, hit := range hits {
+		entry, ok := m.cache[hit.SourceKey]
+		if !ok || entry.DeletedAt.Valid {
+			continue
+		}
+		entry.HitCount += hit.Count
+		if hit.LastUsedAt.After(entry.LastUsedAt) {
+			entry.LastUsedAt = hit.LastUsedAt
+		}
+	}
+	return nil
+}
+
+// Invalidate мягко удаляет запись, чей file_id Telegram больше не принимает.
+func (m *Memory) Invalidate(sourceKey string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if entry, ok := m.cache[sourceKey]; ok && !entry.DeletedAt.Valid {
+		entry.DeletedAt = gorm.DeletedAt{Time: time.Now(), Valid: true}
+	}
+	return nil
+}
+
+// ExpireCache мягко удаляет записи, которые не использовались с unusedSince.
+func (m *Memory) ExpireCache(unusedSince time.Time) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var expired int64
+	now := time.Now()
+	for _, entry := range m.cache {
+		if !entry.DeletedAt.Valid && entry.LastUsedAt.Before(unusedSince) {
+			entry.DeletedAt = gorm.DeletedAt{Time: now, Valid: true}
+			expired++
+		}
+	}
+	return expired, nil
+}
+
+// EvictCache мягко удаляет живые записи сверх maxEntries по политике policy.
+func (m *Memory) EvictCache(maxEntries int, policy EvictionPolicy) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var live []*MediaCache
+	for _, entry := range m.cache {
+		if !entry.DeletedAt.Valid {
+			live = append(live, entry)
+		}
+	}
+	excess := len(live) - maxEntries
+	if excess <= 0 {
+		return 0, nil
+	}
+
+	// Тот же порядок, что evictionOrder у Storage
+	slices.SortFunc(live, func(a, b *MediaCache) int {
+		if policy == EvictLFU {
+			if c := cmp.Compare(a.HitCount, b.HitCount); c != 0 {
+				return c
+			}
+		}
+		if c := a.LastUsedAt.Compare(b.LastUsedAt); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.ID, b.ID)
+	})
+	now := time.Now()
+	for _, entry := range live[:excess] {
+		entry.DeletedAt = gorm.DeletedAt{Time: now, Valid: true}
+	}
+	return int64(excess), nil
+}
+
+// PurgeCache окончательно удаляет записи, мягко удалённые до deletedBefore.
+func (m *Memory) PurgeCache(deletedBefore time.Time) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var purged int64
+	for key, entry := range m.cache {
+		if entry.DeletedAt.Valid && entry.DeletedAt.Time.Before(deletedBefore) {
+			delete(m.cache, key)
+			purged++
+		}
+	}
+	return purged, nil
+}
+
+// --- Субтитры ---
+
+// LookupSubtitle ищет субтитры видео на языке lang.
+func (m *Memory) LookupSubtitle(sourceKey, lang string) (*Subtitle, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry, ok := m.subtitles[subtitleKey{sourceKey, lang}]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	found := *entry
+	return &found, nil
+}
+
+// SaveSubtitle сохраняет file_id субтитров; повторное сохранение того же ключа перезаписывает его.
+func (m *Memory) SaveSubtitle(entry *Subtitle) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := subtitleKey{entry.SourceKey, entry.Lang}
+	if existing, ok := m.subtitles[key]; ok {
+		existing.TrackLang = entry.TrackLang
+		existing.TgFileID = entry.TgFileID
+		existing.TgFileUniqueID = entry.TgFileUniqueID
+		entry.ID = existing.ID
+		return nil
+	}
+	m.nextSubtitleID++
+	entry.ID = m.nextSubtitleID
+	if entry.CreatedAt.IsZero() {
+		entry.CreatedAt = time.Now()
+	}
+	saved := *entry
+	m.subtitles[key] = &saved
+	return nil
+}
+
+// --- Негативный кэш ---
+
+// LookupFailure возвращает ещё не истёкшую запись о неудаче по source_key.
+func (m *Memory) LookupFailure(sourceKey string) (*Failure, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry, ok := m.failures[sourceKey]
+	if !ok || !entry.ExpiresAt.After(time.Now()) {
+		return nil, ErrNotFound
+	}
+	found := *entry
+	return &found, nil
+}
+
+// SaveFailure запоминает неудачу; повторная неудача того же ключа перезаписывает класс и срок.
+func (m *Memory) SaveFailure(entry *Failure) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	if existing, ok := m.failures[entry.SourceKey]; ok {
+		existing.Class = entry.Class
+		existing.Text = entry.Text
+		existing.ExpiresAt = entry.ExpiresAt
+		existing.UpdatedAt = now
+		entry.ID = existing.ID
+		return nil
+	}
+	m.nextFailureID++
+	entry.ID = m.nextFailureID
+	entry.CreatedAt = now
+	entry.UpdatedAt = now
+	saved := *entry
+	m.failures[entry.SourceKey] = &saved
+	return nil
+}
+
+// PurgeFailures удаляет записи, истёкшие до expiredBefore.
+func (m *Memory) PurgeFailures(expiredBefore time.Time) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var purged int64
+	for key, entry := range m.failures {
+		if entry.ExpiresAt.Before(expiredBefore) {
+			delete(m.failures, key)
+			purged++
+		}
+	}
+	return purged, nil
+}
+
+// --- История запросов ---
+
+// SaveRequests добавляет записи истории.
+func (m *Memory) SaveRequests(entries []Request) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i := range entries {
+		m.nextRequestID++
+		entries[i].ID = m.nextRequestID
+		if entries[i].CreatedAt.IsZero() {
+			entries[i].CreatedAt = time.Now()
+		}
+		m.requests = append(m.requests, entries[i])
+	}
+	return nil
+}
+
+// PurgeRequests удаляет записи истории старше before.
+func (m *Memory) PurgeRequests(before time.Time) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	kept := m.requests[:0]
+	for _, entry := range m.requests {
+		if !entry.CreatedAt.Before(before) {
+			kept = append(kept, entry)
+		}
+	}
+	purged := int64(len(m.requests) - len(kept))
+	m.requests = kept
+	return purged, nil
+}
+
+// --- Пользователи и чаты ---
+
+// TouchUser добавляет пользователя или обновляет профиль и last_seen_at; blocked не трогает.
+func (m *Memory) TouchUser(user *User) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	user.LastSeenAt = seenAt(user.LastSeenAt)
+	if existing, ok := m.users[user.ID]; ok {
+		existing.Username = user.Username
+		existing.FirstName = user.FirstName
+		existing.LanguageCode = user.LanguageCode
+		existing.LastSeenAt = user.LastSeenAt
+		user.FirstSeenAt = existing.FirstSeenAt
+		user.Blocked, user.BlockedAt = existing.Blocked, existing.BlockedAt
+		user.Preferences = existing.Preferences
+		return nil
+	}
+	user.Blocked, user.BlockedAt = false, nil
+	user.FirstSeenAt = user.LastSeenAt
+	user.Preferences = Preferences{}
+	saved := *user
+	m.users[user.ID] = &saved
+	return nil
+}
+
+// TouchChat добавляет чат или обновляет тип, название и last_seen_at. Снимает блокировку
+// не новее LastSeenAt с чата, а для личного чата — и с пользователя с тем же ID.
+func (m *Memory) TouchChat(chat *Chat) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	chat.LastSeenAt = seenAt(chat.LastSeenAt)
+	unblock := func(blocked *bool, blockedAt **time.Time) {
+		if *blocked && !(*blockedAt).After(chat.LastSeenAt) {
+			*blocked, *blockedAt = false, nil
+		}
+	}
+	if chat.Type == ChatPrivate {
+		if user, ok := m.users[chat.ID]; ok {
+			unblock(&user.Blocked, &user.BlockedAt)
+		}
+	}
+	if existing, ok := m.chats[chat.ID]; ok {
+		existing.Type = chat.Type
+		existing.Title = chat.Title
+		unblock(&existing.Blocked, &existing.BlockedAt)
+		existing.LastSeenAt = chat.LastSeenAt
+		chat.FirstSeenAt = existing.FirstSeenAt
+		chat.Blocked, chat.BlockedAt = existing.Blocked, existing.BlockedAt
+		chat.Preferences = existing.Preferences
+		return nil
+	}
+	chat.Blocked, chat.BlockedAt = false, nil
+	chat.FirstSeenAt = chat.LastSeenAt
+	chat.Preferences = Preferences{}
+	saved := *chat
+	m.chats[chat.ID] = &saved
+	return nil
+}
+
+// MarkBlocked помечает чат и пользователя с тем же ID заблокированными.
+func (m *Memory) MarkBlocked(chatID int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	if chat, ok := m.chats[chatID]; ok && !chat.Blocked {
+		chat.Blocked, chat.BlockedAt = true, &now
+	}
+	if user, ok := m.users[chatID]; ok && !user.Blocked {
+		user.Blocked, user.BlockedAt = true, &now
+	}
+	return nil
+}
+
+// LookupUser ищет пользователя по Telegram ID.
+func (m *Memory) LookupUser(id int64) (*User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	user, ok := m.users[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	found := *user
+	return &found, nil
+}
+
+// LookupChat ищет чат по Telegram ID.
+func (m *Memory) LookupChat(id int64) (*Chat, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	chat, ok := m.chats[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	found := *chat
+	return &found, nil
+}
+
+// SetUserPreferences заменяет настройки пользователя.
+func (m *Memory) SetUserPreferences(id int64, prefs Preferences) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	user, ok := m.users[id]
+	if !ok {
+		return ErrNotFound
+	}
+	user.Preferences = prefs
+	return nil
+}
+
+// SetChatPreferences заменяет настройки чата.
+func (m *Memory) SetChatPreferences(id int64, prefs Preferences) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	chat, ok := m.chats[id]
+	if !ok {
+		return ErrNotFound
+	}
+	chat.Preferences = prefs
+	return nil
+}
+
+// --- Очередь задач ---
+
+// EnqueueJob ставит задачу в очередь. Задача готова к выполнению сразу.
+func (m *Memory) EnqueueJob(job *Job) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	job.Status = JobPending
+	job.NextRunAt = now
+	if job.MaxAttempts < 1 {
+		job.MaxAttempts = 1
+	}
+	m.nextJobID++
+	job.ID = m.nextJobID
+	job.CreatedAt = now
+	job.UpdatedAt = now
+	saved := *job
+	m.jobs[job.ID] = &saved
+	return nil
+}
+
+// ClaimJob забирает самую раннюю готовую задачу, помечает её running и берёт в аренду на lease.
+func (m *Memory) ClaimJob(lease time.Duration) (*Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	var next *Job
+	for _, job := range m.jobs {
+		if job.Status != JobPending || job.NextRunAt.After(now) {
+			continue
+		}
+		if next == nil || job.NextRunAt.Before(next.NextRunAt) ||
+			(job.NextRunAt.Equal(next.NextRunAt) && job.ID < next.ID) {
+			next = job
+		}
+	}
+	if next == nil {
+		return nil, ErrNoJobs
+	}
+
+	leaseUntil := now.Add(lease)
+	next.Status = JobRunning
+	next.Attempts++
+	next.StartedAt = &now
+	next.LeaseUntil = &leaseUntil
+	next.UpdatedAt = now
+	claimed := *next
+	return &claimed, nil
+}
+
+// RenewJobLease продлевает аренду выполняющейся задачи.
+func (m *Memory) RenewJobLease(id uint, lease time.Duration) error {
+	return m.updateJob(id, func(job *Job) {
+		if job.Status == JobRunning {
+			leaseUntil := time.Now().Add(lease)
+			job.LeaseUntil = &leaseUntil
+		}
+	})
+}
+
+// RetryJob возвращает задачу в очередь; следующая попытка не раньше nextRunAt.
+func (m *Memory) RetryJob(id uint, lastError string, nextRunAt time.Time) error {
+	return m.updateJob(id, func(job *Job) {
+		job.Status = JobPending
+		job.NextRunAt = nextRunAt
+		job.LastError = truncate(lastError, maxJobErrorLen)
+	})
+}
+
+// FinishJob записывает финальный статус задачи (done/failed/cancelled).
+func (m *Memory) FinishJob(id uint, status JobStatus, lastError string) error {
+	return m.updateJob(id, func(job *Job) {
+		now := time.Now()
+		job.Status = status
+		job.LastError = truncate(lastError, maxJobErrorLen)
+		job.FinishedAt = &now
+	})
+}
+
+// SetJobStatusMessage обновляет ID статус-сообщения задачи.
+func (m *Memory) SetJobStatusMessage(id uint, messageID int) error {
+	return m.updateJob(id, func(job *Job) {
+		job.StatusMessageID = messageID
+	})
+}
+
+// updateJob меняет задачу, если она есть; как и UPDATE в PostgreSQL, молча
+// ничего не делает для несуществующего id.
+func (m *Memory) updateJob(id uint, update func(job *Job)) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if job, ok := m.jobs[id]; ok {
+		update(job)
+		job.UpdatedAt = time.Now()
+	}
+	return nil
+}
+
+// JobByStatusMessage ищет последнюю задачу по статус-сообщению (для кнопки «Отмена»).
+func (m *Memory) JobByStatusMessage(chatID int64, messageID int) (*Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var found *Job
+	for _, job := range m.jobs {
+		if job.ChatID == chatID && job.StatusMessageID == messageID && (found == nil || job.ID > found.ID) {
+			found = job
+		}
+	}
+	if found == nil {
+		return nil, ErrJobNotFound
+	}
+	job := *found
+	return &job, nil
+}
+
+// CancelPendingJob отменяет задачу, если её ещё не взял воркер.
+func (m *Memory) CancelPendingJob(id uint) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	job, ok := m.jobs[id]
+	if !ok || job.Status != JobPending {
+		return false, nil
+	}
+	now := time.Now()
+	job.Status = JobCancelled
+	job.FinishedAt = &now
+	job.UpdatedAt = now
+	return true, nil
+}
+
+// RequeueExpiredJobs подбирает задачи с истёкшей арендой, как и Storage:
+// исчерпавшие попытки — в failed, остальные — обратно в очередь.
+func (m *Memory) RequeueExpiredJobs() ([]Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var jobs []Job
+	now := time.Now()
+	for _, job := range m.jobs {
+		if job.Status != JobRunning || (job.LeaseUntil != nil && !job.LeaseUntil.Before(now)) {
+			continue
+		}
+		job.LastError = errLeaseExpired
+		job.UpdatedAt = now
+		if job.Attempts >= job.MaxAttempts {
+			job.Status = JobFailed
+			job.FinishedAt = &now
+		} else {
+			job.Status = JobPending
+			job.NextRunAt = now
+		}
+		jobs = append(jobs, *job)
+	}
+	slices.SortFunc(jobs, func(a, b Job) int { return cmp.Compare(a.ID, b.ID) })
+	return jobs, nil
+}
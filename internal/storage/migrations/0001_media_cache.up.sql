@@ -0,0 +1,18 @@
+-- Кэш file_id. IF NOT EXISTS — у инстансов, живших на AutoMigrate, таблица уже есть
+-- с теми же колонками и индексами.
+CREATE TABLE IF NOT EXISTS media_cache (
+    id                bigserial PRIMARY KEY,
+    source_key        varchar(512) NOT NULL,
+    sha256            varchar(64),
+    tg_file_id        varchar(512) NOT NULL,
+    tg_file_unique_id varchar(256) NOT NULL,
+    size_bytes        bigint NOT NULL,
+    hit_count         bigint NOT NULL DEFAULT 0,
+    created_at        timestamptz,
+    last_used_at      timestamptz,
+    deleted_at        timestamptz
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_media_cache_source_key ON media_cache (source_key);
+CREATE INDEX IF NOT EXISTS idx_media_cache_sha256 ON media_cache (sha256);
+CREATE INDEX IF NOT EXISTS idx_media_cache_deleted_at ON media_cache (deleted_at);
@@ -0,0 +1,23 @@
+-- Очередь задач на скачивание.
+CREATE TABLE IF NOT EXISTS jobs (
+    id                  bigserial PRIMARY KEY,
+    status              varchar(16) NOT NULL,
+    next_run_at         timestamptz NOT NULL,
+    chat_id             bigint NOT NULL,
+    user_id             bigint NOT NULL DEFAULT 0,
+    reply_to_message_id bigint NOT NULL DEFAULT 0,
+    status_message_id   bigint NOT NULL DEFAULT 0,
+    url                 varchar(2048) NOT NULL,
+    source_key          varchar(512) NOT NULL,
+    attempts            bigint NOT NULL DEFAULT 0,
+    max_attempts        bigint NOT NULL DEFAULT 1,
+    last_error          varchar(1024),
+    started_at          timestamptz,
+    finished_at         timestamptz,
+    created_at          timestamptz,
+    updated_at          timestamptz
+);
+
+CREATE INDEX IF NOT EXISTS idx_jobs_claim ON jobs (status, next_run_at);
+CREATE INDEX IF NOT EXISTS idx_jobs_status_message_id ON jobs (status_message_id);
+CREATE INDEX IF NOT EXISTS idx_jobs_source_key ON jobs (source_key);
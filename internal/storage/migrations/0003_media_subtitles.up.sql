@@ -0,0 +1,12 @@
+-- file_id .srt-документов с субтитрами.
+CREATE TABLE IF NOT EXISTS media_subtitles (
+    id                bigserial PRIMARY KEY,
+    source_key        varchar(512) NOT NULL,
+    lang              varchar(16) NOT NULL,
+    track_lang        varchar(32),
+    tg_file_id        varchar(512) NOT NULL,
+    tg_file_unique_id varchar(256) NOT NULL,
+    created_at        timestamptz
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_subtitles_key_lang ON media_subtitles (source_key, lang);
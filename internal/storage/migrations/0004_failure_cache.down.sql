@@ -0,0 +1 @@
+DROP TABLE IF EXISTS failure_cache;
@@ -0,0 +1,13 @@
+-- Негативный кэш: ссылки, которые недавно окончательно не скачались.
+CREATE TABLE IF NOT EXISTS failure_cache (
+    id         bigserial PRIMARY KEY,
+    source_key varchar(512) NOT NULL,
+    class      varchar(32) NOT NULL,
+    text       varchar(1024) NOT NULL,
+    expires_at timestamptz NOT NULL,
+    created_at timestamptz,
+    updated_at timestamptz
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_failure_cache_source_key ON failure_cache (source_key);
+CREATE INDEX IF NOT EXISTS idx_failure_cache_expires_at ON failure_cache (expires_at);
@@ -0,0 +1,24 @@
+-- История запросов: кто что просил и чем это кончилось.
+CREATE TABLE IF NOT EXISTS requests (
+    id          bigserial PRIMARY KEY,
+    user_id     bigint NOT NULL DEFAULT 0,
+    chat_id     bigint NOT NULL,
+    chat_type   varchar(16) NOT NULL DEFAULT '',
+    source_key  varchar(512) NOT NULL,
+    outcome     varchar(16) NOT NULL,
+    error_class varchar(32),
+    size_bytes  bigint NOT NULL DEFAULT 0,
+    attempts    bigint NOT NULL DEFAULT 0,
+    total_ms    bigint NOT NULL DEFAULT 0,
+    queue_ms    bigint NOT NULL DEFAULT 0,
+    probe_ms    bigint NOT NULL DEFAULT 0,
+    download_ms bigint NOT NULL DEFAULT 0,
+    process_ms  bigint NOT NULL DEFAULT 0,
+    upload_ms   bigint NOT NULL DEFAULT 0,
+    created_at  timestamptz NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_requests_user_id ON requests (user_id);
+CREATE INDEX IF NOT EXISTS idx_requests_chat_id ON requests (chat_id);
+CREATE INDEX IF NOT EXISTS idx_requests_source_key ON requests (source_key);
+CREATE INDEX IF NOT EXISTS idx_requests_created_at ON requests (created_at);
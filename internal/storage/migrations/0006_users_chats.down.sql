@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS chats;
+DROP TABLE IF EXISTS users;
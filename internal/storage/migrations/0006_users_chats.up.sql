@@ -0,0 +1,23 @@
+-- Пользователи и чаты, которые писали боту, и их настройки.
+CREATE TABLE IF NOT EXISTS users (
+    id            bigint PRIMARY KEY,
+    username      varchar(64),
+    first_name    varchar(256),
+    language_code varchar(16),
+    blocked       boolean NOT NULL DEFAULT false,
+    blocked_at    timestamptz,
+    preferences   jsonb NOT NULL DEFAULT '{}',
+    first_seen_at timestamptz NOT NULL,
+    last_seen_at  timestamptz NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS chats (
+    id            bigint PRIMARY KEY,
+    type          varchar(16) NOT NULL,
+    title         varchar(256),
+    blocked       boolean NOT NULL DEFAULT false,
+    blocked_at    timestamptz,
+    preferences   jsonb NOT NULL DEFAULT '{}',
+    first_seen_at timestamptz NOT NULL,
+    last_seen_at  timestamptz NOT NULL
+);
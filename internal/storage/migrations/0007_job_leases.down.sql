@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_jobs_lease;
+ALTER TABLE jobs DROP COLUMN IF EXISTS lease_until;
@@ -0,0 +1,5 @@
+-- Аренда задачи: инстанс, который её выполняет, продлевает lease_until.
+-- Задачу с истёкшей арендой (инстанс упал) забирает любой другой.
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS lease_until timestamptz;
+
+CREATE INDEX IF NOT EXISTS idx_jobs_lease ON jobs (status, lease_until);
@@ -0,0 +1,3 @@
+-- Тип чата нужен истории и для запросов, которые доходят до очереди.
+-- IF NOT EXISTS: в базах, где 0005 ещё добавляла колонку, она уже есть.
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS chat_type varchar(16) NOT NULL DEFAULT '';
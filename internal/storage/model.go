@@ -30,3 +30,152 @@ func (MediaCache) TableName() string {
 func SourceKeyFromParsed(linkType, videoID string) string {
 	return linkType + ":" + videoID
 }
+
+// Subtitle — субтитры к видео из media_cache: file_id .srt-документа в Telegram.
+// Ключ — source_key видео и язык, который запросил пользователь.
+type Subtitle struct {
+	ID             uint   `gorm:"primaryKey"`
+	SourceKey      string `gorm:"uniqueIndex:idx_subtitles_key_lang;size:512;not null"`
+	Lang           string `gorm:"uniqueIndex:idx_subtitles_key_lang;size:16;not null"`
+	TrackLang      string `gorm:"size:32"` // язык найденной дорожки ("eng-US"), может отличаться от Lang
+	TgFileID       string `gorm:"size:512;not null"`
+	TgFileUniqueID string `gorm:"size:256;not null"`
+	CreatedAt      time.Time
+}
+
+// TableName — имя таблицы в БД.
+func (Subtitle) TableName() string {
+	return "media_subtitles"
+}
+
+// Failure — ссылка, которая недавно окончательно не скачалась (удалена, приватная, ...).
+// Пока не истёк ExpiresAt, бот сразу отвечает Text, не занимая слот скачивания.
+type Failure struct {
+	ID        uint      `gorm:"primaryKey"`
+	SourceKey string    `gorm:"uniqueIndex;size:512;not null"`
+	Class     string    `gorm:"size:32;not null"`   // класс ошибки: removed, private, rate_limited, ...
+	Text      string    `gorm:"size:1024;not null"` // что ответили пользователю
+	ExpiresAt time.Time `gorm:"not null;index"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// TableName — имя таблицы в БД.
+func (Failure) TableName() string {
+	return "failure_cache"
+}
+
+// RequestOutcome — чем закончился запрос видео.
+type RequestOutcome string
+
+const (
+	OutcomeCacheHit     RequestOutcome = "cache_hit"     // отправили по file_id из кэша
+	OutcomeDedupHit     RequestOutcome = "dedup_hit"     // скачали, но такой файл уже был по другой ссылке
+	OutcomeDownloaded   RequestOutcome = "downloaded"    // скачали и залили заново
+	OutcomeKnownFailure RequestOutcome = "known_failure" // ответили из негативного кэша
+	OutcomeFailed       RequestOutcome = "failed"        // ошибка, класс — в ErrorClass
+	OutcomeCancelled    RequestOutcome = "cancelled"     // пользователь нажал «Отмена»
+)
+
+// Request — запись истории: кто что просил, чем кончилось и сколько заняло.
+// Длительности — в миллисекундах; этапы, до которых не дошло, остаются нулями.
+type Request struct {
+	ID         uint           `gorm:"primaryKey"`
+	UserID     int64          `gorm:"not null;default:0;index"`
+	ChatID     int64          `gorm:"not null;index"`
+	ChatType   string         `gorm:"size:16;not null;default:''"` // private, group, supergroup
+	SourceKey  string         `gorm:"size:512;not null;index"`
+	Outcome    RequestOutcome `gorm:"size:16;not null"`
+	ErrorClass string         `gorm:"size:32"` // removed, rate_limited, ... или other
+	SizeBytes  int64          `gorm:"not null;default:0"`
+	Attempts   int            `gorm:"not null;default:0"`
+	TotalMs    int64          `gorm:"not null;default:0"` // от сообщения пользователя до ответа
+	QueueMs    int64          `gorm:"not null;default:0"` // ожидание воркера (вместе с паузами между попытками)
+	ProbeMs    int64          `gorm:"not null;default:0"`
+	DownloadMs int64          `gorm:"not null;default:0"`
+	ProcessMs  int64          `gorm:"not null;default:0"` // ffprobe, faststart, чтение и хэш
+	UploadMs   int64          `gorm:"not null;default:0"`
+	CreatedAt  time.Time      `gorm:"not null;index"`
+}
+
+// TableName — имя таблицы в БД.
+func (Request) TableName() string {
+	return "requests"
+}
+
+// User — пользователь Telegram, который писал боту. ID — Telegram ID.
+type User struct {
+	ID           int64       `gorm:"primaryKey;autoIncrement:false"`
+	Username     string      `gorm:"size:64"`
+	FirstName    string      `gorm:"size:256"`
+	LanguageCode string      `gorm:"size:16"`
+	Blocked      bool        `gorm:"not null;default:false"` // заблокировал бота (Telegram ответил 403)
+	BlockedAt    *time.Time  //
+	Preferences  Preferences `gorm:"type:jsonb;not null;default:'{}'"`
+	FirstSeenAt  time.Time   `gorm:"not null"`
+	LastSeenAt   time.Time   `gorm:"not null"`
+}
+
+// TableName — имя таблицы в БД.
+func (User) TableName() string {
+	return "users"
+}
+
+// Chat — чат (личка или группа), где есть бот. ID — Telegram ID чата.
+type Chat struct {
+	ID          int64       `gorm:"primaryKey;autoIncrement:false"`
+	Type        string      `gorm:"size:16;not null"` // private, group, supergroup
+	Title       string      `gorm:"size:256"`
+	Blocked     bool        `gorm:"not null;default:false"` // бота заблокировали или выгнали
+	BlockedAt   *time.Time  //
+	Preferences Preferences `gorm:"type:jsonb;not null;default:'{}'"`
+	FirstSeenAt time.Time   `gorm:"not null"`
+	LastSeenAt  time.Time   `gorm:"not null"`
+}
+
+// TableName — имя таблицы в БД.
+func (Chat) TableName() string {
+	return "chats"
+}
+
+// ChatPrivate — тип личного чата; его ID совпадает с ID пользователя.
+const ChatPrivate = "private"
+
+// JobStatus — состояние задачи на скачивание.
+type JobStatus string
+
+const (
+	JobPending   JobStatus = "pending"   // ждёт свободного воркера (или следующей попытки)
+	JobRunning   JobStatus = "running"   // взята воркером
+	JobDone      JobStatus = "done"      // видео отправлено
+	JobFailed    JobStatus = "failed"    // попытки исчерпаны или ошибка не ретраится
+	JobCancelled JobStatus = "cancelled" // отменена кнопкой «Отмена»
+)
+
+// Job — задача на скачивание видео.
+// Очередь живёт в PostgreSQL, поэтому переживает рестарт бота.
+type Job struct {
+	ID               uint      `gorm:"primaryKey"`
+	Status           JobStatus `gorm:"size:16;not null;index:idx_jobs_claim,priority:1"`
+	NextRunAt        time.Time `gorm:"not null;index:idx_jobs_claim,priority:2"` // раньше этого времени задачу не берём (backoff)
+	ChatID           int64     `gorm:"not null"`
+	ChatType         string    `gorm:"size:16;not null;default:''"`
+	UserID           int64     `gorm:"not null;default:0"`
+	ReplyToMessageID int       `gorm:"not null;default:0"`
+	StatusMessageID  int       `gorm:"not null;default:0;index"` // сообщение «⏳ качаю», которое редактируем/удаляем
+	URL              string    `gorm:"size:2048;not null"`
+	SourceKey        string    `gorm:"size:512;not null;index"`
+	Attempts         int       `gorm:"not null;default:0"`
+	MaxAttempts      int       `gorm:"not null;default:1"`
+	LastError        string    `gorm:"size:1024"`
+	StartedAt        *time.Time
+	LeaseUntil       *time.Time // пока не истекла, задачу выполняет живой инстанс
+	FinishedAt       *time.Time
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+}
+
+// TableName — имя таблицы в БД.
+func (Job) TableName() string {
+	return "jobs"
+}
@@ -0,0 +1,49 @@
+package storage
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+)
+
+// CaptionStyle — какую подпись ставить под видео.
+type CaptionStyle string
+
+const (
+	CaptionCredit CaptionStyle = ""     // «🎬 @XA4yy» (по умолчанию)
+	CaptionNone   CaptionStyle = "none" // без подписи
+)
+
+// Preferences — настройки пользователя (в личке) или чата (в группе).
+// Хранятся JSON-ом, поэтому новые поля не требуют миграций; нулевое значение — поведение по умолчанию.
+type Preferences struct {
+	Caption        CaptionStyle `json:"caption,omitempty"`
+	SendAsDocument bool         `json:"send_as_document,omitempty"` // файлом, без пережатия Telegram
+}
+
+// Value сохраняет настройки в jsonb.
+func (p Preferences) Value() (driver.Value, error) {
+	data, err := json.Marshal(p)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// Scan читает настройки из jsonb; неизвестные поля игнорируются.
+func (p *Preferences) Scan(value any) error {
+	var data []byte
+	switch v := value.(type) {
+	case nil:
+		*p = Preferences{}
+		return nil
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return fmt.Errorf("unsupported preferences type %T", value)
+	}
+	*p = Preferences{}
+	return json.Unmarshal(data, p)
+}
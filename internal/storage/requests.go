@@ -0,0 +1,19 @@
+package storage
+
+import "time"
+
+// --- История запросов ---
+
+// SaveRequests записывает пачку записей истории одним INSERT.
+func (s *Storage) SaveRequests(entries []Request) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	return s.db.Create(&entries).Error
+}
+
+// PurgeRequests удаляет записи истории старше before.
+func (s *Storage) PurgeRequests(before time.Time) (int64, error) {
+	result := s.db.Where("created_at < ?", before).Delete(&Request{})
+	return result.RowsAffected, result.Error
+}
@@ -1,27 +1,50 @@
 package storage
 
 import (
+	"context"
 	"errors"
+	"strings"
 	"time"
 
 	"go.uber.org/zap"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 	gormlogger "gorm.io/gorm/logger"
 )
 
-var ErrNotFound = errors.New("cache entry not found")
+var (
+	ErrNotFound     = errors.New("cache entry not found")
+	ErrDuplicateKey = errors.New("cache entry already exists")
+	ErrNoJobs       = errors.New("no jobs ready")
+	ErrJobNotFound  = errors.New("job not found")
+)
 
-// Storage — обёртка над GORM для работы с кэшем.
+// Storage — Store поверх GORM и PostgreSQL.
 type Storage struct {
 	db  *gorm.DB
 	log *zap.Logger
 }
 
-// New подключается к PostgreSQL, выполняет AutoMigrate и возвращает Storage.
+// New подключается к PostgreSQL, применяет недостающие миграции и возвращает Storage.
 func New(dsn string, log *zap.Logger) (*Storage, error) {
+	s, err := Connect(dsn, log)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.MigrateUp(context.Background()); err != nil {
+		s.Close()
+		return nil, err
+	}
+	log.Info("storage initialized (PostgreSQL)")
+	return s, nil
+}
+
+// Connect подключается к PostgreSQL без миграций — для команды migrate.
+func Connect(dsn string, log *zap.Logger) (*Storage, error) {
 	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{
-		Logger: gormlogger.Default.LogMode(gormlogger.Warn),
+		Logger:         gormlogger.Default.LogMode(gormlogger.Warn),
+		TranslateError: true,
 	})
 	if err != nil {
 		return nil, err
@@ -36,12 +59,6 @@ func New(dsn string, log *zap.Logger) (*Storage, error) {
 	sqlDB.SetMaxIdleConns(5)
 	sqlDB.SetConnMaxLifetime(5 * time.Minute)
 
-	// AutoMigrate — создаёт/обновляет таблицу
-	if err := db.AutoMigrate(&MediaCache{}); err != nil {
-		return nil, err
-	}
-
-	log.Info("storage initialized (PostgreSQL)")
 	return &Storage{db: db, log: log}, nil
 }
 
@@ -56,7 +73,7 @@ func (s *Storage) Close() error {
 
 // --- Операции с кэшем ---
 
-// Lookup ищет запись по source_key. Если найдена — обновляет last_used_at и hit_count.
+// Lookup ищет запись по source_key. Только читает: статистику отправок пишет RecordHits.
 func (s *Storage) Lookup(sourceKey string) (*MediaCache, error) {
 	var entry MediaCache
 	result := s.db.Where("source_key = ?", sourceKey).First(&entry)
@@ -66,13 +83,6 @@ func (s *Storage) Lookup(sourceKey string) (*MediaCache, error) {
 		}
 		return nil, result.Error
 	}
-
-	// Обновляем статистику использования
-	s.db.Model(&entry).Updates(map[string]interface{}{
-		"last_used_at": time.Now(),
-		"hit_count":    gorm.Expr("hit_count + 1"),
-	})
-
 	return &entry, nil
 }
 
@@ -93,26 +103,61 @@ func (s *Storage) LookupBySHA256(hash string) (*MediaCache, error) {
 func (s *Storage) Save(entry *MediaCache) error {
 	entry.CreatedAt = time.Now()
 	entry.LastUsedAt = time.Now()
-	return s.db.Create(entry).Error
+	err := s.db.Create(entry).Error
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return ErrDuplicateKey
+	}
+	return err
 }
 
-// Upsert — вставка или обновление по source_key.
+// Upsert — вставка или обновление по source_key одним INSERT ... ON CONFLICT,
+// поэтому два одновременных скачивания одного видео не конфликтуют.
+// Запись, удалённая через Invalidate, оживает с новым file_id; hit_count и created_at сохраняются.
 func (s *Storage) Upsert(entry *MediaCache) error {
-	var existing MediaCache
-	result := s.db.Where("source_key = ?", entry.SourceKey).First(&existing)
-	if result.Error != nil {
-		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
-			return s.Save(entry)
+	now := time.Now()
+	entry.CreatedAt = now
+	entry.LastUsedAt = now
+	entry.DeletedAt = gorm.DeletedAt{}
+	return s.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "source_key"}},
+		DoUpdates: clause.AssignmentColumns([]string{"sha256", "tg_file_id", "tg_file_unique_id", "size_bytes", "last_used_at", "deleted_at"}),
+	}).Create(entry).Error
+}
+
+// CacheHits — сколько раз запись кэша реально отправили с прошлого сброса счётчиков.
+type CacheHits struct {
+	SourceKey  string
+	Count      int64
+	LastUsedAt time.Time // время последней из этих отправок
+}
+
+// hitsChunk — сколько записей обновляем одним UPDATE (по 3 параметра на запись).
+const hitsChunk = 1000
+
+// RecordHits прибавляет накопленные отправки к hit_count и сдвигает last_used_at.
+// Удалённые и отсутствующие записи пропускаются.
+func (s *Storage) RecordHits(hits []CacheHits) error {
+	for start := 0; start < len(hits); start += hitsChunk {
+		chunk := hits[start:min(start+hitsChunk, len(hits))]
+		values := make([]string, len(chunk))
+		args := make([]interface{}, 0, 3*len(chunk))
+		for i, hit := range chunk {
+			values[i] = "(?, ?::bigint, ?::timestamptz)"
+			args = append(args, hit.SourceKey, hit.Count, hit.LastUsedAt)
+		}
+		query := `UPDATE media_cache AS m
+SET hit_count = m.hit_count + v.count, last_used_at = GREATEST(m.last_used_at, v.last_used_at)
+FROM (VALUES ` + strings.Join(values, ", ") + `) AS v(source_key, count, last_used_at)
+WHERE m.source_key = v.source_key AND m.deleted_at IS NULL`
+		if err := s.db.Exec(query, args...).Error; err != nil {
+			return err
 		}
-		return result.Error
 	}
+	return nil
+}
 
-	// Обновляем существующую запись
-	return s.db.Model(&existing).Updates(map[string]interface{}{
-		"sha256":            entry.SHA256,
-		"tg_file_id":        entry.TgFileID,
-		"tg_file_unique_id": entry.TgFileUniqueID,
-		"size_bytes":        entry.SizeBytes,
-		"last_used_at":      time.Now(),
-	}).Error
+// Invalidate мягко удаляет запись, чей file_id Telegram больше не принимает.
+// Lookup и LookupBySHA256 её больше не видят; отсутствующая запись — не ошибка.
+func (s *Storage) Invalidate(sourceKey string) error {
+	return s.db.Where("source_key = ?", sourceKey).Delete(&MediaCache{}).Error
 }
@@ -0,0 +1,78 @@
+package storage
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Store — всё, что боту нужно от хранилища: кэш file_id, субтитры, негативный кэш,
+// история запросов, пользователи и чаты с настройками, очередь задач.
+// Реализации: Storage (PostgreSQL) и Memory (в памяти процесса, для локального запуска и тестов).
+type Store interface {
+	Lookup(sourceKey string) (*MediaCache, error)
+	LookupBySHA256(hash string) (*MediaCache, error)
+	Save(entry *MediaCache) error
+	Upsert(entry *MediaCache) error
+	RecordHits(hits []CacheHits) error
+	Invalidate(sourceKey string) error
+	ExpireCache(unusedSince time.Time) (int64, error)
+	EvictCache(maxEntries int, policy EvictionPolicy) (int64, error)
+	PurgeCache(deletedBefore time.Time) (int64, error)
+
+	LookupSubtitle(sourceKey, lang string) (*Subtitle, error)
+	SaveSubtitle(entry *Subtitle) error
+
+	LookupFailure(sourceKey string) (*Failure, error)
+	SaveFailure(entry *Failure) error
+	PurgeFailures(expiredBefore time.Time) (int64, error)
+
+	SaveRequests(entries []Request) error
+	PurgeRequests(before time.Time) (int64, error)
+
+	TouchUser(user *User) error
+	TouchChat(chat *Chat) error
+	MarkBlocked(chatID int64) error
+	LookupUser(id int64) (*User, error)
+	LookupChat(id int64) (*Chat, error)
+	SetUserPreferences(id int64, prefs Preferences) error
+	SetChatPreferences(id int64, prefs Preferences) error
+
+	EnqueueJob(job *Job) error
+	ClaimJob(lease time.Duration) (*Job, error)
+	RenewJobLease(id uint, lease time.Duration) error
+	RetryJob(id uint, lastError string, nextRunAt time.Time) error
+	FinishJob(id uint, status JobStatus, lastError string) error
+	SetJobStatusMessage(id uint, messageID int) error
+	JobByStatusMessage(chatID int64, messageID int) (*Job, error)
+	CancelPendingJob(id uint) (bool, error)
+	RequeueExpiredJobs() ([]Job, error)
+
+	Close() error
+}
+
+var (
+	_ Store = (*Storage)(nil)
+	_ Store = (*Memory)(nil)
+)
+
+// Open выбирает бэкенд по схеме DATABASE_URL:
+// postgres:// и postgresql:// (или DSN вида "host=... dbname=...") — PostgreSQL,
+// memory:// — хранилище в памяти: без docker-compose, но всё теряется при рестарте.
+func Open(dsn string, log *zap.Logger) (Store, error) {
+	scheme, _, ok := strings.Cut(dsn, "://")
+	if !ok {
+		// key=value DSN без схемы — так его понимает pgx
+		return New(dsn, log)
+	}
+	switch strings.ToLower(scheme) {
+	case "postgres", "postgresql":
+		return New(dsn, log)
+	case "memory":
+		return NewMemory(log), nil
+	default:
+		return nil, fmt.Errorf("unsupported DATABASE_URL scheme %q", scheme)
+	}
+}
@@ -0,0 +1,574 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Один набор проверок для всех реализаций Store: бот не должен замечать,
+// на каком бэкенде он работает.
+
+func TestMemoryStore(t *testing.T) {
+	testStore(t, func(t *testing.T) Store {
+		return NewMemory(zap.NewNop())
+	})
+}
+
+// TestLRUStore — LRU поверх Memory не должен менять поведение хранилища.
+func TestLRUStore(t *testing.T) {
+	testStore(t, func(t *testing.T) Store {
+		return NewLRU(NewMemory(zap.NewNop()), 2, time.Minute)
+	})
+}
+
+// TestPostgresStore гоняет тот же набор на настоящей базе из TEST_DATABASE_URL.
+// Таблицы очищаются перед каждым подтестом — не указывайте рабочую базу.
+func TestPostgresStore(t *testing.T) {
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+	testStore(t, func(t *testing.T) Store {
+		s, err := New(dsn, zap.NewNop())
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { s.Close() })
+		if err := s.db.Exec("TRUNCATE media_cache, media_subtitles, failure_cache, requests, users, chats, jobs RESTART IDENTITY").Error; err != nil {
+			t.Fatal(err)
+		}
+		return s
+	})
+}
+
+func TestOpen(t *testing.T) {
+	s, err := Open("memory://", zap.NewNop())
+	if err != nil {
+		t.Fatalf("Open(memory://) error = %v", err)
+	}
+	if _, ok := s.(*Memory); !ok {
+		t.Fatalf("Open(memory://) = %T, want *Memory", s)
+	}
+	if _, err := Open("mysql://localhost/db", zap.NewNop()); err == nil {
+		t.Fatal("Open(mysql://) error = nil, want unsupported scheme")
+	}
+}
+
+func testStore(t *testing.T, newStore func(t *testing.T) Store) {
+	t.Run("cache", func(t *testing.T) {
+		s := newStore(t)
+
+		if _, err := s.Lookup("tiktok:1"); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("Lookup(missing) error = %v, want ErrNotFound", err)
+		}
+
+		entry := &MediaCache{SourceKey: "tiktok:1", SHA256: "aa", TgFileID: "file-1", TgFileUniqueID: "u1", SizeBytes: 100}
+		if err := s.Upsert(entry); err != nil {
+			t.Fatal(err)
+		}
+		if entry.ID == 0 {
+			t.Error("Upsert(new) did not set ID")
+		}
+		if err := s.Save(&MediaCache{SourceKey: "tiktok:1", TgFileID: "x", TgFileUniqueID: "x"}); !errors.Is(err, ErrDuplicateKey) {
+			t.Errorf("Save(duplicate) error = %v, want ErrDuplicateKey", err)
+		}
+
+		updated := &MediaCache{SourceKey: "tiktok:1", SHA256: "bb", TgFileID: "file-2", TgFileUniqueID: "u2", SizeBytes: 200}
+		if err := s.Upsert(updated); err != nil {
+			t.Fatal(err)
+		}
+		if updated.ID != entry.ID {
+			t.Errorf("Upsert(existing) ID = %d, want %d", updated.ID, entry.ID)
+		}
+		got, err := s.Lookup("tiktok:1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.ID != entry.ID || got.TgFileID != "file-2" || got.SHA256 != "bb" || got.SizeBytes != 200 || got.HitCount != 0 {
+			t.Errorf("Lookup after update = %+v", got)
+		}
+		usedAt := got.LastUsedAt.Add(time.Minute).Truncate(time.Microsecond) // точность timestamptz
+		if err := s.RecordHits([]CacheHits{
+			{SourceKey: "tiktok:1", Count: 2, LastUsedAt: usedAt},
+			{SourceKey: "tiktok:missing", Count: 1, LastUsedAt: usedAt},
+		}); err != nil {
+			t.Fatal(err)
+		}
+		if got, _ := s.Lookup("tiktok:1"); got.HitCount != 2 || !got.LastUsedAt.Equal(usedAt) {
+			t.Errorf("after RecordHits = hit_count %d, last_used_at %v; want 2, %v", got.HitCount, got.LastUsedAt, usedAt)
+		}
+
+		if err := s.Upsert(&MediaCache{SourceKey: "instagram:2", SHA256: "bb", TgFileID: "file-2", TgFileUniqueID: "u2"}); err != nil {
+			t.Fatal(err)
+		}
+		dedup, err := s.LookupBySHA256("bb")
+		if err != nil || dedup.SourceKey != "tiktok:1" {
+			t.Errorf("LookupBySHA256 = %+v, %v; want the earliest entry tiktok:1", dedup, err)
+		}
+		if _, err := s.LookupBySHA256("cc"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("LookupBySHA256(missing) error = %v, want ErrNotFound", err)
+		}
+	})
+
+	// На PostgreSQL проверяет, что Upsert и RecordHits атомарны: раньше параллельные
+	// скачивания одного видео падали на уникальном индексе source_key.
+	t.Run("concurrent cache", func(t *testing.T) {
+		s := newStore(t)
+		const writers, hitters = 16, 32
+
+		entries := make([]*MediaCache, writers)
+		errs := make(chan error, writers+hitters)
+		var wg sync.WaitGroup
+		for i := range entries {
+			entries[i] = &MediaCache{SourceKey: "tiktok:1", SHA256: "aa", TgFileID: fmt.Sprintf("file-%d", i), TgFileUniqueID: "u1", SizeBytes: 100}
+			wg.Add(1)
+			go func(entry *MediaCache) {
+				defer wg.Done()
+				errs <- s.Upsert(entry)
+			}(entries[i])
+		}
+		wg.Wait()
+		for i, entry := range entries {
+			if entry.ID != entries[0].ID {
+				t.Fatalf("Upsert #%d ID = %d, want the same row %d", i, entry.ID, entries[0].ID)
+			}
+		}
+
+		for range hitters {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				errs <- s.RecordHits([]CacheHits{{SourceKey: "tiktok:1", Count: 1, LastUsedAt: time.Now()}})
+			}()
+		}
+		wg.Wait()
+		close(errs)
+		for err := range errs {
+			if err != nil {
+				t.Fatalf("concurrent Upsert/RecordHits error = %v", err)
+			}
+		}
+
+		got, err := s.Lookup("tiktok:1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.HitCount != hitters {
+			t.Errorf("HitCount = %d, want %d: concurrent hits lost updates", got.HitCount, hitters)
+		}
+	})
+
+	t.Run("invalidate", func(t *testing.T) {
+		s := newStore(t)
+
+		if err := s.Upsert(&MediaCache{SourceKey: "tiktok:1", SHA256: "aa", TgFileID: "stale", TgFileUniqueID: "u1"}); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Invalidate("tiktok:1"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.Lookup("tiktok:1"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Lookup(invalidated) error = %v, want ErrNotFound", err)
+		}
+		if _, err := s.LookupBySHA256("aa"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("LookupBySHA256(invalidated) error = %v, want ErrNotFound", err)
+		}
+		if err := s.Invalidate("tiktok:missing"); err != nil {
+			t.Errorf("Invalidate(missing) error = %v, want nil", err)
+		}
+
+		// Повторное скачивание кладёт свежий file_id на место удалённого
+		if err := s.Upsert(&MediaCache{SourceKey: "tiktok:1", SHA256: "aa", TgFileID: "fresh", TgFileUniqueID: "u1"}); err != nil {
+			t.Fatalf("Upsert(invalidated) error = %v", err)
+		}
+		got, err := s.Lookup("tiktok:1")
+		if err != nil || got.TgFileID != "fresh" {
+			t.Errorf("Lookup after re-upsert = %+v, %v; want fresh file_id", got, err)
+		}
+	})
+
+	t.Run("maintenance", func(t *testing.T) {
+		s := newStore(t)
+
+		// Ключи в порядке сохранения; между записями проходит время, чтобы last_used_at различались
+		keys := []string{"tiktok:1", "tiktok:2", "tiktok:3", "tiktok:4"}
+		for _, key := range keys {
+			if err := s.Upsert(&MediaCache{SourceKey: key, TgFileID: key, TgFileUniqueID: key}); err != nil {
+				t.Fatal(err)
+			}
+			time.Sleep(2 * time.Millisecond)
+		}
+		// tiktok:1 отправляли недавно и дважды, tiktok:2 — один раз, раньше
+		s.RecordHits([]CacheHits{{SourceKey: "tiktok:2", Count: 1, LastUsedAt: time.Now()}})
+		time.Sleep(2 * time.Millisecond)
+		s.RecordHits([]CacheHits{{SourceKey: "tiktok:1", Count: 2, LastUsedAt: time.Now()}})
+
+		if n, err := s.ExpireCache(time.Now().Add(-time.Hour)); err != nil || n != 0 {
+			t.Fatalf("ExpireCache(hour ago) = %d, %v; want nothing expired", n, err)
+		}
+
+		// LRU: из четырёх остаются три — уходит tiktok:3, самый давний
+		if n, err := s.EvictCache(3, EvictLRU); err != nil || n != 1 {
+			t.Fatalf("EvictCache(3, lru) = %d, %v; want 1", n, err)
+		}
+		if _, err := s.Lookup("tiktok:3"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("LRU kept tiktok:3, error = %v", err)
+		}
+
+		// LFU: из трёх остаётся один — tiktok:1 с наибольшим hit_count
+		if n, err := s.EvictCache(1, EvictLFU); err != nil || n != 2 {
+			t.Fatalf("EvictCache(1, lfu) = %d, %v; want 2", n, err)
+		}
+		if _, err := s.Lookup("tiktok:1"); err != nil {
+			t.Errorf("LFU evicted tiktok:1: %v", err)
+		}
+		if n, err := s.EvictCache(1, EvictLFU); err != nil || n != 0 {
+			t.Fatalf("EvictCache(within limit) = %d, %v; want 0", n, err)
+		}
+
+		if n, err := s.ExpireCache(time.Now().Add(time.Hour)); err != nil || n != 1 {
+			t.Fatalf("ExpireCache(future) = %d, %v; want 1", n, err)
+		}
+
+		// Мягко удалённые ещё не прошли grace period
+		if n, err := s.PurgeCache(time.Now().Add(-time.Hour)); err != nil || n != 0 {
+			t.Fatalf("PurgeCache(hour ago) = %d, %v; want 0", n, err)
+		}
+		if n, err := s.PurgeCache(time.Now().Add(time.Hour)); err != nil || n != 4 {
+			t.Fatalf("PurgeCache(future) = %d, %v; want 4", n, err)
+		}
+		// После purge ключ свободен и для обычного Save
+		if err := s.Save(&MediaCache{SourceKey: "tiktok:3", TgFileID: "new", TgFileUniqueID: "new"}); err != nil {
+			t.Fatalf("Save after purge error = %v", err)
+		}
+	})
+
+	t.Run("subtitles", func(t *testing.T) {
+		s := newStore(t)
+
+		if _, err := s.LookupSubtitle("tiktok:1", "ru"); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("LookupSubtitle(missing) error = %v, want ErrNotFound", err)
+		}
+		for _, fileID := range []string{"sub-1", "sub-2"} {
+			if err := s.SaveSubtitle(&Subtitle{SourceKey: "tiktok:1", Lang: "ru", TrackLang: "rus", TgFileID: fileID, TgFileUniqueID: fileID}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		got, err := s.LookupSubtitle("tiktok:1", "ru")
+		if err != nil || got.TgFileID != "sub-2" || got.TrackLang != "rus" {
+			t.Errorf("LookupSubtitle = %+v, %v; want overwritten sub-2", got, err)
+		}
+		if _, err := s.LookupSubtitle("tiktok:1", "en"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("LookupSubtitle(other lang) error = %v, want ErrNotFound", err)
+		}
+	})
+
+	t.Run("failures", func(t *testing.T) {
+		s := newStore(t)
+
+		if _, err := s.LookupFailure("tiktok:1"); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("LookupFailure(missing) error = %v, want ErrNotFound", err)
+		}
+		if err := s.SaveFailure(&Failure{SourceKey: "tiktok:1", Class: "rate_limited", Text: "wait", ExpiresAt: time.Now().Add(time.Minute)}); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.SaveFailure(&Failure{SourceKey: "tiktok:1", Class: "removed", Text: "gone", ExpiresAt: time.Now().Add(time.Hour)}); err != nil {
+			t.Fatal(err)
+		}
+		got, err := s.LookupFailure("tiktok:1")
+		if err != nil || got.Class != "removed" || got.Text != "gone" {
+			t.Fatalf("LookupFailure = %+v, %v; want overwritten removed", got, err)
+		}
+
+		if err := s.SaveFailure(&Failure{SourceKey: "tiktok:2", Class: "rate_limited", Text: "wait", ExpiresAt: time.Now().Add(-time.Second)}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.LookupFailure("tiktok:2"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("LookupFailure(expired) error = %v, want ErrNotFound", err)
+		}
+		if n, err := s.PurgeFailures(time.Now()); err != nil || n != 1 {
+			t.Errorf("PurgeFailures = %d, %v; want 1", n, err)
+		}
+		if _, err := s.LookupFailure("tiktok:1"); err != nil {
+			t.Errorf("PurgeFailures removed live entry: %v", err)
+		}
+	})
+
+	t.Run("requests", func(t *testing.T) {
+		s := newStore(t)
+
+		if err := s.SaveRequests(nil); err != nil {
+			t.Fatalf("SaveRequests(nil) error = %v", err)
+		}
+		old := time.Now().Add(-48 * time.Hour)
+		entries := []Request{
+			{UserID: 1, ChatID: 1, ChatType: "private", SourceKey: "tiktok:1", Outcome: OutcomeCacheHit, TotalMs: 120, CreatedAt: old},
+			{UserID: 2, ChatID: -100, ChatType: "supergroup", SourceKey: "tiktok:2", Outcome: OutcomeFailed, ErrorClass: "removed"},
+		}
+		if err := s.SaveRequests(entries); err != nil {
+			t.Fatal(err)
+		}
+		if entries[0].ID == 0 || entries[1].ID == 0 || entries[1].CreatedAt.IsZero() {
+			t.Fatalf("SaveRequests did not fill ID and CreatedAt: %+v", entries)
+		}
+
+		if n, err := s.PurgeRequests(time.Now().Add(-24 * time.Hour)); err != nil || n != 1 {
+			t.Fatalf("PurgeRequests(day ago) = %d, %v; want 1", n, err)
+		}
+		if n, err := s.PurgeRequests(time.Now().Add(time.Minute)); err != nil || n != 1 {
+			t.Fatalf("PurgeRequests(now) = %d, %v; want the remaining 1", n, err)
+		}
+	})
+
+	t.Run("users and chats", func(t *testing.T) {
+		s := newStore(t)
+
+		if _, err := s.LookupUser(42); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("LookupUser(missing) error = %v, want ErrNotFound", err)
+		}
+		if err := s.SetUserPreferences(42, Preferences{Caption: CaptionNone}); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("SetUserPreferences(missing) error = %v, want ErrNotFound", err)
+		}
+		if err := s.TouchUser(&User{ID: 42, Username: "old", LanguageCode: "ru"}); err != nil {
+			t.Fatal(err)
+		}
+		prefs := Preferences{Caption: CaptionNone, SendAsDocument: true}
+		if err := s.SetUserPreferences(42, prefs); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.MarkBlocked(42); err != nil {
+			t.Fatal(err)
+		}
+		user, err := s.LookupUser(42)
+		if err != nil || !user.Blocked || user.BlockedAt == nil {
+			t.Fatalf("LookupUser after MarkBlocked = %+v, %v; want blocked", user, err)
+		}
+		firstSeen := user.FirstSeenAt
+
+		// Пользователь пишет в группе: профиль обновился, но блокировка в личке осталась
+		if err := s.TouchUser(&User{ID: 42, Username: "new", LanguageCode: "en"}); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.TouchChat(&Chat{ID: -300, Type: "group", Title: "friends"}); err != nil {
+			t.Fatal(err)
+		}
+		user, err = s.LookupUser(42)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if user.Username != "new" || user.LanguageCode != "en" || !user.Blocked {
+			t.Errorf("LookupUser after group touch = %+v; want updated and still blocked", user)
+		}
+
+		// Отложенная запись обновления, пришедшего до блокировки, её не снимает
+		if err := s.TouchChat(&Chat{ID: 42, Type: ChatPrivate, LastSeenAt: user.BlockedAt.Add(-time.Minute)}); err != nil {
+			t.Fatal(err)
+		}
+		if user, err = s.LookupUser(42); err != nil || !user.Blocked {
+			t.Fatalf("LookupUser after stale private touch = %+v, %v; want still blocked", user, err)
+		}
+
+		// Пользователь вернулся в личку: блокировка снята, настройки на месте
+		if err := s.TouchChat(&Chat{ID: 42, Type: ChatPrivate}); err != nil {
+			t.Fatal(err)
+		}
+		user, err = s.LookupUser(42)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if user.Blocked || user.BlockedAt != nil {
+			t.Errorf("LookupUser after private touch = %+v; want unblocked", user)
+		}
+		if user.Preferences != prefs {
+			t.Errorf("Preferences = %+v, want %+v", user.Preferences, prefs)
+		}
+		if !user.FirstSeenAt.Equal(firstSeen) || user.LastSeenAt.Before(firstSeen) {
+			t.Errorf("seen = %v..%v; want first_seen kept at %v", user.FirstSeenAt, user.LastSeenAt, firstSeen)
+		}
+
+		if err := s.TouchChat(&Chat{ID: -100, Type: "supergroup", Title: "memes"}); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.SetChatPreferences(-100, Preferences{SendAsDocument: true}); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.MarkBlocked(-100); err != nil {
+			t.Fatal(err)
+		}
+		chat, err := s.LookupChat(-100)
+		if err != nil || !chat.Blocked || chat.Title != "memes" || !chat.Preferences.SendAsDocument {
+			t.Fatalf("LookupChat = %+v, %v; want blocked memes with document preference", chat, err)
+		}
+		if _, err := s.LookupChat(-200); !errors.Is(err, ErrNotFound) {
+			t.Errorf("LookupChat(missing) error = %v, want ErrNotFound", err)
+		}
+	})
+
+	t.Run("jobs", func(t *testing.T) {
+		s := newStore(t)
+
+		if _, err := s.ClaimJob(time.Minute); !errors.Is(err, ErrNoJobs) {
+			t.Fatalf("ClaimJob(empty) error = %v, want ErrNoJobs", err)
+		}
+
+		first := &Job{ChatID: 1, URL: "https://a", SourceKey: "tiktok:1", MaxAttempts: 3}
+		second := &Job{ChatID: 1, URL: "https://b", SourceKey: "tiktok:2", MaxAttempts: 2}
+		for _, job := range []*Job{first, second} {
+			if err := s.EnqueueJob(job); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if first.ID == 0 || first.Status != JobPending || first.NextRunAt.IsZero() {
+			t.Fatalf("EnqueueJob did not fill defaults: %+v %+v", first, second)
+		}
+		if err := s.SetJobStatusMessage(first.ID, 42); err != nil {
+			t.Fatal(err)
+		}
+
+		claimed, err := s.ClaimJob(time.Minute)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if claimed.ID != first.ID || claimed.Status != JobRunning || claimed.Attempts != 1 || claimed.StartedAt == nil {
+			t.Fatalf("ClaimJob = %+v, want first job running with 1 attempt", claimed)
+		}
+
+		// Отложенная задача не берётся раньше времени
+		if err := s.RetryJob(first.ID, "boom", time.Now().Add(time.Hour)); err != nil {
+			t.Fatal(err)
+		}
+		claimed, err = s.ClaimJob(time.Minute)
+		if err != nil || claimed.ID != second.ID {
+			t.Fatalf("ClaimJob = %+v, %v; want second job", claimed, err)
+		}
+		if _, err := s.ClaimJob(time.Minute); !errors.Is(err, ErrNoJobs) {
+			t.Fatalf("ClaimJob(only delayed) error = %v, want ErrNoJobs", err)
+		}
+
+		job, err := s.JobByStatusMessage(1, 42)
+		if err != nil || job.ID != first.ID || job.LastError != "boom" || job.Status != JobPending {
+			t.Fatalf("JobByStatusMessage = %+v, %v", job, err)
+		}
+		if _, err := s.JobByStatusMessage(1, 43); !errors.Is(err, ErrJobNotFound) {
+			t.Fatalf("JobByStatusMessage(missing) error = %v, want ErrJobNotFound", err)
+		}
+
+		if ok, err := s.CancelPendingJob(first.ID); err != nil || !ok {
+			t.Fatalf("CancelPendingJob(pending) = %v, %v; want true", ok, err)
+		}
+		if ok, err := s.CancelPendingJob(second.ID); err != nil || ok {
+			t.Fatalf("CancelPendingJob(running) = %v, %v; want false", ok, err)
+		}
+
+		// Аренда живого инстанса действует — задачу не трогаем
+		if requeued, err := s.RequeueExpiredJobs(); err != nil || len(requeued) != 0 {
+			t.Fatalf("RequeueExpiredJobs(live lease) = %+v, %v; want nothing", requeued, err)
+		}
+		// Инстанс умер: аренда истекла, попытка засчитана, задача снова в очереди
+		if err := s.RenewJobLease(second.ID, -time.Second); err != nil {
+			t.Fatal(err)
+		}
+		requeued, err := s.RequeueExpiredJobs()
+		if err != nil || len(requeued) != 1 || requeued[0].ID != second.ID || requeued[0].Status != JobPending {
+			t.Fatalf("RequeueExpiredJobs = %+v, %v; want second job pending", requeued, err)
+		}
+		claimed, err = s.ClaimJob(-time.Second)
+		if err != nil || claimed.ID != second.ID || claimed.Attempts != 2 {
+			t.Fatalf("ClaimJob after requeue = %+v, %v; want second job, interrupted attempt counted", claimed, err)
+		}
+		// Снова упали, а попытки кончились — задача проваливается, а не крутится вечно
+		requeued, err = s.RequeueExpiredJobs()
+		if err != nil || len(requeued) != 1 || requeued[0].Status != JobFailed {
+			t.Fatalf("RequeueExpiredJobs(exhausted) = %+v, %v; want second job failed", requeued, err)
+		}
+		if _, err := s.ClaimJob(time.Minute); !errors.Is(err, ErrNoJobs) {
+			t.Fatalf("ClaimJob after failure error = %v, want ErrNoJobs", err)
+		}
+
+		defaults := &Job{ChatID: 1, URL: "https://c", SourceKey: "tiktok:3"}
+		if err := s.EnqueueJob(defaults); err != nil || defaults.MaxAttempts != 1 {
+			t.Fatalf("EnqueueJob(no max attempts) = %+v, %v; want MaxAttempts 1", defaults, err)
+		}
+	})
+}
+
+func TestMaintain(t *testing.T) {
+	s := NewMemory(zap.NewNop())
+	for _, key := range []string{"tiktok:1", "tiktok:2", "tiktok:3"} {
+		if err := s.Upsert(&MediaCache{SourceKey: key}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s.Invalidate("tiktok:3")
+
+	// Шаги с нулевыми порогами не выполняются
+	report, err := Maintain(s, MaintenanceConfig{MaxEntries: 1, Eviction: EvictLRU}, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report != (MaintenanceReport{Evicted: 1}) {
+		t.Fatalf("Maintain(cap only) = %+v, want 1 evicted", report)
+	}
+
+	later := time.Now().Add(48 * time.Hour)
+	report, err = Maintain(s, MaintenanceConfig{TTL: 24 * time.Hour, PurgeAfter: time.Hour}, later)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report != (MaintenanceReport{Expired: 1, Purged: 3}) {
+		t.Fatalf("Maintain(ttl and purge) = %+v, want 1 expired and 3 purged", report)
+	}
+}
+
+func TestLoadMigrations(t *testing.T) {
+	migrations, err := loadMigrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(migrations) == 0 || migrations[0].Name != "media_cache" {
+		t.Fatalf("loadMigrations() = %+v, want 0001_media_cache first", migrations)
+	}
+}
+
+// TestPostgresMigrations проверяет, что все миграции откатываются и накатываются заново.
+func TestPostgresMigrations(t *testing.T) {
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+	s, err := New(dsn, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	ctx := context.Background()
+
+	migrations, _ := loadMigrations()
+	if _, err := s.MigrateDown(ctx, len(migrations)); err != nil {
+		t.Fatalf("MigrateDown(all) error = %v", err)
+	}
+	if _, err := s.MigrateDown(ctx, 1); !errors.Is(err, ErrNoMigrations) {
+		t.Fatalf("MigrateDown(empty) error = %v, want ErrNoMigrations", err)
+	}
+	applied, err := s.MigrateUp(ctx)
+	if err != nil || len(applied) != len(migrations) {
+		t.Fatalf("MigrateUp() = %d migrations, %v; want %d", len(applied), err, len(migrations))
+	}
+
+	states, err := s.MigrationStatus(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, st := range states {
+		if st.AppliedAt == nil {
+			t.Errorf("migration %04d_%s not applied", st.Version, st.Name)
+		}
+	}
+}
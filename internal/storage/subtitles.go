@@ -0,0 +1,31 @@
+package storage
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// --- Субтитры ---
+
+// LookupSubtitle ищет субтитры видео на языке lang.
+func (s *Storage) LookupSubtitle(sourceKey, lang string) (*Subtitle, error) {
+	var entry Subtitle
+	result := s.db.Where("source_key = ? AND lang = ?", sourceKey, lang).First(&entry)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, result.Error
+	}
+	return &entry, nil
+}
+
+// SaveSubtitle сохраняет file_id субтитров; повторное сохранение того же ключа перезаписывает его.
+func (s *Storage) SaveSubtitle(entry *Subtitle) error {
+	return s.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "source_key"}, {Name: "lang"}},
+		DoUpdates: clause.AssignmentColumns([]string{"track_lang", "tg_file_id", "tg_file_unique_id"}),
+	}).Create(entry).Error
+}
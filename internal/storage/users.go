@@ -0,0 +1,117 @@
+package storage
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// --- Пользователи и чаты ---
+
+// TouchUser добавляет пользователя или обновляет его профиль и last_seen_at
+// (нулевой LastSeenAt — текущее время). Блокировку и настройки не трогает:
+// сообщение в группе ничего не говорит о том, заблокирован ли бот в личке.
+func (s *Storage) TouchUser(user *User) error {
+	user.LastSeenAt = seenAt(user.LastSeenAt)
+	user.FirstSeenAt = user.LastSeenAt
+	user.Blocked, user.BlockedAt = false, nil
+	return s.db.Omit("preferences").Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"username", "first_name", "language_code", "last_seen_at"}),
+	}).Create(user).Error
+}
+
+// TouchChat добавляет чат или обновляет его тип, название и last_seen_at
+// (нулевой LastSeenAt — текущее время). Раз из чата пришло обновление, бот в нём
+// не заблокирован: blocked сбрасывается, а для личного чата — и у пользователя
+// с тем же ID. Блокировку новее LastSeenAt не снимает — отложенная запись
+// не должна отменять свежий 403.
+func (s *Storage) TouchChat(chat *Chat) error {
+	chat.LastSeenAt = seenAt(chat.LastSeenAt)
+	chat.FirstSeenAt = chat.LastSeenAt
+	chat.Blocked, chat.BlockedAt = false, nil
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Omit("preferences").Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"type", "title", "last_seen_at"}),
+		}).Create(chat).Error
+		if err != nil {
+			return err
+		}
+		unblocked := map[string]interface{}{"blocked": false, "blocked_at": nil}
+		if err := tx.Model(&Chat{}).Where("id = ? AND blocked AND blocked_at <= ?", chat.ID, chat.LastSeenAt).Updates(unblocked).Error; err != nil {
+			return err
+		}
+		if chat.Type != ChatPrivate {
+			return nil
+		}
+		return tx.Model(&User{}).Where("id = ? AND blocked AND blocked_at <= ?", chat.ID, chat.LastSeenAt).Updates(unblocked).Error
+	})
+}
+
+// seenAt — время обновления для Touch*: заданное вызывающим или текущее.
+func seenAt(t time.Time) time.Time {
+	if t.IsZero() {
+		return time.Now()
+	}
+	return t
+}
+
+// MarkBlocked помечает чат заблокированным (Telegram ответил 403).
+// ID личного чата совпадает с ID пользователя — он тоже помечается.
+func (s *Storage) MarkBlocked(chatID int64) error {
+	blocked := map[string]interface{}{"blocked": true, "blocked_at": time.Now()}
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&Chat{}).Where("id = ? AND NOT blocked", chatID).Updates(blocked).Error; err != nil {
+			return err
+		}
+		return tx.Model(&User{}).Where("id = ? AND NOT blocked", chatID).Updates(blocked).Error
+	})
+}
+
+// LookupUser ищет пользователя по Telegram ID.
+func (s *Storage) LookupUser(id int64) (*User, error) {
+	var user User
+	if err := s.db.First(&user, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &user, nil
+}
+
+// LookupChat ищет чат по Telegram ID.
+func (s *Storage) LookupChat(id int64) (*Chat, error) {
+	var chat Chat
+	if err := s.db.First(&chat, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &chat, nil
+}
+
+// SetUserPreferences заменяет настройки пользователя. Пользователь должен уже быть в базе.
+func (s *Storage) SetUserPreferences(id int64, prefs Preferences) error {
+	return updatePreferences(s.db.Model(&User{}), id, prefs)
+}
+
+// SetChatPreferences заменяет настройки чата. Чат должен уже быть в базе.
+func (s *Storage) SetChatPreferences(id int64, prefs Preferences) error {
+	return updatePreferences(s.db.Model(&Chat{}), id, prefs)
+}
+
+func updatePreferences(model *gorm.DB, id int64, prefs Preferences) error {
+	result := model.Where("id = ?", id).Update("preferences", prefs)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}